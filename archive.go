@@ -0,0 +1,157 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// isDoc reports whether a file (e.g. an archive member, or a file found with -r) looks like a word doc (or template), or an OOXML or RTF one, from its extension
+func isDoc(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".doc", ".dot", ".docx", ".docm", ".dotx", ".dotm", ".rtf":
+		return true
+	}
+	return false
+}
+
+// processMember reports the fields of an archive member (which is read into memory, as mscfb needs a ReaderAt), identifying it first with -sf as for any other file.
+// A member bigger than -max-read isn't read past the limit, so a zip or tar bomb can't exhaust memory; it is reported with ErrTooBig.
+// Results are tagged with the archive name and the member path, e.g. package.zip!data/letter.doc.
+// It returns ctx.Err() if the run is interrupted, without reporting the member.
+func processMember(ctx context.Context, arc, name string, rdr io.Reader) error {
+	max := *maxRead << 20
+	var buf []byte
+	var err error
+	if max > 0 {
+		if buf, err = io.ReadAll(io.LimitReader(rdr, max+1)); err == nil && int64(len(buf)) > max {
+			err = fmt.Errorf("%w: the archive member is over the limit of %d bytes (-max-read)", fields.ErrTooBig, max)
+		}
+	} else {
+		buf, err = io.ReadAll(rdr)
+	}
+	if err != nil {
+		output(arc+"!"+name, nil, nil, wrapError(err))
+		return nil
+	}
+	var fix *fixity
+	if wantFixity() {
+		fix, _ = checksum(bytes.NewReader(buf)) // reading from memory can't fail
+	}
+	res, _, err := withTimeout(ctx, func(ctx context.Context) (*fields.Report, *fixity, error) {
+		ra := bytes.NewReader(buf)
+		if sf != nil {
+			if err := identify(name, ra); err != nil {
+				return nil, nil, err
+			}
+		}
+		res, err := parse(ctx, arc+"!"+name, ra)
+		return res, nil, err
+	})
+	if err != nil && err == ctx.Err() {
+		return err
+	}
+	output(arc+"!"+name, fix, res, err)
+	return nil
+}
+
+// processArchive iterates the members of a zip or tar (or gzipped tar) archive, processing each word doc member directly from the archive stream.
+// It stops, returning ctx.Err(), if ctx is cancelled (e.g. by Ctrl-C).
+func processArchive(ctx context.Context, arc string) error {
+	if strings.EqualFold(filepath.Ext(arc), ".zip") {
+		return processZip(ctx, arc)
+	}
+	return processTar(ctx, arc)
+}
+
+func processZip(ctx context.Context, arc string) error {
+	rdr, err := zip.OpenReader(arc)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer rdr.Close()
+	for _, f := range rdr.File {
+		if stopped {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() || !isDoc(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			output(arc+"!"+f.Name, nil, nil, wrapError(err))
+			continue
+		}
+		err = processMember(ctx, arc, f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func processTar(ctx context.Context, arc string) error {
+	file, err := os.Open(arc)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer file.Close()
+	buf := bufio.NewReader(file)
+	var src io.Reader = buf
+	if sig, _ := buf.Peek(2); len(sig) == 2 && sig[0] == 0x1F && sig[1] == 0x8B { // a .tar.gz or .tgz, whatever it is called
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			return wrapError(err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+	rdr := tar.NewReader(src)
+	for !stopped {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := rdr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return wrapError(err)
+		}
+		if hdr.Typeflag != tar.TypeReg || !isDoc(hdr.Name) {
+			continue
+		}
+		if err := processMember(ctx, arc, hdr.Name, rdr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// benchRuns is bench's -n
+var benchRuns *int
+
+// benchFlags defines the flags that only bench has
+func benchFlags(fs *flag.FlagSet) {
+	benchRuns = fs.Int("n", 3, "the number of times to parse the documents")
+}
+
+// jsonBench is the object written for each run by `doctool -json bench`
+type jsonBench struct {
+	Run           int     `json:"run"`
+	Files         int     `json:"files"`
+	Failed        int     `json:"failed"` // files that couldn't be opened or parsed (not those without fields)
+	Bytes         int64   `json:"bytes"`
+	Seconds       float64 `json:"seconds"`
+	FilesPerSec   float64 `json:"filespersec"`
+	MiBPerSec     float64 `json:"mibpersec"`
+	AllocsPerFile uint64  `json:"allocsperfile"`
+	BytesPerFile  uint64  `json:"bytesperfile"` // allocated
+}
+
+// benchCommand runs `doctool bench [-n 3] file ...`, which parses every document n times, with -j workers, and reports the throughput and memory allocation of each run.
+// Only the parse is timed, not writing a report, so the figures can be compared between versions of doctool on the same corpus. The first run also warms the
+// operating system's file cache, so the later runs are the ones to compare.
+func benchCommand(ins []string) {
+	if *benchRuns < 1 {
+		fatal("bench: -n must be at least 1")
+	}
+	n := *workers
+	if n < 1 {
+		n = 1
+	}
+	best := -1.0
+	for run := 1; run <= *benchRuns; run++ {
+		var ms runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&ms)
+		mallocs, alloc := ms.Mallocs, ms.TotalAlloc
+		jb := jsonBench{Run: run, Files: len(ins)}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		next := make(chan string)
+		start := time.Now()
+		for w := 0; w < n; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for in := range next {
+					size, ok := benchFile(in)
+					mu.Lock()
+					jb.Bytes += size
+					if !ok {
+						jb.Failed++
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, in := range ins {
+			next <- in
+		}
+		close(next)
+		wg.Wait()
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&ms)
+		jb.Seconds = elapsed.Seconds()
+		if jb.Seconds > 0 {
+			jb.FilesPerSec, jb.MiBPerSec = float64(jb.Files)/jb.Seconds, float64(jb.Bytes)/(1<<20)/jb.Seconds
+		}
+		if jb.Files > 0 {
+			jb.AllocsPerFile, jb.BytesPerFile = (ms.Mallocs-mallocs)/uint64(jb.Files), (ms.TotalAlloc-alloc)/uint64(jb.Files)
+		}
+		if *jsonOut {
+			if err := json.NewEncoder(out).Encode(jb); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			continue
+		}
+		fmt.Fprintf(out, "Run %d: %d files (%.1f MiB, failed: %d) in %s: %.1f files/s, %.1f MiB/s, %d allocations and %d KiB allocated per file\n",
+			run, jb.Files, float64(jb.Bytes)/(1<<20), jb.Failed, elapsed.Round(time.Millisecond), jb.FilesPerSec, jb.MiBPerSec, jb.AllocsPerFile, jb.BytesPerFile>>10)
+		if jb.FilesPerSec > best {
+			best = jb.FilesPerSec
+		}
+	}
+	if !*jsonOut && *benchRuns > 1 {
+		fmt.Fprintf(out, "Best: %.1f files/s (workers: %d)\n", best, n)
+	}
+}
+
+// benchFile parses a document for bench, returning its size and whether it could be parsed (including if it has no fields)
+func benchFile(in string) (int64, bool) {
+	file, closeInput, err := openInput(in)
+	if err != nil {
+		return 0, false
+	}
+	defer closeInput()
+	var size int64
+	if fi, err := os.Stat(in); err == nil {
+		size = fi.Size()
+	}
+	_, err = parse(context.Background(), in, file)
+	return size, err == nil || err == fields.ErrNoFields
+}
@@ -0,0 +1,121 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// command is one of doctool's commands, e.g. doctool text file ... Each has its own flags, which follow its name, as well as the global flags that every command shares.
+// A command's flags are also top-level flags (so doctool -text file ... is the same as doctool text file ...), apart from those that only make sense for it, given by define.
+type command struct {
+	name, args, summary string
+	flags               []string               // the names of the top-level flags that belong to the command
+	define              func(fs *flag.FlagSet) // defines the flags that only the command has, if any
+	mode                *bool                  // the top-level flag that the command stands for, which it sets
+	report              bool                   // the command reports on documents, so takes all the flags that choose what goes in the report (see reportFlags)
+	noFields            bool                   // the command reports on documents without listing their fields
+}
+
+// globalFlags are the flags that every command shares: what is read, where the output goes and in what form, and how the run goes
+var globalFlags = []string{"json", "xml", "csv", "long", "template", "sqlite", "o", "q", "hash", "r", "recursive", "ext", "from-file", "archive", "j", "workers", "basename", "strict", "lenient", "timeout", "max-read", "sf", "v", "vv", "log", "plugin"}
+
+// modeFlags are the top-level flags that each stand for a command that doesn't report fields, so they don't belong to the commands that do
+var modeFlags = []string{"list", "slack", "text"}
+
+// filterFlags are the flags that choose which fields are reported, for commands (like diff) that take those but not the rest of the report flags
+var filterFlags = []string{"type", "fields", "profile-set", "profile-file", "external"}
+
+var commands = []*command{
+	{name: "fields", args: "file ...", report: true, summary: "report the fields of each document, with any of the other parts of the report chosen by the flags; this is what doctool does without a command. doctool [-json] fields list lists the field types doctool knows, with their codes, keywords, classes and specs"},
+	{name: "meta", args: "file ...", summary: "report the document properties (and custom properties) of each document, without its fields", flags: []string{"metadata", "assoc", "savedby", "word-version", "macros"}, mode: meta, noFields: true},
+	{name: "triage", args: "file ...", summary: "check each document for DDE and DDEAUTO fields, macros, packaged files, and encryption or obfuscation, and give a risk summary; exits with status 5 if any are found", flags: []string{"external", "objects", "macros", "policy"}, mode: triageMode},
+	{name: "text", args: "file ...", summary: "print the plain text of each .doc, with fields shown by their results", mode: textOut},
+	{name: "slack", args: "[-text] file ...", summary: "report the text in each .doc that isn't part of the document, with a preview of each run (or, with -text, all of it)", flags: []string{"text"}, mode: slack},
+	{name: "list", args: "file ...", summary: "list the storages and streams in each file", mode: list},
+	{name: "streams", args: "file ...", summary: "list the storages and streams of each compound file, with their sizes, CLSIDs and times"},
+	{name: "fib", args: "file ...", summary: "print every part of the FIB of each .doc"},
+	{name: "diff", args: "[-meta] a.doc b.doc", summary: "compare the fields of two documents; exits 0 if they are the same, 1 if not", flags: filterFlags, define: diffFlags},
+	{name: "watch", args: "[-settle 2s] [-existing] folder ...", summary: "process the documents added to folders, until interrupted", define: watchFlags, report: true},
+	{name: "serve", args: "[-addr :8080] [-max-size 100]", summary: "report the fields of documents POSTed over HTTP, as JSON", define: serveFlags, report: true},
+	{name: "bench", args: "[-n 3] file ...", summary: "parse each document n times, with -j workers, and report the files and MiB parsed per second and the memory allocated per file, to measure doctool's throughput on a corpus", define: benchFlags},
+}
+
+// reportFlags returns the names of the top-level flags that aren't global or modes, which choose what is in the report on each document
+func reportFlags() []string {
+	skip := make(map[string]bool)
+	for _, n := range append(append([]string{}, globalFlags...), modeFlags...) {
+		skip[n] = true
+	}
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if !skip[f.Name] {
+			names = append(names, f.Name)
+		}
+	})
+	return names
+}
+
+// findCommand returns the command with the given name, or nil if there isn't one
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// parse parses the flags given after the command's name, which set the same variables as the top-level flags, and returns the arguments that follow them.
+// It sets the command's mode (e.g. -text for text).
+func (c *command) parse(args []string) []string {
+	fs := flag.NewFlagSet("doctool "+c.name, flag.ExitOnError)
+	fs.SetOutput(flag.CommandLine.Output())
+	names := append(append([]string{}, globalFlags...), c.flags...)
+	if c.report {
+		names = append(names, reportFlags()...)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if f := flag.Lookup(n); f != nil {
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	}
+	if c.define != nil {
+		c.define(fs)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: doctool %s [flags] %s\n\n%s.\n\nFlags (the global flags, %s, can also be given before %s):\n",
+			c.name, c.args, strings.ToUpper(c.summary[:1])+c.summary[1:], "-"+strings.Join(globalFlags, ", -"), c.name)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if c.mode != nil {
+		*c.mode = true
+	}
+	return fs.Args()
+}
+
+// commandUsage lists the commands, for the usage text
+func commandUsage() string {
+	var b strings.Builder
+	for _, c := range commands {
+		fmt.Fprintf(&b, "  %-8s %s\n", c.name, c.summary)
+	}
+	return b.String()
+}
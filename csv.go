@@ -0,0 +1,110 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// csvWriter is created, and the header row written, on the first call to writeCSV
+var csvWriter *csv.Writer
+
+// writeCSV writes a row for a file: its name, table stream, the field summary (as in the default output) for each region, its size and checksum (unless -hash is none),
+// its status (see fileStatus) and any error
+func writeCSV(w io.Writer, name string, fix *fixity, res *fields.Report, err error) {
+	if *csvLong {
+		writeCSVLong(w, name, fix, res, err)
+		return
+	}
+	if csvWriter == nil {
+		csvWriter = csv.NewWriter(w)
+		hdr := []string{"file", "table"}
+		hdr = append(hdr, fields.RegionKeys()...)
+		csvWriter.Write(append(append(hdr, fixityHeader()...), "status", "error"))
+	}
+	row := []string{header(name), ""}
+	if res != nil {
+		row[1] = res.Table
+	}
+	if res != nil {
+		for _, r := range res.AllRegions() {
+			row = append(row, summarise(r.Fields))
+		}
+	} else {
+		row = append(row, make([]string, len(fields.RegionKeys()))...)
+	}
+	var e string
+	if err != nil {
+		e = err.Error()
+	}
+	csvWriter.Write(append(append(row, fixityCells(fix)...), fileStatus(err), e))
+}
+
+// fixityHeader returns the headers of the size and checksum columns, which are named after the -hash algorithm, e.g. sha256 (none with -hash none)
+func fixityHeader() []string {
+	if hashes[*hashAlg] == nil {
+		return nil
+	}
+	return []string{"size", *hashAlg}
+}
+
+// fixityCells returns the size and checksum cells for a file, which are empty if it couldn't be read
+func fixityCells(fix *fixity) []string {
+	if hashes[*hashAlg] == nil {
+		return nil
+	}
+	if fix == nil {
+		return []string{"", ""}
+	}
+	return []string{strconv.FormatInt(fix.Size, 10), fix.Checksum}
+}
+
+// writeCSVLong writes a row for each field found in a file, giving its region (as in the -json keys) and type.
+// A file without fields, or that couldn't be processed, still gets a row, with empty region and field cells.
+func writeCSVLong(w io.Writer, name string, fix *fixity, res *fields.Report, err error) {
+	if csvWriter == nil {
+		csvWriter = csv.NewWriter(w)
+		csvWriter.Write(append(append([]string{"file", "region", "field"}, fixityHeader()...), "status", "error"))
+	}
+	cells := fixityCells(fix)
+	var e string
+	if err != nil {
+		e = err.Error()
+	}
+	var n int
+	if res != nil {
+		for _, r := range res.Regions() {
+			for _, f := range r.Fields {
+				csvWriter.Write(append(append([]string{header(name), r.Key, f}, cells...), fileStatus(err), e))
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		csvWriter.Write(append(append([]string{header(name), "", ""}, cells...), fileStatus(err), e))
+	}
+}
+
+func flushCSV() error {
+	if csvWriter == nil {
+		return nil
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,147 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// diffMetaFlag is diff's -meta, which is its own rather than the top-level -meta
+var diffMetaFlag *bool
+
+// diffFlags defines the flags that only diff has
+func diffFlags(fs *flag.FlagSet) {
+	diffMetaFlag = fs.Bool("meta", false, "compare the document properties (title, author etc.) and whether there are macros, as well as the fields")
+}
+
+// diffCommand runs `doctool diff [-meta] a.doc b.doc`, which compares the fields of two documents, region by region, and returns the exit status:
+// exitFields (0) if they are the same, exitNoFields (1) if they differ, or exitFailed (2) if either can't be read.
+// The filters (-type, -fields, -profile-set and -external) apply to both documents, so just those fields are compared.
+func diffCommand(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(out, "diff compares two documents: doctool diff [-meta] a.doc b.doc")
+		return exitFailed
+	}
+	var reports [2]*fields.Report
+	for i, in := range args {
+		res, _, err := process(context.Background(), in)
+		if err != nil && err != fields.ErrNoFields {
+			fmt.Fprintf(out, "%s: %v\n", in, err)
+			return exitFailed
+		}
+		if profile != nil || *external {
+			filter(res)
+		}
+		reports[i] = res
+	}
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", args[0], args[1])
+	same := true
+	if *diffMetaFlag {
+		same = diffMeta(out, reports[0], reports[1])
+	}
+	if !diffFields(out, reports[0], reports[1]) {
+		same = false
+	}
+	if same {
+		fmt.Fprintln(out, "No differences")
+		return exitFields
+	}
+	return exitNoFields
+}
+
+// diffFields prints the fields added, removed and changed in each region from a to b, returning false if there are any.
+// The fields of each type are paired up in document order: a pair whose instructions differ (ignoring extra spaces) is changed, and any left over are added or removed.
+func diffFields(w io.Writer, a, b *fields.Report) bool {
+	same := true
+	ra, rb := a.AllRegions(), b.AllRegions()
+	for i := range ra {
+		var lines []string
+		byType := func(occs []fields.Field) (map[string][]string, []string) {
+			m := make(map[string][]string)
+			var order []string
+			for _, f := range occs {
+				if _, ok := m[f.Name]; !ok {
+					order = append(order, f.Name)
+				}
+				m[f.Name] = append(m[f.Name], strings.Join(strings.Fields(f.Instruction), " "))
+			}
+			return m, order
+		}
+		ma, order := byType(ra[i].Occurrences)
+		mb, orderB := byType(rb[i].Occurrences)
+		for _, name := range orderB {
+			if _, ok := ma[name]; !ok {
+				order = append(order, name)
+			}
+		}
+		for _, name := range order {
+			ia, ib := ma[name], mb[name]
+			for j := 0; j < len(ia) || j < len(ib); j++ {
+				switch {
+				case j >= len(ib):
+					lines = append(lines, fmt.Sprintf("  - %s: %s", name, ia[j]))
+				case j >= len(ia):
+					lines = append(lines, fmt.Sprintf("  + %s: %s", name, ib[j]))
+				case ia[j] != ib[j]:
+					lines = append(lines, fmt.Sprintf("  ~ %s: %s -> %s", name, ia[j], ib[j]))
+				}
+			}
+		}
+		if len(lines) > 0 {
+			same = false
+			fmt.Fprintf(w, "%s fields:\n%s\n", ra[i].Name, strings.Join(lines, "\n"))
+		}
+	}
+	return same
+}
+
+// diffMeta prints the document properties, custom properties and Macros that differ between a and b, returning false if there are any
+func diffMeta(w io.Writer, a, b *fields.Report) bool {
+	pa, pb := propertyList(a.Properties), propertyList(b.Properties)
+	pa = append(pa, labelled{"Macros", fmt.Sprint(a.Macros)})
+	pb = append(pb, labelled{"Macros", fmt.Sprint(b.Macros)})
+	ca, cb := make(map[string]string), make(map[string]string)
+	var names []string // the custom properties of either document, a's first
+	for _, p := range a.CustomProperties {
+		ca[p.Name] = p.Value
+		names = append(names, p.Name)
+	}
+	for _, p := range b.CustomProperties {
+		if _, ok := ca[p.Name]; !ok {
+			names = append(names, p.Name)
+		}
+		cb[p.Name] = p.Value
+	}
+	for _, name := range names {
+		pa, pb = append(pa, labelled{"Custom property " + name, ca[name]}), append(pb, labelled{"Custom property " + name, cb[name]})
+	}
+	var lines []string
+	for i := range pa {
+		if pa[i].value != pb[i].value {
+			lines = append(lines, fmt.Sprintf("  ~ %s: %q -> %q", pa[i].label, pa[i].value, pb[i].value))
+		}
+	}
+	if len(lines) == 0 {
+		return true
+	}
+	fmt.Fprintf(w, "Document properties:\n%s\n", strings.Join(lines, "\n"))
+	return false
+}
@@ -14,176 +14,695 @@
 
 // Check file information block in word docs for presence for fields (gives raw byte size of field information)
 // Examples:
-//    ./doctool test.doc
+//
+//	./doctool test.doc
 package main
 
 import (
-	"encoding/binary"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"strings"
 
-	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/siegfried"
+	"github.com/ross-spencer/doctool/fields"
 )
 
-const (
-	UNSET int = iota
-	TAB0
-	TAB1
-)
+var types listFlag
+
+func init() {
+	flag.BoolVar(recursive, "recursive", false, "the same as -r")
+	flag.IntVar(workers, "workers", runtime.NumCPU(), "the same as -j")
+	flag.Var(&types, "type", "only report (and count) fields of this type, matched ignoring case and spaces (e.g. INCLUDETEXT); repeat for more types")
+	flag.Var(&types, "fields", "only report (and count) fields of these types, a comma separated list matched ignoring case and spaces (e.g. MergeField,IncludeText,DdeAuto); files with none of them exit with status 1")
+}
 
 var (
-	ErrNoFields error = errors.New("No fields")
-	ErrFibShort error = errors.New("file information block too short")
-	ErrTable    error = errors.New("cannot find table stream")
+	matchOnly    = flag.Bool("match-only", false, "with -type, -fields, -profile-set or -external, leave out files that have none of the selected fields")
+	basename     = flag.Bool("basename", false, "print the file name without its directory or extension as the header for each file")
+	archive      = flag.String("archive", "", "process the word doc (.doc, .docx, .docm and .rtf) members of a zip, tar or gzipped tar (.tar.gz or .tgz) archive without unpacking it, reporting each as archive!member")
+	matrix       = flag.Bool("matrix", false, "print a CSV table with a row for each file and a column for each field type seen, giving the count of that field")
+	metadata     = flag.Bool("metadata", false, "report the creating and last modifying applications recorded in the document")
+	meta         = flag.Bool("meta", false, "report the document properties: title, subject, author, last saved by, creation and last saved times, application, template and company, and any custom (user-defined) properties")
+	assoc        = flag.Bool("assoc", false, "report the attached template, mail merge data source and header document, and the author strings recorded in the SttbfAssoc of each .doc")
+	savedBy      = flag.Bool("savedby", false, "list the save history (SttbSavedBy) of each .doc: the author and path of each of the last few saves, the most recent last")
+	bookmarks    = flag.Bool("bookmarks", false, "list the bookmarks in each .doc, with the region and character positions (CPs) of the text each covers")
+	crossRefs    = flag.Bool("crossrefs", false, "list the cross-reference fields (REF, PAGEREF and NOTEREF) in each .doc with the bookmark each refers to, flagging those whose bookmark is missing, which show \"Error! Reference source not found.\" when updated")
+	comments     = flag.Bool("comments", false, "list the comments in each .doc, with the name and initials of the reviewer who made them")
+	mailMerge    = flag.Bool("mailmerge", false, "report whether each document is a mail merge main document, the merge fields it uses, and its data source, connection string and query")
+	hyperlinks   = flag.Bool("hyperlinks", false, "list the URL or path that each HYPERLINK field goes to, from its instruction text and from the hyperlink data Word stores with it")
+	objects      = flag.Bool("objects", false, "list the embedded OLE objects (the ObjectPool) in each .doc, with their classes and, for packaged files, the names of the files")
+	pictures     = flag.Bool("pictures", false, "list the pictures in the text of each .doc, with the format (EMF, WMF, PICT, JPEG, PNG etc.) and size of each stored image, and whether it is linked to a file or is the result of an INCLUDEPICTURE field")
+	layoutRisk   = flag.Bool("layoutrisk", false, "list the fields that draw or move text (EQ, SYMBOL and ADVANCE), with what each draws or moves (e.g. a symbol's font and character code), as they render nothing, or the wrong thing, when converted badly")
+	controlsFlag = flag.Bool("controls", false, "count and list the ActiveX controls embedded in each .doc (e.g. Forms 2.0 buttons and text boxes, media players), with the CLSID, class and name of each")
+	dot          = flag.Bool("dot", false, "for a template (.dot), report its AutoText entries with the fields in each, its command customisations (key assignments, menu and toolbar commands that run macros, and customised toolbars) and whether it has macros")
+	protection   = flag.Bool("protection", false, "report how each .doc is protected against editing (for forms, comments or tracked changes, or read-only recommended) and, if it is protected for forms, list its form fields with their names (and bookmarks) and default values")
+	lang         = flag.Bool("lang", false, "report the languages of each .doc: how much of the text is in each language, the language of the Normal style, and the install language of the copy of Word that saved it")
+	revisions    = flag.Bool("revisions", false, "report whether each .doc has tracked changes that haven't been accepted or rejected, and who made them")
+	profSet      = flag.String("profile-set", "", "only report fields in a named set: security, links, forms, merge, or a set defined with -profile-file")
+	policyPath   = flag.String("policy", "", "evaluate each file against the rules in this YAML policy file, which give a pass, warn or fail verdict for field types, macros, encryption and property values; exit with status 6 if any file fails")
+	profFile     = flag.String("profile-file", "", "file of extra profile sets, one per line as: name = FIELD, FIELD, ...")
+	fastSave     = flag.Bool("fastsave", false, "report whether each .doc was fast saved (fComplex), which can leave deleted text in the file, with the number of consecutive fast saves and of pieces of text in its piece table")
+	flags        = flag.Bool("flags", false, "print the flags set in the file information block (fDot, fComplex, fEncrypted etc.)")
+	wordVersion  = flag.Bool("word-version", false, "report the version of Word that last saved each .doc (Word 6.0 to Word 2007), and whether it is a template")
+	strict       = flag.Bool("strict", false, "stop at the first file that can't be processed, rather than reporting the error and going on to the next")
+	quiet        = flag.Bool("q", false, "quiet: don't write any output or warnings, just exit with the status (see above)")
+	failUnknown  = flag.Bool("fail-on-unknown", false, "exit with status 2 (after listing them) if any document contains field codes missing from the field names table")
+	recursive    = flag.Bool("r", false, "process every file beneath any directory given as an argument")
+	ext          = flag.String("ext", "", "with -r, only process files with this extension, or one of a comma-separated list of them (e.g. .doc,.dot), rather than those with a Word extension (.doc, .dot, .docx, .docm, .dotx, .dotm and .rtf); * processes every file")
+	workers      = flag.Int("j", runtime.NumCPU(), "number of files to process at once")
+	summaryMode  = flag.Bool("summary", false, "instead of reporting each file, report how many files contain each field type (and how often) across all the inputs, and how many couldn't be processed, by error")
+	summaryJSON  = flag.String("summary-json", "", "as well as reporting each file, write the -summary totals to this file as JSON at the end of the run")
+	positions    = flag.Bool("positions", false, "list every field in document order with the character positions (CPs) of its begin and end")
+	instructions = flag.Bool("instructions", false, "list every field in document order with its instruction text (e.g. MERGEFIELD LastName)")
+	fieldResults = flag.Bool("results", false, "list every field in document order with its result text, as Word last rendered it (e.g. the date a DATE field showed), and with -instructions its instruction text too, so the two can be compared")
+	external     = flag.Bool("external", false, "only report fields that pull in external content (INCLUDETEXT, INCLUDEPICTURE, LINK, IMPORT, DDE, DDEAUTO, HYPERLINK), with the path or URL they refer to")
+	triageMode   = flag.Bool("triage", false, "check each document for DDE and DDEAUTO fields, macros, and encryption or obfuscation, and give a risk summary; exit with status 5 if any are found")
+	volatile     = flag.Bool("volatile", false, "classify each field as static, volatile (recalculated when the document is opened or printed, e.g. DATE, FILENAME, PAGE), external or interactive (form fields, buttons and controls), and give each document a preservation-risk score")
+	verbose      = flag.Bool("verbose", false, "also report which table stream was used, and the structure (begin, separator and end characters, and nesting depth) of the fields in each region; FIB details are written to stderr")
+	logV         = flag.Bool("v", false, "log how each document is read to stderr, apart from the report: the FIB, the table stream chosen, where each region's field data is read from, and the parts that were clamped or skipped")
+	logVV        = flag.Bool("vv", false, "like -v, and also log each stream seen, each read of the WordDocument and table streams (offset and length) and each file skipped while walking a directory")
+	logFormat    = flag.String("log", "text", "the format of the -v and -vv log: text (key=value pairs) or json (an object per line)")
+	list         = flag.Bool("list", false, "just list the storages and streams in each file (no field parsing)")
+	slack        = flag.Bool("slack", false, "just report the text in each .doc that isn't part of the document (left behind by fast saves and deletions) with a preview of each run; with -text, print each run of it in full")
+	textOut      = flag.Bool("text", false, "just print the plain text of each .doc (no field parsing), with fields shown by their results, e.g. for full-text indexing")
+	macros       = flag.Bool("macros", false, "report whether each document contains a VBA project (macros)")
+	csvOut       = flag.Bool("csv", false, "output CSV with a row for each file and a column for the fields in each region")
+	lenient      = flag.Bool("lenient", false, "recover what can be read from damaged .doc files: read a FIB cut short by the end of its stream as though the rest were zeros, and read field data that runs past the end of the table stream up to the end (each with a warning), rather than giving up on them")
+	timeout      = flag.Duration("timeout", 0, "the longest a file may take to process, e.g. 30s (0 for no limit): a file that takes longer is abandoned and reported with the status timeout, so a pathological document can't hang a batch run")
+	maxRead      = flag.Int64("max-read", 256, "the most of a document that is read into memory at once, in MiB (0 for no limit): a part of a .doc that is bigger is skipped with a warning, and a bigger RTF document or -archive member isn't read")
+	fromFile     = flag.String("from-file", "", "also read the paths of the files to process from this file (or - for stdin), one per line or separated by NULs (e.g. from find -print0)")
+	sfSig        = flag.String("sf", "", "identify each file with siegfried, using this signature file (e.g. ~/siegfried/default.sig), and skip any that PRONOM says isn't a Word format, reporting its PUID")
+	sqliteOut    = flag.String("sqlite", "", "write the results to tables in this SQLite database (created if need be, and added to if it exists): files, field_occurrences and errors")
+	csvLong      = flag.Bool("long", false, "with -csv, write a row for each field found (file, region, field, error) rather than a row for each file")
+	hashAlg      = flag.String("hash", "sha256", "the algorithm for the checksum given with the size of each file in the -json, -xml, -csv and -sqlite output (md5, sha1, sha256 or sha512), or none to leave both out")
+	outPath      = flag.String("o", "", "write the report to this file instead of stdout")
+	sizes        = flag.Bool("sizes", false, "report the size in bytes of the field data for each region, and the total")
+	raw          = flag.Bool("raw", false, "list every field in document order (e.g. hyperlink, hyperlink, TOC) rather than each field type once, sorted by name, with a count; in -json, keep fields in document order")
+	jsonOut      = flag.Bool("json", false, "output newline-delimited JSON: one object per file, with an \"error\" member if the file couldn't be processed")
+	xmlOut       = flag.Bool("xml", false, "output an XML document with a file element for each file (identification, fields by region, errors and warnings), described by doctool.xsd, e.g. for embedding in METS or PREMIS metadata")
+	pluginPaths  = flag.String("plugin", "", "load analyzers from this Go plugin (.so), or each of a comma-separated list of them, and add the findings of each to the report of every document; see README for writing one")
+	tmplFlag     = flag.String("template", "", "format each file's results with a Go text/template (given inline or as a path to a template file); see README for the available fields")
 )
 
+// out is where reports are written: stdout, or the file given with -o
+var out io.Writer = os.Stdout
+
+// closeOut closes the -o file, if there is one, so that a failed write (e.g. to a full disk) isn't missed, and removes any temporary copy of stdin
+func closeOut() {
+	removeTemp()
+	if f, ok := out.(*os.File); ok && f != os.Stdout {
+		if err := f.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+}
+
+// header returns the name printed before each file's results
+func header(in string) string {
+	if !*basename {
+		return in
+	}
+	base := filepath.Base(in)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Result is the data passed to the -template output, so its exported fields and methods are documented in the README
+type Result struct {
+	Filename string // as printed in the per-file header (so respects -basename)
+	Error    string // empty unless processing failed
+	Size     int64  // the size of the file in bytes, and its checksum in hex (see -hash); 0 and empty with -hash none, or if the file couldn't be read
+	Checksum string
+	fields.Report
+}
+
 func wrapError(e error) error {
-	return errors.New("Error processing file: " + e.Error())
+	return fmt.Errorf("Error processing file: %w", e) // the same wrapping as the fields package uses for its errors
 }
 
-// this bitwise op is necessary because only 5 of the 8 bits of the byte are significant
-func matchField(a, b byte) bool {
-	return a&0x7F == b
+// process reports the fields of the named file, or of a document read from stdin if the name is "-" (see openInput), along with its size and checksum
+func process(ctx context.Context, in string) (*fields.Report, *fixity, error) {
+	return withTimeout(ctx, func(ctx context.Context) (*fields.Report, *fixity, error) {
+		ra, closeInput, err := openInput(in)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer closeInput()
+		var fix *fixity
+		if wantFixity() {
+			if fix, err = checksum(ra); err != nil {
+				return nil, nil, err
+			}
+		}
+		if sf != nil {
+			if err := identify(in, ra); err != nil {
+				return nil, fix, err
+			}
+		}
+		res, err := parse(ctx, in, ra)
+		return res, fix, err
+	})
 }
 
-// process the field data by looking for the start of fields and extracting field names (see fieldnames.go)
-func processField(b []byte) string {
-	var strs []string
-	numDataElements := (len(b) - 4) / 6
-	ignore := numDataElements*4 + 4 // igore the CP section of the field data
-	for i := 0; i < numDataElements; i = i + 2 {
-		if matchField(b[ignore+i], 0x13) { // check if one of the pairs is the start of a field (0x13)
-			strs = append(strs, fieldNames[b[ignore+i+1]])
+// errTimeout is returned for a file that took longer than -timeout to process
+var errTimeout = errors.New("timed out")
+
+// withTimeout runs fn, giving up on it with errTimeout if it takes longer than -timeout (if that is set).
+// fn's context is cancelled then, so an abandoned file stops at its next check of the context (between the parts of the document it reads), in the background.
+// If ctx is cancelled first, its error is returned.
+func withTimeout(ctx context.Context, fn func(context.Context) (*fields.Report, *fixity, error)) (*fields.Report, *fixity, error) {
+	if *timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	type result struct {
+		res *fields.Report
+		fix *fixity
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, fix, err := fn(ctx)
+		done <- result{res, fix, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil && r.err == ctx.Err() && ctx.Err() == context.DeadlineExceeded {
+			return nil, r.fix, wrapError(fmt.Errorf("%w after %s (-timeout)", errTimeout, *timeout))
+		}
+		return r.res, r.fix, r.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, nil, wrapError(fmt.Errorf("%w after %s (-timeout)", errTimeout, *timeout))
 		}
+		return nil, nil, ctx.Err()
 	}
-	return strings.Join(strs, ", ")
 }
 
-func process(in string) error {
-	file, err := os.Open(in)
-	if err != nil {
-		return wrapError(err)
+// parse reports the fields of a document, with the parsing options set by flags (-lenient, -max-read and -results),
+// and logs how it is read to the -v or -vv log, tagged with the name of the file
+func parse(ctx context.Context, name string, ra io.ReaderAt) (*fields.Report, error) {
+	opts := fields.Options{Lenient: *lenient, MaxRead: *maxRead << 20, Results: *fieldResults}
+	if logger != nil {
+		opts.Logger = logger.With("file", name)
 	}
-	defer file.Close()
-	doc, err := mscfb.New(file)
-	if err != nil {
-		return wrapError(err) // not an OLE file?
+	return fields.ParseWithOptions(ctx, ra, opts)
+}
+
+// logger is the -v or -vv log, or nil if neither was given. It writes to stderr so that it never mixes with the report.
+var logger *slog.Logger
+
+// setLogger sets logger from -v, -vv and -log
+func setLogger() error {
+	var h slog.HandlerOptions
+	if *logVV {
+		h.Level = slog.LevelDebug
 	}
-	var table, table1, table0, wordDoc *mscfb.File
-	whichTable := UNSET
-	var fib []byte
-	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() { // iterate through entries of OLE document
-		switch entry.Name {
-		default:
-			continue
-		case "0Table":
-			table0 = entry
-			if whichTable == TAB0 {
-				break
-			}
-		case "1Table":
-			table1 = entry
-			if whichTable == TAB1 {
-				break
-			}
-		case "WordDocument":
-			wordDoc = entry
-			fib = make([]byte, 634)
-			i, _ := wordDoc.Read(fib)
-			if i < 634 {
-				return wrapError(ErrFibShort) // fib is not long enough
-			}
-			byt := fib[11]
-			whichTable = int(byt>>1&1) + 1 // set which table (0Table or 1Table) is the table stream. Do this because a doc can have both but only one will be referenced. It marked by a single bit within the llth byte of the header.
-			if (whichTable == TAB0 && table0 != nil) || (whichTable == TAB1 && table1 != nil) {
-				break
-			}
-		}
+	switch *logFormat {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &h))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, &h))
+	default:
+		return fmt.Errorf("unknown -log format %q: expecting text or json", *logFormat)
 	}
-	// set the table to either 0Table or 1Table stream
-	switch whichTable {
-	case UNSET:
-		return wrapError(ErrTable)
-	case TAB0:
-		if table0 == nil {
-			return wrapError(ErrTable)
-		}
-		table = table0
-	case TAB1:
-		if table1 == nil {
-			return wrapError(ErrTable)
+	if !*logV && !*logVV {
+		logger = nil
+	}
+	return nil
+}
+
+// the exit statuses, from least to most severe (see exitStatus)
+const (
+	exitFields    = 0 // the documents were processed, and at least one has fields (for meta, list, text, fib etc., everything worked)
+	exitNoFields  = 1 // the documents were processed, but none has fields
+	exitFailed    = 2 // a document couldn't be parsed, or something else went wrong
+	exitNotWord   = 3 // a file isn't a Word document
+	exitEncrypted = 4 // a document is encrypted, so couldn't be inspected
+	exitTriage    = 5 // -triage found risk indicators
+	exitPolicy    = 6 // a file failed the -policy
+)
+
+// failed is set if any file couldn't be processed (other than one that isn't a Word document), or something else went wrong, so that doctool exits with exitFailed
+var failed bool
+
+// notWord is set if any file isn't a Word document, so that doctool exits with exitNotWord
+var notWord bool
+
+// encrypted is set if any document is encrypted, so can't be inspected, so that doctool exits with exitEncrypted
+var encrypted bool
+
+// reported is set once a document's fields are reported (rather than, e.g., its streams listed), and found once one of them has fields (after any filtering),
+// so that doctool exits with exitNoFields if none do
+var reported, found bool
+
+// fieldReport is cleared by a command (meta) that reports on documents without listing their fields, so that the exit status doesn't depend on them
+var fieldReport = true
+
+// stopped is set, with -strict, once a file couldn't be processed, so that no more files are started
+var stopped bool
+
+// the status of each file, as given in the JSON and CSV output
+const (
+	statusOK        = "ok"
+	statusNoFields  = "nofields"  // the document was processed, but has no fields
+	statusEncrypted = "encrypted" // the document is encrypted, so couldn't be inspected
+	statusNotWord   = "notword"   // the file isn't a Word document (.doc, .docx, .docm or .rtf)
+	statusError     = "error"     // the file couldn't be processed
+	statusTimeout   = "timeout"   // the file took longer than -timeout to process, so was abandoned
+)
+
+// fileStatus gives the status of a file from the error returned when processing it
+func fileStatus(err error) string {
+	switch {
+	case err == nil:
+		return statusOK
+	case err == fields.ErrNoFields:
+		return statusNoFields
+	case errors.Is(err, fields.ErrEncrypted):
+		return statusEncrypted
+	case errors.Is(err, fields.ErrNotWord), errors.Is(err, fields.ErrNoWordDocument), errors.Is(err, fields.ErrOOXML): // an OLE file without a WordDocument stream is another Office format, e.g. .xls
+		return statusNotWord
+	case errors.Is(err, errTimeout):
+		return statusTimeout
+	}
+	return statusError
+}
+
+// output prints the result of processing a single file (or holds it for the matrix report). fix is nil if the file couldn't be read, or with -hash none.
+func output(name string, fix *fixity, res *fields.Report, err error) {
+	reported = fieldReport
+	switch fileStatus(err) {
+	case statusEncrypted:
+		encrypted = true
+	case statusNotWord:
+		notWord = true
+		stopped = *strict
+	case statusError, statusTimeout:
+		failed = true
+		stopped = *strict
+	}
+	if res != nil && *failUnknown {
+		trackUnknown(name, res.Unknown)
+	}
+	if res != nil && (profile != nil || *external) {
+		filter(res)
+		if *matchOnly && (err == nil || err == fields.ErrNoFields) && len(res.Regions()) == 0 {
+			return
 		}
-		table = table1
 	}
-	// Get offsets (in table stream) and sizes of field data from the FibRgFcLcb97 section of the FIB (which starts 154 bytes in).
-	// All the items in the FibRgFcLcb97 are listed in the fib_bits.txt doc in this repo. They are each 4 bytes long.
-	// You can calculate the relevant offsets by looking at the place of these items in the fib_bits.txt list.
-	mo, mn := binary.LittleEndian.Uint32(fib[282:286]), binary.LittleEndian.Uint32(fib[286:290]) // Interpret the bytes as an unsigned 32-bit integer in little endian order
-	hdro, hdr := binary.LittleEndian.Uint32(fib[290:294]), binary.LittleEndian.Uint32(fib[294:298])
-	ftno, ftn := binary.LittleEndian.Uint32(fib[298:302]), binary.LittleEndian.Uint32(fib[302:306])
-	como, com := binary.LittleEndian.Uint32(fib[306:310]), binary.LittleEndian.Uint32(fib[310:314])
-	endo, end := binary.LittleEndian.Uint32(fib[538:542]), binary.LittleEndian.Uint32(fib[542:546])
-	txbo, txb := binary.LittleEndian.Uint32(fib[618:622]), binary.LittleEndian.Uint32(fib[622:626])
-	htxo, htx := binary.LittleEndian.Uint32(fib[626:630]), binary.LittleEndian.Uint32(fib[630:634])
-	if mn+hdr+ftn+com+end+txb+htx == 0 {
-		return ErrNoFields // no fields
+	if res != nil && len(res.Counts()) > 0 {
+		found = true
 	}
-	tableBuf := make([]byte, int(table.Size)) // read all the Table stream into a byte buffer
-	table.Read(tableBuf)
-	// now for each offset and length pair, process the relevant bytes from the table stream (after checking that don't overflow bounds of that slice)
-	if mn > 0 {
-		if int(mo+mn) <= len(tableBuf) {
-			fmt.Printf("Document body fields: %s\n", processField(tableBuf[int(mo):int(mo+mn)]))
+	if *triageMode {
+		if risk, _ := triage(res, err); risk > riskNone {
+			suspicious = true
 		}
 	}
-	if hdr > 0 {
-		if int(hdro+hdr) <= len(tableBuf) {
-			fmt.Printf("Header/footer fields: %s\n", processField(tableBuf[int(hdro):int(hdro+hdr)]))
+	if filePolicy != nil {
+		if verdict, _ := filePolicy.evaluate(res, err); verdict == verdictFail {
+			policyFailed = true
 		}
 	}
-	if ftn > 0 {
-		if int(ftno+ftn) <= len(tableBuf) {
-			fmt.Printf("Footnote fields: %s\n", processField(tableBuf[int(ftno):int(ftno+ftn)]))
+	if res != nil && *verbose && res.Format == fields.FormatDOC {
+		writeFIBDetails(os.Stderr, name, res)
+	}
+	if res != nil && !*quiet {
+		for _, w := range res.Warnings {
+			fmt.Fprintf(os.Stderr, "%s: warning: %s\n", name, w)
 		}
 	}
-	if com > 0 {
-		if int(como+com) <= len(tableBuf) {
-			fmt.Printf("Comment fields: %s\n", processField(tableBuf[int(como):int(como+com)]))
+	if *summaryJSON != "" {
+		addSummary(res, err)
+	}
+	if *sqliteOut != "" {
+		addDBRow(name, fix, res, err)
+		return
+	}
+	if *matrix {
+		addRow(name, res, err)
+		return
+	}
+	if *summaryMode {
+		if *summaryJSON == "" { // otherwise it has been added already
+			addSummary(res, err)
 		}
+		return
+	}
+	if *jsonOut {
+		writeJSON(out, name, fix, res, err)
+		return
+	}
+	if *xmlOut {
+		writeXML(out, name, fix, res, err)
+		return
 	}
-	if end > 0 {
-		if int(endo+end) <= len(tableBuf) {
-			fmt.Printf("Endnote fields: %s\n", processField(tableBuf[int(endo):int(endo+end)]))
+	if *csvOut {
+		writeCSV(out, name, fix, res, err)
+		return
+	}
+	if tmpl != nil {
+		r := &Result{Filename: header(name)}
+		if fix != nil {
+			r.Size, r.Checksum = fix.Size, fix.Checksum
+		}
+		if res != nil {
+			r.Report = *res
+		}
+		if err != nil {
+			r.Error = err.Error()
 		}
+		if err := executeTemplate(out, r); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return
+	}
+	writeText(out, name, res, err)
+}
+
+// filter leaves only the fields selected with -profile-set, -type, -fields and -external in a report
+func filter(res *fields.Report) {
+	if profile != nil {
+		applyProfile(res)
 	}
-	if txb > 0 {
-		if int(txbo+txb) <= len(tableBuf) {
-			fmt.Printf("Textbox fields: %s\n", processField(tableBuf[int(txbo):int(txbo+txb)]))
+	if *external {
+		res.Filter(func(f fields.Field) bool {
+			_, ok := f.External()
+			return ok
+		})
+	}
+}
+
+type job struct {
+	res *fields.Report
+	fix *fixity
+	err error
+}
+
+// processAll processes the inputs with a pool of n workers.
+// Results are output from this goroutine only, in the order the files were given, so output never interleaves and is the same whatever n is.
+// A failing file is reported in its turn and doesn't stop the other workers.
+func processAll(ctx context.Context, ins []string, n int) {
+	if n < 1 {
+		n = 1
+	}
+	ctx, cancel := context.WithCancel(ctx) // to stop the workers if -strict stops the run
+	defer cancel()
+	results := make([]chan job, len(ins))
+	for i := range results {
+		results[i] = make(chan job, 1)
+	}
+	next := make(chan int)
+	go func() {
+		for i := range ins {
+			next <- i
 		}
+		close(next)
+	}()
+	for w := 0; w < n; w++ {
+		go func() {
+			for i := range next {
+				if err := ctx.Err(); err != nil { // don't start any more files once interrupted
+					results[i] <- job{nil, nil, err}
+					continue
+				}
+				res, fix, err := process(ctx, ins[i])
+				results[i] <- job{res, fix, err}
+			}
+		}()
 	}
-	if htx > 0 {
-		if int(htxo+htx) <= len(tableBuf) {
-			fmt.Printf("Header/footer textbox fields: %s\n", processField(tableBuf[int(htxo):int(htxo+htx)]))
+	for i, in := range ins {
+		j := <-results[i]
+		if j.err != nil && j.err == ctx.Err() {
+			fmt.Fprintf(os.Stderr, "Interrupted: %d of %d files processed\n", i, len(ins))
+			failed = true
+			return
+		}
+		output(in, j.fix, j.res, j.err)
+		if stopped {
+			fmt.Fprintf(os.Stderr, "Stopped at the first error (-strict): %d of %d files processed\n", i+1, len(ins))
+			return
 		}
 	}
-	return nil
+}
+
+// fatal reports a problem that stops doctool before it processes any files (e.g. a bad flag value), and exits with exitFailed
+func fatal(v ...interface{}) {
+	log.Println(v...)
+	os.Exit(exitFailed)
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: doctool [flags] [command] [flags] file ...
+
+Commands (without one, doctool reports the fields of each file, as the fields command does; doctool command -h lists the command's flags):
+%s
+Each command takes the global flags (-%s) and its own, after its name, e.g. doctool triage -json -r incoming/.
+The commands' own flags can also be given before the command, or instead of it, e.g. doctool -text file is doctool text file.
+
+Use - as a file name to read a document from stdin, with any of the commands (it is read into memory, or if it is
+over 64 MiB into a temporary file, as it needs random access).
+
+Exit status (when more than one applies, the highest in this list is used):
+  2  one or more files couldn't be parsed (with -strict, at the first), -fail-on-unknown found unknown field codes, or the run was interrupted
+  3  one or more files aren't Word documents (.doc, .docx, .docm or .rtf)
+  4  one or more documents are encrypted, so couldn't be inspected
+  5  -triage found risk indicators in one or more files
+  6  one or more files failed the -policy
+  1  every file was processed, but none has fields (of the types selected by -profile-set, -type, -fields or -external)
+  0  every file was processed, and at least one has fields (for meta, list, text, slack, fib and streams: everything worked)
+Use -q to use doctool as a test, e.g. if doctool -q letter.doc; then ... (the letter has fields)
+
+Flags:
+`, commandUsage(), strings.Join(globalFlags, ", -"))
+	flag.PrintDefaults()
 }
 
 func main() {
-	flag.Parse() // we don't actually use any flags in this script, I just copied this from another file (comdump)! Left in in case add flags in future
-	ins := flag.Args()
-	if len(ins) < 1 {
-		log.Fatalln("Missing required argument: path to a word document")
-	}
-	for _, in := range ins { // you can process a bunch of files at once by using: ./doctool doc1.doc doc2.doc doc3.doc etc.
-		fmt.Println(in) // print the file name
-		err := process(in)
+	flag.Usage = usage
+	flag.Parse()
+	args := flag.Args()
+	var cmd string
+	if c := findCommand(flag.Arg(0)); c != nil { // a file with the same name as a command can be given as ./name
+		cmd, args = c.name, c.parse(args[1:])
+		fieldReport = !c.noFields
+	}
+	if *pluginPaths != "" {
+		if err := loadPlugins(*pluginPaths); err != nil {
+			fatal(err)
+		}
+	}
+	if *tmplFlag != "" {
+		if err := parseTemplate(*tmplFlag); err != nil {
+			fatal(err)
+		}
+	}
+	if *profFile != "" {
+		if err := loadProfiles(*profFile); err != nil {
+			fatal(err)
+		}
+	}
+	if *policyPath != "" {
+		var err error
+		if filePolicy, err = loadPolicy(*policyPath); err != nil {
+			fatal(err)
+		}
+	}
+	if *profSet != "" {
+		if err := setProfile(*profSet); err != nil {
+			fatal(err)
+		}
+	}
+	if len(types) > 0 {
+		addTypes(types)
+	}
+	if *quiet {
+		out = io.Discard
+	} else if *outPath != "" {
+		f, err := os.Create(*outPath)
 		if err != nil {
-			fmt.Println(err)
+			fatal(err)
+		}
+		out = f
+	}
+	if err := setLogger(); err != nil {
+		fatal(err)
+	}
+	if *hashAlg != "none" && hashes[*hashAlg] == nil {
+		fatal(fmt.Sprintf("unknown -hash algorithm %q: expecting md5, sha1, sha256, sha512 or none", *hashAlg))
+	}
+	if *sfSig != "" {
+		var err error
+		if sf, err = siegfried.Load(*sfSig); err != nil {
+			fatal(err)
+		}
+	}
+	switch cmd {
+	case "fib", "streams", "bench":
+		ins := inputs(args)
+		if len(args) < 1 && *fromFile == "" {
+			fatal("Missing required argument: path to a word document")
+		}
+		switch cmd {
+		case "fib":
+			fibCommand(ins)
+		case "streams":
+			streamsCommand(ins)
+		default:
+			benchCommand(expand(ins))
+		}
+		closeOut()
+		os.Exit(exitStatus())
+	case "fields":
+		if len(args) == 1 && args[0] == "list" {
+			if err := fieldsListCommand(); err != nil {
+				fatal(err)
+			}
+			closeOut()
+			os.Exit(0)
+		}
+	case "diff":
+		status := diffCommand(args)
+		closeOut()
+		os.Exit(status)
+	case "serve":
+		if err := serveCommand(); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *sqliteOut != "" {
+		if err := openDB(*sqliteOut); err != nil {
+			fatal(err)
+		}
+	}
+	if cmd == "watch" {
+		if err := watchCommand(args); err != nil {
+			fatal(err)
+		}
+		flushCSV()
+		if *xmlOut {
+			closeXML(out)
+		}
+		if *sqliteOut != "" {
+			closeDB()
+		}
+		if *summaryJSON != "" {
+			writeSummaryFile(*summaryJSON)
+		}
+		closeOut()
+		os.Exit(exitStatus())
+	}
+	ins := expand(inputs(args))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt) // Ctrl-C stops the batch after reporting the files already done
+	go func() {
+		<-ctx.Done()
+		stop() // a second Ctrl-C kills the process as usual
+	}()
+	if *archive != "" {
+		if err := processArchive(ctx, *archive); err != nil && err == ctx.Err() {
+			fmt.Fprintf(os.Stderr, "Interrupted while processing %s\n", *archive)
+			failed = true
+		} else if err != nil {
+			fmt.Fprintln(out, err)
+			failed = true
+		}
+	} else if len(args) < 1 && *fromFile == "" {
+		fatal("Missing required argument: path to a word document")
+	}
+	if *list {
+		for _, in := range ins {
+			fmt.Fprintln(out, header(in))
+			if err := listEntries(out, in); err != nil {
+				fmt.Fprintln(out, err)
+				failed = true
+			}
 		}
+		closeOut()
+		os.Exit(exitStatus())
+	}
+	if *slack {
+		for _, in := range ins {
+			fmt.Fprintln(out, header(in))
+			if err := writeSlack(out, in, *textOut); err != nil {
+				fmt.Fprintln(out, err)
+				if errors.Is(err, fields.ErrEncrypted) {
+					encrypted = true
+				} else {
+					failed = true
+				}
+			}
+		}
+		closeOut()
+		os.Exit(exitStatus())
+	}
+	if *textOut {
+		for _, in := range ins {
+			fmt.Fprintln(out, header(in))
+			if err := writeDocText(out, in); err != nil {
+				fmt.Fprintln(out, err)
+				if errors.Is(err, fields.ErrEncrypted) {
+					encrypted = true
+				} else {
+					failed = true
+				}
+			}
+		}
+		closeOut()
+		os.Exit(exitStatus())
+	}
+	if !stopped { // -strict may have stopped the run in the archive
+		processAll(ctx, ins, *workers) // you can process a bunch of files at once by using: ./doctool doc1.doc doc2.doc doc3.doc etc.
+	}
+	if *matrix {
+		writeMatrix(out)
+	}
+	if *summaryMode {
+		writeSummary(out)
+	}
+	if *summaryJSON != "" {
+		writeSummaryFile(*summaryJSON)
+	}
+	if *csvOut {
+		flushCSV()
+	}
+	if *xmlOut {
+		closeXML(out)
+	}
+	if *sqliteOut != "" {
+		closeDB()
+	}
+	if *failUnknown && reportUnknown(os.Stderr) {
+		failed = true
+	}
+	closeOut()
+	os.Exit(exitStatus())
+}
+
+func exitStatus() int {
+	switch {
+	case failed:
+		return exitFailed
+	case notWord:
+		return exitNotWord
+	case encrypted:
+		return exitEncrypted
+	case suspicious:
+		return exitTriage
+	case policyFailed:
+		return exitPolicy
+	case reported && !found:
+		return exitNoFields
 	}
+	return exitFields
 }
@@ -0,0 +1,123 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+//go:embed fib_bits.txt
+var fibBits string
+
+// fcLcbNames are the names of the FibRgFcLcb pairs, in order, from fib_bits.txt (which lists the fc then the lcb of each pair, one per line, with optional notes after the name)
+var fcLcbNames = func() []string {
+	var names []string
+	for _, line := range strings.Split(fibBits, "\n") {
+		if f := strings.Fields(line); len(f) > 0 {
+			names = append(names, f[0])
+		}
+	}
+	return names
+}()
+
+// rgLwNames are the names of the 32-bit values in the FibRgLw97
+var rgLwNames = []string{"cbMac", "reserved1", "reserved2", "ccpText", "ccpFtn", "ccpHdd", "reserved3", "ccpAtn", "ccpEdn", "ccpTxbx", "ccpHdrTxbx",
+	"reserved4", "reserved5", "reserved6", "reserved7", "reserved8", "reserved9", "reserved10", "reserved11", "reserved12", "reserved13", "reserved14"}
+
+// fibCommand runs `doctool fib file ...`, which prints every part of the FIB of each file
+func fibCommand(ins []string) {
+	for _, in := range ins {
+		fmt.Fprintln(out, header(in))
+		if err := dumpFIB(out, in); err != nil {
+			fmt.Fprintln(out, err)
+			failed = true
+		}
+	}
+}
+
+// dumpFIB decodes and prints the whole FIB of a .doc: the FibBase, FibRgW97, FibRgLw97, every fc/lcb pair in the FibRgFcLcb and the FibRgCswNew.
+// Useful for checking doctool's reading of a document against the spec, or for looking at parts of the FIB that doctool doesn't otherwise use.
+func dumpFIB(w io.Writer, in string) error {
+	file, closeInput, err := openInput(in)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+	fib, err := fields.ReadFIB(file)
+	if err != nil {
+		return err
+	}
+	u16 := func(o int) uint16 { return binary.LittleEndian.Uint16(fib.Base[o:]) }
+	nFib := u16(2)
+	fmt.Fprintln(w, "FibBase:")
+	fmt.Fprintf(w, "  wIdent     0x%04X\n", u16(0))
+	fmt.Fprintf(w, "  nFib       0x%04X (%s)\n", nFib, fields.Version(nFib))
+	fmt.Fprintf(w, "  unused     0x%04X\n", u16(4))
+	fmt.Fprintf(w, "  lid        0x%04X\n", u16(6))
+	fmt.Fprintf(w, "  pnNext     0x%04X\n", u16(8))
+	fmt.Fprintf(w, "  flags      0x%04X (%s)\n", u16(10), fib.Flags)
+	fmt.Fprintf(w, "  nFibBack   0x%04X\n", u16(12))
+	fmt.Fprintf(w, "  lKey       0x%08X\n", binary.LittleEndian.Uint32(fib.Base[14:]))
+	fmt.Fprintf(w, "  envr       0x%02X\n", fib.Base[18])
+	fmt.Fprintf(w, "  flags2     0x%02X\n", fib.Base[19])
+	fmt.Fprintf(w, "  reserved3  0x%04X\n", u16(20))
+	fmt.Fprintf(w, "  reserved4  0x%04X\n", u16(22))
+	fmt.Fprintf(w, "  reserved5  0x%08X\n", binary.LittleEndian.Uint32(fib.Base[24:]))
+	fmt.Fprintf(w, "  reserved6  0x%08X\n", binary.LittleEndian.Uint32(fib.Base[28:]))
+	if fib.RgW != nil {
+		fmt.Fprintf(w, "FibRgW97 (csw %d):\n", len(fib.RgW))
+		for i, v := range fib.RgW {
+			fmt.Fprintf(w, "  %2d  0x%04X\n", i, v)
+		}
+	}
+	if fib.RgLw != nil {
+		fmt.Fprintf(w, "FibRgLw97 (cslw %d):\n", len(fib.RgLw))
+		for i, v := range fib.RgLw {
+			name := fmt.Sprintf("[%d]", i)
+			if i < len(rgLwNames) {
+				name = rgLwNames[i]
+			}
+			fmt.Fprintf(w, "  %-11s %d\n", name, v)
+		}
+	}
+	fmt.Fprintf(w, "FibRgFcLcb (%d pairs):\n", len(fib.FcLcb))
+	for i, p := range fib.FcLcb {
+		fc, lcb := fmt.Sprintf("pair %d fc", i), fmt.Sprintf("pair %d lcb", i)
+		if i*2+1 < len(fcLcbNames) {
+			fc, lcb = fcLcbNames[i*2], fcLcbNames[i*2+1]
+		}
+		fmt.Fprintf(w, "  %2d  %-26s %10d  %-26s %10d\n", i, fc, p.Fc, lcb, p.Lcb)
+	}
+	if fib.RgCswNew != nil {
+		fmt.Fprintf(w, "FibRgCswNew (cswNew %d):\n", len(fib.RgCswNew))
+		for i, v := range fib.RgCswNew {
+			if i == 0 {
+				fmt.Fprintf(w, "  nFibNew     0x%04X (%s)\n", v, fields.Version(v))
+				continue
+			}
+			fmt.Fprintf(w, "  [%d]         0x%04X\n", i, v)
+		}
+	}
+	if fib.Short {
+		fmt.Fprintln(w, "The WordDocument stream ends part way through the FIB")
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// Analyzer is a custom check on a document, such as looking for an organisation's own DOCPROPERTY names or classification markings,
+// that adds a section of findings to the report without doctool having to know about it. Analyzers are registered with RegisterAnalyzer
+// (usually from an init function), and every one registered is run over each document that ParseWithOptions reads.
+type Analyzer interface {
+	Analyze(doc *Document) (Findings, error)
+}
+
+// Document is what an Analyzer is given: the report made of a document, and the parts it was read from.
+// The streams are as they are in the file: they aren't limited by Options.MaxRead, and an encrypted document's table stream is left out.
+type Document struct {
+	Report *Report
+	File   io.ReaderAt // the whole file, e.g. for reading its text with Text
+	// for a .doc only (nil otherwise): the entries of the compound file (its storages and streams, in the order of its directory),
+	// the WordDocument stream and the FIB at its start, and the table stream used (see Report.Table; the WordDocument stream for Word 6.0 and Word 95 documents)
+	Entries      []*mscfb.File
+	WordDocument *mscfb.File
+	FIB          []byte
+	Table        *mscfb.File
+}
+
+// Finding is one thing an Analyzer found
+type Finding struct {
+	Message string
+	Region  string // the key of the region it was found in (body, header etc.), if it is in the text
+	CP      uint32 // where it is in that region, if Region is set
+}
+
+// Findings are an Analyzer's section of the report
+type Findings []Finding
+
+// the registered analyzers, by name
+var (
+	analyzersMu sync.RWMutex
+	analyzers   = make(map[string]Analyzer)
+)
+
+// RegisterAnalyzer adds an Analyzer, under a name that keys its findings in Report.Findings. It panics if the name is empty or already taken.
+func RegisterAnalyzer(name string, a Analyzer) {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	if name == "" || a == nil {
+		panic("fields: RegisterAnalyzer needs a name and an analyzer")
+	}
+	if _, ok := analyzers[name]; ok {
+		panic("fields: RegisterAnalyzer called twice for " + name)
+	}
+	analyzers[name] = a
+}
+
+// Analyzers returns the names of the registered analyzers, sorted
+func Analyzers() []string {
+	analyzersMu.RLock()
+	defer analyzersMu.RUnlock()
+	names := make([]string, 0, len(analyzers))
+	for name := range analyzers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// analyze runs the registered analyzers over the document, in the order of their names, setting the report's Findings.
+// An analyzer that fails (or panics) gives a warning instead, and the next is run.
+func (d *Report) analyze(doc *Document) {
+	names := Analyzers()
+	if len(names) == 0 {
+		return
+	}
+	doc.Report = d
+	d.Findings = make(map[string]Findings)
+	for _, name := range names {
+		analyzersMu.RLock()
+		a := analyzers[name]
+		analyzersMu.RUnlock()
+		findings, err := runAnalyzer(a, doc)
+		if err != nil {
+			d.Warnings = append(d.Warnings, fmt.Sprintf("the %s analyzer failed: %v", name, err))
+			continue
+		}
+		if findings == nil {
+			findings = Findings{} // so that an analyzer that found nothing is still reported
+		}
+		d.Findings[name] = findings
+	}
+}
+
+// runAnalyzer runs an analyzer, turning a panic into an error so that one bad analyzer doesn't stop a batch run
+func runAnalyzer(a Analyzer, doc *Document) (findings Findings, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			findings, err = nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return a.Analyze(doc)
+}
@@ -0,0 +1,68 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchFixtures are the fixtures that parse, so the benchmarks measure the whole of Parse rather than an early return
+var benchFixtures = []string{"headerfooter.doc", "hyperlink.doc", "nested.doc"}
+
+// readFixture reads a fixture into memory, so the benchmarks don't measure the file system
+func readFixture(b *testing.B, name string) *bytes.Reader {
+	b.Helper()
+	buf, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return bytes.NewReader(buf)
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, name := range benchFixtures {
+		b.Run(name, func(b *testing.B) {
+			ra := readFixture(b, name)
+			b.SetBytes(ra.Size())
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := Parse(ra); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkText(b *testing.B) {
+	for _, name := range benchFixtures {
+		b.Run(name, func(b *testing.B) {
+			ra := readFixture(b, name)
+			b.SetBytes(ra.Size())
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := Text(ra, io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
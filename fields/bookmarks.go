@@ -0,0 +1,86 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errBookmarks = errors.New("malformed bookmark tables")
+
+// Bookmark is a named range of the document's text. REF, PAGEREF and NOTEREF fields (and HYPERLINK \l) refer to bookmarks by name.
+// Bookmarks Word adds for its own use, e.g. the targets of cross-references (_Ref) and tables of contents (_Toc), have names starting with an underscore.
+type Bookmark struct {
+	Name   string
+	Region string // the key of the region the bookmark starts in (body, header etc.), or empty if it can't be told
+	// the character positions of the start and end of the bookmark, relative to the start of the region (as for Field CPs). The end is exclusive.
+	Start, End uint32
+}
+
+// readBookmarks reads the bookmark names from the SttbfBkmk (pair 21 of the FibRgFcLcb), and the start and end of each from the PlcfBkf (pair 22) and PlcfBkl (pair 23).
+// The PlcfBkf has a CP for each bookmark followed by an FBKF for each: the index in the PlcfBkl of its end (ibkl) and 2 bytes of flags. The PlcfBkl has just the CPs.
+// counts are the lengths of the parts of the document's text (see ccps), used to tell which part each bookmark is in; if nil, the CPs are left as they are, relative to the start of the document.
+func readBookmarks(table io.ReaderAt, tableSize int64, fcLcb []byte, counts []uint32) ([]Bookmark, error) {
+	sttb, err := readTableData(table, tableSize, fcLcb, 21)
+	if err != nil || sttb == nil {
+		return nil, err
+	}
+	names, _, err := readSttb(sttb, 0)
+	if err != nil {
+		return nil, err
+	}
+	bkf, err := readTableData(table, tableSize, fcLcb, 22)
+	if err != nil {
+		return nil, err
+	}
+	bkl, err := readTableData(table, tableSize, fcLcb, 23)
+	if err != nil {
+		return nil, err
+	}
+	n := (len(bkf) - 4) / 8
+	if n < len(names) || len(bkl) < 4 {
+		return nil, errBookmarks
+	}
+	nl := len(bkl)/4 - 1
+	marks := make([]Bookmark, len(names))
+	for i, name := range names {
+		start := binary.LittleEndian.Uint32(bkf[i*4:])
+		ibkl := int(binary.LittleEndian.Uint16(bkf[(n+1)*4+i*4:]))
+		end := start
+		if ibkl < nl && binary.LittleEndian.Uint32(bkl[ibkl*4:]) >= start { // a damaged end is left at the start, so the bookmark is empty
+			end = binary.LittleEndian.Uint32(bkl[ibkl*4:])
+		}
+		marks[i] = Bookmark{Name: name, Start: start, End: end}
+		if counts == nil {
+			continue
+		}
+		var base uint32
+		for t, c := range counts {
+			if start < base+c || t == len(counts)-1 {
+				for _, fr := range fieldRegions {
+					if fr.text == t {
+						marks[i].Region = fr.key
+					}
+				}
+				marks[i].Start, marks[i].End = start-base, end-base
+				break
+			}
+			base += c
+		}
+	}
+	return marks, nil
+}
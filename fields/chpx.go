@@ -0,0 +1,89 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errChpx = errors.New("malformed character formatting tables")
+
+// operandSize returns the size of a sprm's operand, which is given by its spra (top 3 bits); a spra of 6 means the operand starts with its size.
+// sprmTDefTable (0xD608), whose size is 16 bits, can't appear in character formatting, which is all that is read here.
+func operandSize(sprm uint16, grpprl []byte) int {
+	switch sprm >> 13 {
+	case 0, 1:
+		return 1
+	case 2, 4, 5:
+		return 2
+	case 3:
+		return 4
+	case 7:
+		return 3
+	}
+	if len(grpprl) == 0 {
+		return 0
+	}
+	return 1 + int(grpprl[0])
+}
+
+// forSprms calls fn with each sprm in a grpprl (a list of sprms, each a 16-bit sprm followed by its operand), stopping at the first whose operand is cut short
+func forSprms(grpprl []byte, fn func(sprm uint16, operand []byte)) {
+	for k := 0; k+2 <= len(grpprl); {
+		sprm := binary.LittleEndian.Uint16(grpprl[k:])
+		k += 2
+		size := operandSize(sprm, grpprl[k:])
+		if k+size > len(grpprl) {
+			return
+		}
+		fn(sprm, grpprl[k:k+size])
+		k += size
+	}
+}
+
+// scanChpx calls fn with the FCs that bound each run of the document's text that has character formatting, along with the run's grpprl.
+// The character formatting is in ChpxFkps: 512-byte pages of the WordDocument stream listed in the PlcBteChpx (pair 12 of the FibRgFcLcb), which has n+1 FCs and then the page number (the low 22 bits) of each of n pages.
+// Each page has crun+1 FCs (crun is its last byte) that bound its runs of text, then a byte per run giving the offset (in 16-bit words) of the run's Chpx: a size byte and the grpprl.
+func scanChpx(doc io.ReaderAt, docSize int64, bte []byte, fn func(fcStart, fcEnd uint32, grpprl []byte)) error {
+	n := (len(bte) - 4) / 8
+	page := make([]byte, 512)
+	for i := 0; i < n; i++ {
+		pn := binary.LittleEndian.Uint32(bte[(n+1)*4+i*4:]) & 0x3FFFFF
+		if int64(pn+1)*512 > docSize {
+			return errChpx
+		}
+		if _, err := doc.ReadAt(page, int64(pn)*512); err != nil && err != io.EOF {
+			return err
+		}
+		crun := int(page[511])
+		if (crun+1)*4+crun > 511 {
+			return errChpx
+		}
+		for j := 0; j < crun; j++ {
+			off := int(page[(crun+1)*4+j]) * 2
+			if off == 0 {
+				continue
+			}
+			cb := int(page[off])
+			if off+1+cb > 511 {
+				return errChpx
+			}
+			fn(binary.LittleEndian.Uint32(page[j*4:]), binary.LittleEndian.Uint32(page[j*4+4:]), page[off+1:off+1+cb])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+// Class is how a field's result behaves once the document is saved: whether it stays as it is, is recalculated by Word, depends on content outside the document, or is set by the reader
+type Class int
+
+const (
+	Static      Class = iota // the result only changes if someone edits the document
+	Volatile                 // Word recalculates the result when the document is opened, printed or repaginated (e.g. DATE, FILENAME, PAGE)
+	External                 // the result is pulled in from another file or application (see Field.External)
+	Interactive              // the field is filled in or clicked by the reader (form fields, buttons and controls)
+)
+
+func (c Class) String() string {
+	switch c {
+	case Volatile:
+		return "volatile"
+	case External:
+		return "external"
+	case Interactive:
+		return "interactive"
+	}
+	return "static"
+}
+
+// Class classifies the field as static, volatile, external or interactive. External fields (which are also recalculated when updated) take precedence.
+func (f Field) Class() Class {
+	if _, ok := f.External(); ok {
+		return External
+	}
+	if t, ok := f.Type(); ok && (t.Class == Volatile || t.Class == Interactive) { // the types whose result depends on when, where, by whom or on what the document is opened or printed, or on what the reader does, rather than on its content
+		return t.Class
+	}
+	return Static
+}
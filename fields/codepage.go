@@ -0,0 +1,151 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// the Windows (ANSI) code pages of the languages whose text isn't in Windows-1252, by primary language (the low 10 bits of a LID)
+var languageCodePages = map[uint16]uint16{
+	0x01: 1256, // Arabic
+	0x02: 1251, // Bulgarian
+	0x04: 936,  // Chinese, unless it is one of the traditional Chinese languages below
+	0x05: 1250, // Czech
+	0x08: 1253, // Greek
+	0x0D: 1255, // Hebrew
+	0x0E: 1250, // Hungarian
+	0x11: 932,  // Japanese
+	0x12: 949,  // Korean
+	0x15: 1250, // Polish
+	0x18: 1250, // Romanian
+	0x19: 1251, // Russian
+	0x1A: 1250, // Croatian, and Serbian and Bosnian in Latin script
+	0x1B: 1250, // Slovak
+	0x1C: 1250, // Albanian
+	0x1E: 874,  // Thai
+	0x1F: 1254, // Turkish
+	0x20: 1256, // Urdu
+	0x22: 1251, // Ukrainian
+	0x23: 1251, // Belarusian
+	0x24: 1250, // Slovenian
+	0x25: 1257, // Estonian
+	0x26: 1257, // Latvian
+	0x27: 1257, // Lithuanian
+	0x29: 1256, // Persian
+	0x2A: 1258, // Vietnamese
+	0x2C: 1254, // Azerbaijani in Latin script
+	0x2F: 1251, // Macedonian
+	0x3F: 1251, // Kazakh
+	0x40: 1251, // Kyrgyz
+	0x43: 1254, // Uzbek in Latin script
+	0x44: 1251, // Tatar
+	0x50: 1251, // Mongolian
+}
+
+// the LIDs whose code page differs from that of their primary language: traditional Chinese, and languages in Cyrillic script rather than Latin
+var lidCodePages = map[uint16]uint16{
+	0x0404: 950,  // Chinese (Taiwan)
+	0x0C04: 950,  // Chinese (Hong Kong)
+	0x1404: 950,  // Chinese (Macao)
+	0x0C1A: 1251, // Serbian (Cyrillic)
+	0x201A: 1251, // Bosnian (Cyrillic)
+	0x082C: 1251, // Azerbaijani (Cyrillic)
+	0x0843: 1251, // Uzbek (Cyrillic)
+}
+
+// the decoders for the code pages other than Windows-1252
+var codePageEncodings = map[uint16]encoding.Encoding{
+	874:  charmap.Windows874,
+	932:  japanese.ShiftJIS,
+	936:  simplifiedchinese.GBK,
+	949:  korean.EUCKR,
+	950:  traditionalchinese.Big5,
+	1250: charmap.Windows1250,
+	1251: charmap.Windows1251,
+	1252: charmap.Windows1252,
+	1253: charmap.Windows1253,
+	1254: charmap.Windows1254,
+	1255: charmap.Windows1255,
+	1256: charmap.Windows1256,
+	1257: charmap.Windows1257,
+	1258: charmap.Windows1258,
+}
+
+// CodePage returns the Windows (ANSI) code page that 8-bit text in a language is written in, by its LID, e.g. 1251 for Russian or 932 for Japanese.
+// It is 1252 (Windows-1252) for the languages of western Europe and the Americas, and for any it doesn't know.
+func CodePage(lid uint16) uint16 {
+	if cp, ok := lidCodePages[lid]; ok {
+		return cp
+	}
+	if cp, ok := languageCodePages[lid&0x3FF]; ok {
+		return cp
+	}
+	return 1252
+}
+
+// textCodePage returns the code page of a .doc's 8-bit text and strings, from its FIB. From Word 97 on, 8-bit text is always Windows-1252, and text that
+// can't be written in it is stored as UTF-16; Word 6.0 and Word 95 write their text in the code page of the language of their copy of Word (lid in the FibBase).
+func textCodePage(fib []byte) uint16 {
+	if !isWord6(binary.LittleEndian.Uint16(fib[2:4])) {
+		return 1252
+	}
+	return CodePage(binary.LittleEndian.Uint16(fib[6:8]))
+}
+
+// ansiCodePage returns the code page of the 8-bit strings that a .doc has outside its text and STTBs (in an object's CompObj or Ole10Native stream, a hyperlink's
+// file moniker or a linked picture's name), which are written in the ANSI code page of the system that saved them, not in Word's: this is taken to be the code page of the
+// language of the copy of Word that saved the document (lid in the FibBase).
+func ansiCodePage(fib []byte) uint16 {
+	return CodePage(binary.LittleEndian.Uint16(fib[6:8]))
+}
+
+// decodeString decodes 8-bit text in a Windows code page as UTF-8. Text in a code page without a decoder, or that can't be decoded, is read as Windows-1252.
+func decodeString(b []byte, codePage uint16) string {
+	if e, ok := codePageEncodings[codePage]; ok {
+		if s, err := e.NewDecoder().Bytes(b); err == nil {
+			return string(s)
+		}
+	}
+	s, _ := charmap.Windows1252.NewDecoder().Bytes(b) // a single-byte code page always decodes
+	return string(s)
+}
+
+// byteDecoder returns a function that decodes a single byte of 8-bit text in a Windows code page, for telling whether a run of bytes is text.
+// A double-byte code page's characters can't be told a byte at a time, so only its ASCII is decoded, and any other byte is utf8.RuneError;
+// a code page without a decoder is read as Windows-1252.
+func byteDecoder(codePage uint16) func(byte) rune {
+	e, ok := codePageEncodings[codePage]
+	if !ok {
+		e = charmap.Windows1252
+	}
+	if cm, ok := e.(*charmap.Charmap); ok {
+		return cm.DecodeByte
+	}
+	return func(b byte) rune {
+		if b < 0x80 {
+			return rune(b)
+		}
+		return utf8.RuneError
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+var errComments = errors.New("malformed comment tables")
+
+// Comment is a reviewer's comment (annotation) on the document
+type Comment struct {
+	Author   string // from the GrpXstAtnOwners; empty if it can't be found
+	Initials string
+	CP       uint32 // the character position in the document body of the comment's reference mark
+	Text     string // empty if the text can't be read
+}
+
+// readComments reads the comments on the document. The PlcfandRef (pair 4 of the FibRgFcLcb) has the CP of each comment's reference mark followed by an ATRDPre10 (30 bytes) for each:
+// the author's initials (a 16-bit count and up to 9 UTF-16 characters) and, at offset 20, the index (ibst) of the author's name in the GrpXstAtnOwners (pair 36), which is a run of Xsts (a 16-bit count and that many UTF-16 characters).
+// The PlcfandTxt (pair 5) has the CP of the start of each comment's text in the comments part of the document's text, and one more for the end of the last.
+// The text is read with the piece table, if there is one; like field instructions, each comment's text is cut short at maxInstruction characters.
+func readComments(table io.ReaderAt, tableSize int64, fcLcb []byte, doc io.ReaderAt, pieces []piece, counts []uint32) ([]Comment, error) {
+	ref, err := readTableData(table, tableSize, fcLcb, 4)
+	if err != nil || ref == nil {
+		return nil, err
+	}
+	n := (len(ref) - 4) / 34
+	owners, err := readTableData(table, tableSize, fcLcb, 36)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for i := 0; i+2 <= len(owners); {
+		l := int(binary.LittleEndian.Uint16(owners[i:]))
+		if i+2+l*2 > len(owners) {
+			break
+		}
+		names = append(names, utf16String(owners[i+2:i+2+l*2]))
+		i += 2 + l*2
+	}
+	comments := make([]Comment, n)
+	for i := range comments {
+		atrd := ref[(n+1)*4+i*30:]
+		l := int(binary.LittleEndian.Uint16(atrd))
+		if l > 9 {
+			l = 9
+		}
+		comments[i] = Comment{Initials: utf16String(atrd[2 : 2+l*2]), CP: binary.LittleEndian.Uint32(ref[i*4:])}
+		if ibst := int(binary.LittleEndian.Uint16(atrd[20:])); ibst < len(names) {
+			comments[i].Author = names[ibst]
+		}
+	}
+	if pieces == nil || counts == nil {
+		return comments, nil
+	}
+	txt, err := readTableData(table, tableSize, fcLcb, 5)
+	if err != nil {
+		return comments, err
+	}
+	if len(txt)/4 < n+1 {
+		return comments, errComments
+	}
+	var base uint32
+	for _, c := range counts[:4] { // the comments come after the body, footnotes, headers and (unused) macro text
+		base += c
+	}
+	for i := range comments {
+		start, end := binary.LittleEndian.Uint32(txt[i*4:]), binary.LittleEndian.Uint32(txt[i*4+4:])
+		if end <= start {
+			continue
+		}
+		text, err := readText(doc, pieces, base+start, base+end)
+		if err != nil {
+			return comments, err
+		}
+		comments[i].Text = cleanText(text)
+	}
+	return comments, nil
+}
+
+func utf16String(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// cleanText tidies text read from the document for display: paragraph marks and line breaks become newlines, and the other control characters (e.g. the 0x05 annotation reference mark) are dropped
+func cleanText(s string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		switch {
+		case r == '\r' || r == '\v':
+			return '\n'
+		case r == '\t' || r == '\n':
+			return r
+		case r < 0x20:
+			return -1
+		}
+		return r
+	}, s))
+}
@@ -0,0 +1,52 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import "strings"
+
+// CrossReference is a REF, PAGEREF or NOTEREF (or its older form, FTNREF) field, which shows the text, page number or note number of a bookmark, and the bookmark it refers to
+type CrossReference struct {
+	Region   string    // the key of the region the field is in (body, header etc.)
+	CP       uint32    // the character position of the field's begin character, relative to the start of the region
+	Type     string    // the field's keyword: REF, PAGEREF, NOTEREF or FTNREF
+	Bookmark string    // the name of the bookmark, from the field's instruction text; empty if it has none
+	Target   *Bookmark // the bookmark of that name (Word ignores case in bookmark names), or nil if the document has none, which leaves the reference dangling: Word shows "Error! Reference source not found." when the field is updated
+}
+
+// CrossReferences lists the cross-reference fields in the document, in the order of the regions and then of the fields, with the bookmark each refers to.
+// resolved is false if the document's bookmarks aren't read (it is a Word 6.0 or Word 95 .doc, an OOXML package or RTF), and then no Target is set.
+func (d *Report) CrossReferences() (refs []CrossReference, resolved bool) {
+	resolved = d.Format == FormatDOC && d.Encryption == "" && !isWord6(d.NFib)
+	for _, r := range d.Regions() {
+		for _, f := range r.Occurrences {
+			t, _ := f.Type()
+			if t.Keyword != "REF" && t.Keyword != "PAGEREF" && t.Keyword != "NOTEREF" && t.Keyword != "FTNREF" {
+				continue
+			}
+			x := CrossReference{Region: r.Key, CP: f.CP, Type: t.Keyword}
+			if _, args := Args(f.Instruction); len(args) > 0 {
+				x.Bookmark = args[0]
+			}
+			for i, b := range d.Bookmarks {
+				if resolved && x.Bookmark != "" && strings.EqualFold(b.Name, x.Bookmark) {
+					x.Target = &d.Bookmarks[i]
+					break
+				}
+			}
+			refs = append(refs, x)
+		}
+	}
+	return refs, resolved
+}
@@ -0,0 +1,202 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/mscfb"
+)
+
+var errCustom = errors.New("malformed user-defined property set")
+
+// the FMTID of the user-defined property set, the second section of the DocumentSummaryInformation stream, as stored (the first three parts are little-endian)
+var fmtidUserDefined = []byte{0x05, 0xD5, 0xCD, 0xD5, 0x9C, 0x2E, 0x1B, 0x10, 0x93, 0x97, 0x08, 0x00, 0x2B, 0x2C, 0xF9, 0xAE}
+
+// readCustomProperties reads the user-defined (custom) properties from the DocumentSummaryInformation stream, in the order they are stored.
+// The stream is a property set: a 28 byte header, with the number of sections at offset 24, followed by the FMTID and offset of each section. A section is its size,
+// the number of properties, and the identifier and offset (from the start of the section) of each. In the user-defined section, property 0 is the dictionary,
+// which names the other properties, and property 1 is the code page of its strings. Values of types that aren't text, numbers, booleans or times are left out.
+// It returns nil, and no error, if the stream doesn't have a user-defined section.
+func readCustomProperties(docSummary *mscfb.File) ([]Property, error) {
+	if docSummary == nil {
+		return nil, nil
+	}
+	if docSummary.Size > 1<<24 { // far more than any property set needs
+		return nil, errCustom
+	}
+	buf := make([]byte, docSummary.Size)
+	if n, err := docSummary.ReadAt(buf, 0); n < len(buf) && err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(buf) < 28 {
+		return nil, errCustom
+	}
+	var sec []byte
+	for i, n := 0, int(binary.LittleEndian.Uint32(buf[24:])); i < n && 28+i*20+20 <= len(buf); i++ {
+		if bytes.Equal(buf[28+i*20:28+i*20+16], fmtidUserDefined) {
+			if o := int64(binary.LittleEndian.Uint32(buf[28+i*20+16:])); o+8 <= int64(len(buf)) {
+				sec = buf[o:]
+			}
+		}
+	}
+	if sec == nil {
+		return nil, nil
+	}
+	n := int(binary.LittleEndian.Uint32(sec[4:]))
+	if 8+n*8 > len(sec) {
+		return nil, errCustom
+	}
+	codePage := uint16(1252)
+	offsets := make(map[uint32]int, n)
+	var ids []uint32
+	for i := 0; i < n; i++ {
+		id, o := binary.LittleEndian.Uint32(sec[8+i*8:]), int(binary.LittleEndian.Uint32(sec[12+i*8:]))
+		if o+4 > len(sec) {
+			return nil, errCustom
+		}
+		offsets[id] = o
+		if id == 1 && o+6 <= len(sec) && binary.LittleEndian.Uint16(sec[o:]) == 0x0002 { // VT_I2
+			codePage = binary.LittleEndian.Uint16(sec[o+4:])
+		}
+		if id > 1 && id&0x80000000 == 0 { // not the dictionary, the code page or one of the reserved identifiers for the locale and behaviour
+			ids = append(ids, id)
+		}
+	}
+	names, err := readDictionary(sec, offsets, codePage)
+	if err != nil {
+		return nil, err
+	}
+	var props []Property
+	for _, id := range ids {
+		name, ok := names[id]
+		if !ok {
+			name = fmt.Sprintf("property %d", id)
+		}
+		if v, ok := propertyValue(sec[offsets[id]:], codePage); ok {
+			props = append(props, Property{name, v})
+		}
+	}
+	return props, nil
+}
+
+// readDictionary reads the names of the properties from the dictionary (property 0) of a section: a count, then for each entry the property's identifier and the length
+// of its name in characters (including the terminating null), then the name. For the UTF-16 code page (1200) each name is padded to a multiple of 4 bytes.
+func readDictionary(sec []byte, offsets map[uint32]int, codePage uint16) (map[uint32]string, error) {
+	names := make(map[uint32]string)
+	o, ok := offsets[0]
+	if !ok {
+		return names, nil
+	}
+	n := int(binary.LittleEndian.Uint32(sec[o:]))
+	o += 4
+	for i := 0; i < n; i++ {
+		if o+8 > len(sec) {
+			return names, errCustom
+		}
+		id, l := binary.LittleEndian.Uint32(sec[o:]), int(binary.LittleEndian.Uint32(sec[o+4:]))
+		o += 8
+		if codePage == 1200 {
+			l *= 2
+		}
+		if l < 0 || o+l > len(sec) {
+			return names, errCustom
+		}
+		names[id] = propertyString(sec[o:o+l], codePage)
+		o += l
+		if codePage == 1200 && l%4 != 0 {
+			o += 4 - l%4
+		}
+	}
+	return names, nil
+}
+
+// propertyString decodes a string from a property set in its code page: UTF-16 (1200), UTF-8 (65001) or a Windows code page (see decodeString)
+func propertyString(b []byte, codePage uint16) string {
+	switch codePage {
+	case 1200:
+		return strings.TrimRight(utf16String(b), "\x00")
+	case 65001:
+		return string(bytes.TrimRight(b, "\x00"))
+	}
+	return decodeString(bytes.TrimRight(b, "\x00"), codePage)
+}
+
+// propertyValue gives a property's value (its 16-bit type, 2 bytes of padding, then the value) as text. ok is false if the type isn't one that is reported.
+func propertyValue(b []byte, codePage uint16) (v string, ok bool) {
+	if len(b) < 4 {
+		return "", false
+	}
+	typ, b := binary.LittleEndian.Uint16(b), b[4:]
+	switch {
+	case typ == 0x001E && len(b) >= 4: // VT_LPSTR: a size in bytes, then the string in the code page
+		if l := int(binary.LittleEndian.Uint32(b)); l >= 0 && 4+l <= len(b) {
+			return propertyString(b[4:4+l], codePage), true
+		}
+	case typ == 0x001F && len(b) >= 4: // VT_LPWSTR: a length in characters, then UTF-16
+		if l := int(binary.LittleEndian.Uint32(b)); l >= 0 && 4+l*2 <= len(b) {
+			return strings.TrimRight(utf16String(b[4:4+l*2]), "\x00"), true
+		}
+	case typ == 0x0002 && len(b) >= 2: // VT_I2
+		return fmt.Sprint(int16(binary.LittleEndian.Uint16(b))), true
+	case typ == 0x0003 && len(b) >= 4: // VT_I4
+		return fmt.Sprint(int32(binary.LittleEndian.Uint32(b))), true
+	case typ == 0x0005 && len(b) >= 8: // VT_R8
+		return fmt.Sprint(math.Float64frombits(binary.LittleEndian.Uint64(b))), true
+	case typ == 0x000B && len(b) >= 2: // VT_BOOL
+		return fmt.Sprint(binary.LittleEndian.Uint16(b) != 0), true
+	case typ == 0x0040 && len(b) >= 8: // VT_FILETIME: 100-nanosecond intervals since 1601, given in the same form as the times of the other properties
+		ft := binary.LittleEndian.Uint64(b)
+		if ft < 116444736000000000 {
+			return "", false
+		}
+		return time.Unix(0, 0).Add(time.Duration(ft-116444736000000000) * 100).UTC().String(), true
+	}
+	return "", false
+}
+
+// readPackageCustom reads an OOXML package's custom properties (docProps/custom.xml), in the order they are stored
+func readPackageCustom(files map[string]*zip.File) []Property {
+	rc := openTarget(files, "custom-properties")
+	if rc == nil {
+		return nil
+	}
+	defer rc.Close()
+	var custom struct {
+		Property []struct {
+			Name  string `xml:"name,attr"`
+			Value struct {
+				Text string `xml:",chardata"`
+			} `xml:",any"` // a vt:lpwstr, vt:i4, vt:bool, vt:filetime etc.
+		} `xml:"property"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&custom); err != nil {
+		return nil
+	}
+	var props []Property
+	for _, p := range custom.Property {
+		props = append(props, Property{p.Name, p.Value.Text})
+	}
+	return props
+}
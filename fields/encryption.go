@@ -0,0 +1,77 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// the methods of encryption reported in a Report's Encryption
+const (
+	EncryptionXOR       = "XOR obfuscation"
+	EncryptionRC4       = "RC4"
+	EncryptionCryptoAPI = "RC4 CryptoAPI"
+	EncryptionOOXML     = "OOXML"
+	EncryptionUnknown   = "unknown method"
+)
+
+// encryptionVersion reads the version (vMajor, vMinor) at the start of an EncryptionHeader or EncryptionInfo stream
+func encryptionVersion(stream *mscfb.File) (major, minor uint16, ok bool) {
+	if stream == nil {
+		return 0, 0, false
+	}
+	b := make([]byte, 4)
+	if n, _ := stream.ReadAt(b, 0); n < len(b) {
+		return 0, 0, false
+	}
+	return binary.LittleEndian.Uint16(b), binary.LittleEndian.Uint16(b[2:]), true
+}
+
+// docEncryption identifies how an encrypted .doc is encrypted. Word 6.0 and Word 95 only had XOR obfuscation, which later versions mark with fObfuscated.
+// Otherwise the table stream starts with an EncryptionHeader, whose version is 1.1 for RC4, and 2.2, 3.2 or 4.2 for RC4 CryptoAPI.
+// (lKey, in the FibBase, is the password verifier for XOR obfuscation, and the size of the EncryptionHeader otherwise.)
+func docEncryption(word6 bool, flags Flags, table *mscfb.File) string {
+	if word6 || flags.Obfuscated {
+		return EncryptionXOR
+	}
+	major, minor, ok := encryptionVersion(table)
+	switch {
+	case !ok:
+		return EncryptionUnknown
+	case major == 1 && minor == 1:
+		return EncryptionRC4
+	case major >= 2 && major <= 4 && minor == 2:
+		return EncryptionCryptoAPI
+	}
+	return EncryptionUnknown
+}
+
+// packageEncryption describes the encryption of an encrypted OOXML document, which is a compound file holding the encrypted package (EncryptedPackage) and
+// the details of its encryption (EncryptionInfo): version 4.4 is agile encryption, 3.2 and 4.2 standard encryption, and 3.3 and 4.3 extensible encryption
+func packageEncryption(info *mscfb.File) string {
+	major, minor, _ := encryptionVersion(info)
+	switch {
+	case major == 4 && minor == 4:
+		return EncryptionOOXML + " agile encryption"
+	case (major == 3 || major == 4) && minor == 2:
+		return EncryptionOOXML + " standard encryption"
+	case (major == 3 || major == 4) && minor == 3:
+		return EncryptionOOXML + " extensible encryption"
+	}
+	return fmt.Sprintf("%s encryption (unknown version %d.%d)", EncryptionOOXML, major, minor)
+}
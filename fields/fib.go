@@ -0,0 +1,367 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// versions maps values of nFib (the FIB version number at offset 2) to the versions of Word that write them.
+// Documents written by Word 97 and later all have an nFib of at least 0x00C1 here, with the precise version only recorded further into the FIB.
+var versions = map[uint16]string{
+	0x0021: "Word for Windows 1.0",
+	0x002D: "Word for Windows 2.0",
+	0x0065: "Word 6.0",
+	0x0068: "Word 95",
+	0x00C0: "Word 97 beta",
+	0x00C1: "Word 97",
+	0x00D9: "Word 2000",
+	0x0101: "Word 2002",
+	0x010C: "Word 2003",
+	0x0112: "Word 2007",
+}
+
+// the first nFib with the FibRgFcLcb97 layout that doctool reads field offsets from
+const nFib97 = 0x00C1
+
+// the range of nFib written by Word 6.0 and Word 95, whose FIB has a fixed layout (see readFIB6)
+const (
+	nFib6  = 0x0065
+	nFib95 = 0x0068
+)
+
+// isWord6 reports whether the nFib is that of a Word 6.0 or Word 95 document
+func isWord6(nFib uint16) bool {
+	return nFib >= nFib6 && nFib <= nFib95
+}
+
+// Version returns the version of Word that writes the given nFib
+func Version(nFib uint16) string {
+	if v, ok := versions[nFib]; ok {
+		return v
+	}
+	return "unknown version"
+}
+
+// checkVersion returns an ErrUnsupportedVersion error if the FIB predates Word 6.0, as doctool doesn't know where those versions keep their fields
+func checkVersion(fib []byte) error {
+	nFib := binary.LittleEndian.Uint16(fib[2:4])
+	if nFib < nFib97 && !isWord6(nFib) {
+		return fmt.Errorf("%w: %s (nFib 0x%04X)", ErrUnsupportedVersion, Version(nFib), nFib)
+	}
+	return nil
+}
+
+// fcLcbVersions maps values of cbRgFcLcb (the number of fc/lcb pairs in the FibRgFcLcb) to the versions of Word that write them.
+// Each version from Word 97 on appends pairs for its new features, so this is more precise than the nFib in the FibBase.
+var fcLcbVersions = map[uint16]string{
+	0x005D: "Word 97",
+	0x006C: "Word 2000",
+	0x0088: "Word 2002",
+	0x00A4: "Word 2003",
+	0x00B7: "Word 2007",
+}
+
+// WordVersion returns the version of Word that last saved the document, from the most precise of the version numbers in its FIB:
+// nFibNew (Word 2000 and later), then cbRgFcLcb (which distinguishes the same versions, but is less reliable as other applications copy Word 97's), then nFib.
+// It is empty for documents that aren't .docs.
+func (d *Report) WordVersion() string {
+	if d.Format != FormatDOC {
+		return ""
+	}
+	if v, ok := versions[d.NFibNew]; ok {
+		return v
+	}
+	if v, ok := fcLcbVersions[d.CbRgFcLcb]; ok {
+		return v
+	}
+	return Version(d.NFib)
+}
+
+// FcLcbVersion returns the version of Word whose FibRgFcLcb has the given number of fc/lcb pairs
+func FcLcbVersion(cbRgFcLcb uint16) string {
+	if v, ok := fcLcbVersions[cbRgFcLcb]; ok {
+		return v
+	}
+	return "unknown version"
+}
+
+// readFIB reads the FIB from the start of the WordDocument stream, returning it along with its FibRgFcLcb: the fc (offset) and lcb (size) pairs that locate
+// parts of the document in the table stream. The length of the FIB varies between versions, so it is read in steps, using the count that precedes each
+// variable-length part: csw (the 16-bit words of the FibRgW), cslw (the 32-bit words of the FibRgLw) and cbRgFcLcb (the fc/lcb pairs).
+// If the stream ends part way through the FibRgFcLcb, the pairs that are there are returned; callers should check the length before using a pair.
+func readFIB(r io.ReaderAt) ([]byte, []byte, error) {
+	fib, err := extendFIB(r, nil, 34) // the FibBase and csw
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkVersion(fib); err != nil {
+		return nil, nil, err
+	}
+	if isWord6(binary.LittleEndian.Uint16(fib[2:4])) { // these don't have the csw and counts that follow
+		return readFIB6(r, fib)
+	}
+	l := 34 + int(binary.LittleEndian.Uint16(fib[32:34]))*2 // the end of the FibRgW
+	if fib, err = extendFIB(r, fib, l+2); err != nil {      // and cslw
+		return nil, nil, err
+	}
+	l += 2 + int(binary.LittleEndian.Uint16(fib[l:l+2]))*4 // the end of the FibRgLw
+	if fib, err = extendFIB(r, fib, l+2); err != nil {     // and cbRgFcLcb
+		return nil, nil, err
+	}
+	start := l + 2
+	end := start + int(binary.LittleEndian.Uint16(fib[l:l+2]))*8
+	fib, err = extendFIB(r, fib, end)
+	if err != nil && err != ErrFibShort {
+		return nil, nil, err
+	}
+	fcLcb := fib[start : start+(len(fib)-start)/8*8]
+	if err == nil { // the FibRgFcLcb is complete, so read cswNew and the FibRgCswNew that follow it (Word 97 documents have a cswNew of 0); it's not an error if they are missing
+		if fib, err = extendFIB(r, fib, end+2); err == nil {
+			fib, _ = extendFIB(r, fib, end+2+int(binary.LittleEndian.Uint16(fib[end:end+2]))*2)
+		}
+	}
+	return fib, fcLcb, nil
+}
+
+// nFibNew returns the nFibNew from the FibRgCswNew, which Word 2000 and later add after the FibRgFcLcb to record their version, or 0 if the FIB doesn't have one
+func nFibNew(fib []byte) uint16 {
+	if isWord6(binary.LittleEndian.Uint16(fib[2:4])) {
+		return 0
+	}
+	end := 34 + int(binary.LittleEndian.Uint16(fib[32:34]))*2
+	end += 2 + int(binary.LittleEndian.Uint16(fib[end:end+2]))*4
+	end += 2 + int(binary.LittleEndian.Uint16(fib[end:end+2]))*8
+	if len(fib) < end+4 || binary.LittleEndian.Uint16(fib[end:end+2]) == 0 {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(fib[end+2 : end+4])
+}
+
+// readFIB6 reads the rest of a Word 6.0 or Word 95 FIB, which has a fixed layout with its fc/lcb pairs starting at offset 88.
+// The pairs are in the same order as in the Word 97 FibRgFcLcb, except that after fcSttbfAtnBkmk/lcbSttbfAtnBkmk (pair 37) come five 16-bit values
+// (which Word 97 moved to the FibRgW and FibRgLw), so these are taken out to make the indexes of the later pairs match.
+func readFIB6(r io.ReaderAt, fib []byte) ([]byte, []byte, error) {
+	const start, split, gap = 88, 88 + 38*8, 10
+	fib, err := extendFIB(r, fib, split+gap+22*8) // up to the end of fcPlcffldHdrTxbx/lcbPlcffldHdrTxbx (pair 59), the last that doctool reads
+	if err != nil && (err != ErrFibShort || len(fib) < start) {
+		return nil, nil, err
+	}
+	if len(fib) <= split {
+		return fib, fib[start : start+(len(fib)-start)/8*8], nil
+	}
+	fcLcb := append([]byte(nil), fib[start:split]...)
+	if len(fib) > split+gap {
+		fcLcb = append(fcLcb, fib[split+gap:]...)
+	}
+	return fib, fcLcb[:len(fcLcb)/8*8], nil
+}
+
+// zeroPadded reads a stream as though it carried on with zeros past its end, so that a FIB cut short can be read with Options.Lenient
+type zeroPadded struct {
+	r io.ReaderAt
+}
+
+func (z zeroPadded) ReadAt(p []byte, off int64) (int, error) {
+	n, err := z.r.ReadAt(p, off)
+	if n < len(p) && (err == nil || err == io.EOF || err == io.ErrUnexpectedEOF) {
+		for i := n; i < len(p); i++ {
+			p[i] = 0
+		}
+		return len(p), nil
+	}
+	return n, err
+}
+
+// extendFIB reads more of the FIB, so that it is n bytes long. If the stream is too short it returns what there is, along with ErrFibShort.
+func extendFIB(r io.ReaderAt, fib []byte, n int) ([]byte, error) {
+	if n <= len(fib) {
+		return fib, nil
+	}
+	buf := make([]byte, n-len(fib))
+	m, err := r.ReadAt(buf, int64(len(fib)))
+	fib = append(fib, buf[:m]...)
+	if m < len(buf) { // ReadAt can return io.EOF along with all the bytes, so check m rather than err
+		if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fib, ErrFibShort
+		}
+		return fib, err
+	}
+	return fib, nil
+}
+
+// cbRgFcLcb returns the number of fc/lcb pairs that the FIB says its FibRgFcLcb has
+func cbRgFcLcb(fib []byte) uint16 {
+	l := 34 + int(binary.LittleEndian.Uint16(fib[32:34]))*2
+	l += 2 + int(binary.LittleEndian.Uint16(fib[l:l+2]))*4
+	return binary.LittleEndian.Uint16(fib[l : l+2])
+}
+
+// Flags are the bit fields of the FibBase: the 16 bit flags word at offset 10 of the FIB, and the byte at offset 19.
+// Names in the comments are those used in the MS-DOC spec.
+type Flags struct {
+	Dot                 bool // fDot: the document is a template
+	Glsy                bool // fGlsy: the document only contains AutoText items
+	Complex             bool // fComplex: the last save was an incremental (fast) save
+	HasPic              bool // fHasPic: the document has pictures
+	QuickSaves          int  // cQuickSaves: the number of consecutive fast saves (4 bits)
+	Encrypted           bool // fEncrypted: the document is encrypted or obfuscated
+	WhichTblStm         bool // fWhichTblStm: the table stream is 1Table (otherwise 0Table)
+	ReadOnlyRecommended bool // fReadOnlyRecommended
+	WriteReservation    bool // fWriteReservation: the document has a write-reservation password
+	ExtChar             bool // fExtChar: should always be set
+	LoadOverride        bool // fLoadOverride: override language and font defaults with the application's
+	FarEast             bool // fFarEast: the installation language was East Asian
+	Obfuscated          bool // fObfuscated: if Encrypted is also set, XOR obfuscation is used
+	Mac                 bool // fMac: last saved on a Macintosh
+	EmptySpecial        bool // fEmptySpecial
+	LoadOverridePage    bool // fLoadOverridePage: override page size and orientation defaults
+}
+
+func decodeFlags(fib []byte) Flags {
+	w := binary.LittleEndian.Uint16(fib[10:12])
+	bit := func(n uint) bool { return w>>n&1 == 1 }
+	return Flags{
+		Dot:                 bit(0),
+		Glsy:                bit(1),
+		Complex:             bit(2),
+		HasPic:              bit(3),
+		QuickSaves:          int(w >> 4 & 0xF),
+		Encrypted:           bit(8),
+		WhichTblStm:         bit(9),
+		ReadOnlyRecommended: bit(10),
+		WriteReservation:    bit(11),
+		ExtChar:             bit(12),
+		LoadOverride:        bit(13),
+		FarEast:             bit(14),
+		Obfuscated:          bit(15),
+		Mac:                 fib[19]&1 == 1,
+		EmptySpecial:        fib[19]>>1&1 == 1,
+		LoadOverridePage:    fib[19]>>2&1 == 1,
+	}
+}
+
+// String lists the flags that are set, by their spec names, along with the quick save count
+func (f Flags) String() string {
+	var set []string
+	for _, fl := range []struct {
+		name string
+		val  bool
+	}{
+		{"fDot", f.Dot},
+		{"fGlsy", f.Glsy},
+		{"fComplex", f.Complex},
+		{"fHasPic", f.HasPic},
+		{"fEncrypted", f.Encrypted},
+		{"fWhichTblStm", f.WhichTblStm},
+		{"fReadOnlyRecommended", f.ReadOnlyRecommended},
+		{"fWriteReservation", f.WriteReservation},
+		{"fExtChar", f.ExtChar},
+		{"fLoadOverride", f.LoadOverride},
+		{"fFarEast", f.FarEast},
+		{"fObfuscated", f.Obfuscated},
+		{"fMac", f.Mac},
+		{"fEmptySpecial", f.EmptySpecial},
+		{"fLoadOverridePage", f.LoadOverridePage},
+	} {
+		if fl.val {
+			set = append(set, fl.name)
+		}
+	}
+	return fmt.Sprintf("%s; cQuickSaves=%d", strings.Join(set, ", "), f.QuickSaves)
+}
+
+// FIB is the file information block of a .doc, split into its parts, for debugging.
+// Word 6.0 and Word 95 FIBs have a fixed layout without the counted parts, so only Base and FcLcb are set for them.
+type FIB struct {
+	Base     []byte   // the FibBase: the first 32 bytes
+	Flags    Flags    // the flags from the FibBase, decoded
+	RgW      []uint16 // the FibRgW97, counted by csw
+	RgLw     []uint32 // the FibRgLw97, counted by cslw
+	FcLcb    []FcLcb  // the FibRgFcLcb, counted by cbRgFcLcb; listed in fib_bits.txt in this repo
+	RgCswNew []uint16 // the FibRgCswNew, counted by cswNew (the first is nFibNew)
+	Short    bool     // the WordDocument stream ended part way through the FIB
+}
+
+// FcLcb is an offset (fc) and size (lcb) pair from the FibRgFcLcb
+type FcLcb struct {
+	Fc, Lcb uint32
+}
+
+// ReadFIB reads the FIB of a .doc without going on to parse the document, so that it can be used on documents that Parse can't make sense of
+func ReadFIB(ra io.ReaderAt) (*FIB, error) {
+	if sniff(ra) == "" {
+		return nil, wrapError(ErrNotWord)
+	}
+	doc, err := mscfb.New(ra)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	var wordDoc *mscfb.File
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		if entry.Name == "WordDocument" && len(entry.Path) == 0 {
+			wordDoc = entry
+			break
+		}
+	}
+	if wordDoc == nil {
+		return nil, wrapError(ErrNoWordDocument)
+	}
+	fib, fcLcb, err := readFIB(wordDoc)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	f := &FIB{Base: fib[:32], Flags: decodeFlags(fib)}
+	for i := 0; i+8 <= len(fcLcb); i += 8 {
+		f.FcLcb = append(f.FcLcb, FcLcb{binary.LittleEndian.Uint32(fcLcb[i:]), binary.LittleEndian.Uint32(fcLcb[i+4:])})
+	}
+	if isWord6(binary.LittleEndian.Uint16(fib[2:4])) {
+		f.Short = len(fcLcb) < 60*8
+		return f, nil
+	}
+	counted := func(l, size int) (int, int) { // the number of items in the part whose count is at l, and the end of the part, limited to the FIB that was read
+		if l+2 > len(fib) {
+			return 0, l
+		}
+		n := int(binary.LittleEndian.Uint16(fib[l:]))
+		end := l + 2 + n*size
+		if end > len(fib) {
+			f.Short = true
+			n = (len(fib) - l - 2) / size
+		}
+		return n, end
+	}
+	n, l := counted(32, 2)
+	for i := 0; i < n; i++ {
+		f.RgW = append(f.RgW, binary.LittleEndian.Uint16(fib[34+i*2:]))
+	}
+	start := l
+	n, l = counted(l, 4)
+	for i := 0; i < n; i++ {
+		f.RgLw = append(f.RgLw, binary.LittleEndian.Uint32(fib[start+2+i*4:]))
+	}
+	_, l = counted(l, 8)
+	start = l
+	n, _ = counted(l, 2)
+	for i := 0; i < n; i++ {
+		f.RgCswNew = append(f.RgCswNew, binary.LittleEndian.Uint16(fib[start+2+i*2:]))
+	}
+	return f, nil
+}
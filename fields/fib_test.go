@@ -0,0 +1,38 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import "testing"
+
+func TestDecodeFlags(t *testing.T) {
+	tests := []struct {
+		word uint16 // the flags word at offset 10 of the FibBase
+		want Flags
+	}{
+		{0x0000, Flags{}},
+		{0x0200, Flags{WhichTblStm: true}},
+		{0x0001, Flags{Dot: true}},
+		{0x0104, Flags{Complex: true, Encrypted: true}},
+		{0x00F0, Flags{QuickSaves: 15}},
+		{0x8100, Flags{Encrypted: true, Obfuscated: true}},
+	}
+	for _, tt := range tests {
+		fib := make([]byte, 32)
+		fib[10], fib[11] = byte(tt.word), byte(tt.word>>8)
+		if got := decodeFlags(fib); got != tt.want {
+			t.Errorf("decodeFlags(0x%04X) = %+v, want %+v", tt.word, got, tt.want)
+		}
+	}
+}
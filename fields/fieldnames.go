@@ -0,0 +1,154 @@
+package fields
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldType is an entry in the registry of the field types doctool knows, which are listed in the Flt table of the MS-DOC spec (section 2.9.90)
+type FieldType struct {
+	Code    byte   // the flt, which a field's begin marker gives as its type
+	Name    string // the name doctool reports fields of this type by, e.g. "merge field"
+	Keyword string // the canonical name: the keyword that starts the instruction of a field of this type, e.g. MERGEFIELD (empty for the types that have none)
+	Spec    string // where MS-DOC says the field type is specified
+	Class   Class  // how the result of a field of this type behaves (see Class and Field.Class)
+}
+
+var fieldTypes = []FieldType{
+	{0x01, "unparseable", "", "[MS-DOC] 2.9.90", Static},
+	{0x02, "ref - no keyword", "", "[MS-DOC] 2.9.90", Static},
+	{0x03, "ref", "REF", "[ECMA-376] part 4, section 2.16.5.58", Static},
+	{0x05, "ftnref", "FTNREF", "[ECMA-376] part 4, section 2.16.5.47 (as NOTEREF)", Static},
+	{0x06, "set", "SET", "[ECMA-376] part 4, section 2.16.5.64", Static},
+	{0x07, "if", "IF", "[ECMA-376] part 4, section 2.16.5.32", Static},
+	{0x08, "index", "INDEX", "[ECMA-376] part 4, section 2.16.5.35", Static},
+	{0x0A, "styleref", "STYLEREF", "[ECMA-376] part 4, section 2.16.5.66", Static},
+	{0x0C, "seq", "SEQ", "[ECMA-376] part 4, section 2.16.5.63", Static},
+	{0x0D, "TOC", "TOC", "[ECMA-376] part 4, section 2.16.5.75", Static},
+	{0x0E, "info", "INFO", "[ECMA-376] part 4, section 2.16.5.36", Static},
+	{0x0F, "title", "TITLE", "[ECMA-376] part 4, section 2.16.5.73", Static},
+	{0x10, "subject", "SUBJECT", "[ECMA-376] part 4, section 2.16.5.67", Static},
+	{0x11, "author", "AUTHOR", "[ECMA-376] part 4, section 2.16.5.4", Static},
+	{0x12, "keywords", "KEYWORDS", "[ECMA-376] part 4, section 2.16.5.37", Static},
+	{0x13, "comments", "COMMENTS", "[ECMA-376] part 4, section 2.16.5.14", Static},
+	{0x14, "last saved by", "LASTSAVEDBY", "[ECMA-376] part 4, section 2.16.5.38", Volatile},
+	{0x15, "creation date", "CREATEDATE", "[ECMA-376] part 4, section 2.16.5.16", Static},
+	{0x16, "save date", "SAVEDATE", "[ECMA-376] part 4, section 2.16.5.60", Volatile},
+	{0x17, "print date", "PRINTDATE", "[ECMA-376] part 4, section 2.16.5.54", Volatile},
+	{0x18, "revision number", "REVNUM", "[ECMA-376] part 4, section 2.16.5.59", Volatile},
+	{0x19, "edit time", "EDITTIME", "[ECMA-376] part 4, section 2.16.5.21", Volatile},
+	{0x1A, "number of pages", "NUMPAGES", "[ECMA-376] part 4, section 2.16.5.49", Volatile},
+	{0x1B, "number of words", "NUMWORDS", "[ECMA-376] part 4, section 2.16.5.50", Volatile},
+	{0x1C, "number of chars", "NUMCHARS", "[ECMA-376] part 4, section 2.16.5.48", Volatile},
+	{0x1D, "filename", "FILENAME", "[ECMA-376] part 4, section 2.16.5.23", Volatile},
+	{0x1E, "template", "TEMPLATE", "[ECMA-376] part 4, section 2.16.5.71", Static},
+	{0x1F, "date", "DATE", "[ECMA-376] part 4, section 2.16.5.18", Volatile},
+	{0x20, "time", "TIME", "[ECMA-376] part 4, section 2.16.5.72", Volatile},
+	{0x21, "page", "PAGE", "[ECMA-376] part 4, section 2.16.5.51", Volatile},
+	{0x22, "equals", "=", "[ECMA-376] part 4, section 2.16.3.3", Static},
+	{0x23, "quote", "QUOTE", "[ECMA-376] part 4, section 2.16.5.56", Static},
+	{0x24, "include", "INCLUDE", "[ECMA-376] part 4, section 2.16.5.34 (as INCLUDETEXT)", External},
+	{0x25, "pageref", "PAGEREF", "[ECMA-376] part 4, section 2.16.5.52", Volatile},
+	{0x26, "ask", "ASK", "[ECMA-376] part 4, section 2.16.5.3", Volatile},
+	{0x27, "fill in", "FILLIN", "[ECMA-376] part 4, section 2.16.5.25", Volatile},
+	{0x28, "data", "DATA", "[MS-DOC] 2.9.90", Static},
+	{0x29, "next", "NEXT", "[ECMA-376] part 4, section 2.16.5.45", Static},
+	{0x2A, "next if", "NEXTIF", "[ECMA-376] part 4, section 2.16.5.46", Static},
+	{0x2B, "skip if", "SKIPIF", "[ECMA-376] part 4, section 2.16.5.65", Static},
+	{0x2C, "merge rec", "MERGEREC", "[ECMA-376] part 4, section 2.16.5.43", Static},
+	{0x2D, "dde", "DDE", "[MS-OE376] part 2, section 1.3.2.1", External},
+	{0x2E, "dde auto", "DDEAUTO", "[MS-OE376] part 2, section 1.3.2.2", External},
+	{0x2F, "glossary", "GLOSSARY", "[ECMA-376] part 4, section 2.16.5.8 (as AUTOTEXT)", Static},
+	{0x30, "print", "PRINT", "[ECMA-376] part 4, section 2.16.5.53", Static},
+	{0x31, "eq", "EQ", "[ECMA-376] part 4, section 2.16.5.22", Static},
+	{0x32, "goto button", "GOTOBUTTON", "[ECMA-376] part 4, section 2.16.5.29", Interactive},
+	{0x33, "macro button", "MACROBUTTON", "[ECMA-376] part 4, section 2.16.5.41", Interactive},
+	{0x34, "auto num out", "AUTONUMOUT", "[ECMA-376] part 4, section 2.16.5.7", Static},
+	{0x35, "auto num gl", "AUTONUMLGL", "[ECMA-376] part 4, section 2.16.5.6", Static},
+	{0x36, "auto num", "AUTONUM", "[ECMA-376] part 4, section 2.16.5.5", Static},
+	{0x37, "import", "IMPORT", "[ECMA-376] part 4, section 2.16.5.33", External},
+	{0x38, "link", "LINK", "[ECMA-376] part 4, section 2.16.5.39", External},
+	{0x39, "symbol", "SYMBOL", "[ECMA-376] part 4, section 2.16.5.68", Static},
+	{0x3A, "embed", "EMBED", "[MS-DOC] 2.9.90", Static},
+	{0x3B, "merge field", "MERGEFIELD", "[ECMA-376] part 4, section 2.16.5.42", Static},
+	{0x3C, "user name", "USERNAME", "[ECMA-376] part 4, section 2.16.5.78", Volatile},
+	{0x3D, "user initials", "USERINITIALS", "[ECMA-376] part 4, section 2.16.5.77", Volatile},
+	{0x3E, "user address", "USERADDRESS", "[ECMA-376] part 4, section 2.16.5.76", Volatile},
+	{0x3F, "barcode", "BARCODE", "[ECMA-376] part 4, section 2.16.5.10", Static},
+	{0x40, "doc variable", "DOCVARIABLE", "[ECMA-376] part 4, section 2.16.5.20", Static},
+	{0x41, "section", "SECTION", "[ECMA-376] part 4, section 2.16.5.61", Volatile},
+	{0x42, "section pages", "SECTIONPAGES", "[ECMA-376] part 4, section 2.16.5.62", Volatile},
+	{0x43, "include picture", "INCLUDEPICTURE", "[ECMA-376] part 4, section 2.16.5.33", External},
+	{0x44, "include text", "INCLUDETEXT", "[ECMA-376] part 4, section 2.16.5.34", External},
+	{0x45, "file size", "FILESIZE", "[ECMA-376] part 4, section 2.16.5.24", Volatile},
+	{0x46, "form text", "FORMTEXT", "[ECMA-376] part 4, section 2.16.5.28", Interactive},
+	{0x47, "form checkbox", "FORMCHECKBOX", "[ECMA-376] part 4, section 2.16.5.26", Interactive},
+	{0x48, "note ref", "NOTEREF", "[ECMA-376] part 4, section 2.16.5.47", Static},
+	{0x49, "TOA", "TOA", "[ECMA-376] part 4, section 2.16.5.74", Static},
+	{0x4B, "merge seq", "MERGESEQ", "[ECMA-376] part 4, section 2.16.5.44", Static},
+	{0x4E, "database", "DATABASE", "[ECMA-376] part 4, section 2.16.5.17", Static},
+	{0x4F, "auto text", "AUTOTEXT", "[ECMA-376] part 4, section 2.16.5.8", Static},
+	{0x50, "compare", "COMPARE", "[ECMA-376] part 4, section 2.16.5.15", Static},
+	{0x51, "add in", "ADDIN", "[MS-DOC] 2.9.90", Static},
+	{0x53, "form dropdown", "FORMDROPDOWN", "[ECMA-376] part 4, section 2.16.5.27", Interactive},
+	{0x54, "advance", "ADVANCE", "[ECMA-376] part 4, section 2.16.5.2", Static},
+	{0x55, "doc property", "DOCPROPERTY", "[ECMA-376] part 4, section 2.16.5.19", Static},
+	{0x57, "control", "CONTROL", "[MS-DOC] 2.9.90", Interactive},
+	{0x58, "hyperlink", "HYPERLINK", "[ECMA-376] part 4, section 2.16.5.31", External},
+	{0x59, "auto text list", "AUTOTEXTLIST", "[ECMA-376] part 4, section 2.16.5.9", Static},
+	{0x5A, "list number", "LISTNUM", "[ECMA-376] part 4, section 2.16.5.40", Static},
+	{0x5B, "html control", "HTMLCONTROL", "[MS-DOC] 2.9.90", Interactive},
+	{0x5C, "bidi outline", "BIDIOUTLINE", "[ECMA-376] part 4, section 2.16.5.12", Static},
+	{0x5D, "address block", "ADDRESSBLOCK", "[ECMA-376] part 4, section 2.16.5.1", Static},
+	{0x5E, "greeting line", "GREETINGLINE", "[ECMA-376] part 4, section 2.16.5.30", Static},
+	{0x5F, "shape", "SHAPE", "[ECMA-376] part 4, section 2.16.5.56 (as QUOTE)", Static},
+}
+
+// the indexes of the entries of fieldTypes, by code and by name, and the codes of the types by the keywords that start field instructions
+// (as written in OOXML documents, where fields are marked up by their instruction text)
+var (
+	typeCodes     = make(map[byte]int)
+	typeNames     = make(map[string]int)
+	fieldKeywords = make(map[string]byte)
+)
+
+func init() {
+	for i, t := range fieldTypes {
+		typeCodes[t.Code], typeNames[t.Name] = i, i
+		if t.Keyword != "" {
+			fieldKeywords[t.Keyword] = t.Code
+		}
+	}
+}
+
+// FieldTypes returns the registry of field types, in order of their codes
+func FieldTypes() []FieldType {
+	return append([]FieldType(nil), fieldTypes...)
+}
+
+// FieldName returns the name of the field type with the given code (the flt of a field begin marker), and whether the code is one doctool knows.
+// Only the low 7 bits of the flt are the field type, so the code is masked with 0x7F (unlike the field character before it, see matchField). An unknown code gets a name that includes the masked code, e.g. "unknown (0x60)".
+func FieldName(code byte) (name string, known bool) {
+	if i, ok := typeCodes[code&0x7F]; ok {
+		return fieldTypes[i].Name, true
+	}
+	return fmt.Sprintf("unknown (0x%02X)", code&0x7F), false
+}
+
+// Type returns the entry in the registry for the field's type, or false if it is of a type doctool doesn't know
+func (f Field) Type() (FieldType, bool) {
+	i, ok := typeNames[f.Name]
+	if !ok {
+		return FieldType{}, false
+	}
+	return fieldTypes[i], true
+}
+
+// keywordCode returns the field code for an instruction keyword, which is matched ignoring case. A formula field's keyword (=) may run into its expression, as in =2*3.
+func keywordCode(keyword string) (byte, bool) {
+	if strings.HasPrefix(keyword, "=") {
+		return 0x22, true
+	}
+	code, ok := fieldKeywords[strings.ToUpper(keyword)]
+	return code, ok
+}
@@ -0,0 +1,687 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fields reads the fields (e.g. hyperlinks, DDE links, merge fields) in MS Word documents: Word 6.0 to Word 2003 (.doc) compound files, OOXML (.docx, .docm) packages and RTF.
+// Parse a document and get a Report of the fields found in each region (body, header/footer etc.):
+//
+//	rep, err := fields.Parse(f)
+//	for _, r := range rep.Regions() {
+//		fmt.Println(r.Name, r.Fields)
+//	}
+//
+// Walk hands the fields to callbacks as each region is read instead, for very large documents and corpora.
+package fields
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/richardlehane/mscfb"
+)
+
+const (
+	UNSET int = iota
+	TAB0
+	TAB1
+	TABW // Word 6.0 and Word 95 keep the table data in the WordDocument stream
+)
+
+// the kinds of document that can be parsed, as given in a Report's Format
+const (
+	FormatDOC   = "doc"   // an OLE compound file, from Word 6.0 to Word 2003
+	FormatOOXML = "ooxml" // a .docx or .docm package
+	FormatRTF   = "rtf"
+)
+
+var (
+	ErrNoFields           error = errors.New("No fields")
+	ErrFibShort           error = errors.New("file information block too short")
+	ErrTable              error = errors.New("cannot find table stream")
+	ErrNoWordDocument     error = errors.New("cannot find WordDocument stream")
+	ErrEncrypted          error = errors.New("document is encrypted or password protected")
+	ErrUnsupportedVersion error = errors.New("unsupported Word version")
+	ErrOOXML              error = errors.New("this is a zip file, but not an OOXML (.docx, .docm) Word document")
+	ErrNotWord            error = errors.New("not a Word document: not a compound file (.doc), OOXML package or RTF document")
+	ErrTooBig             error = errors.New("too big to read") // a part of a document is bigger than Options.MaxRead
+)
+
+// Report holds the names of the fields found in each region of a word document, in document order.
+// A nil slice means the document has no field data for that region.
+type Report struct {
+	BodyFields          []string
+	HeaderFields        []string
+	FootnoteFields      []string
+	CommentFields       []string
+	EndnoteFields       []string
+	TextboxFields       []string
+	HeaderTextboxFields []string
+	Occurrences         map[string][]Field   // the details of each field in the regions above, keyed by region (body, header, footnote, comment, endnote, textbox, headertextbox)
+	Structure           map[string]Structure // counts of the field characters and the maximum nesting depth, keyed by region
+	Table               string               // the table stream used: 0Table or 1Table (or WordDocument, for Word 6.0 and Word 95 documents)
+	Unreferenced        string               // the other table stream, if the document has both
+	Macros              bool                 // the document contains a VBA project
+	Sizes               map[string]uint32    // the raw size in bytes of the field data for each region (the lcb values in the FIB), keyed by region
+	TotalSize           uint64               // the sum of Sizes
+	Offsets             map[string]uint32    // the offset of each region's field data in the table stream (the fc values in the FIB), keyed by region
+	NFib                uint16               // the FIB version
+	CbRgFcLcb           uint16               // the number of fc/lcb pairs in the FIB, which identifies versions from Word 97 on (see FcLcbVersion)
+	NFibNew             uint16               // the FIB version recorded by Word 2000 and later in the FibRgCswNew, or 0 (see WordVersion)
+	NFibBack            uint16               // the oldest FIB version that can read the document
+	TableSize           int64                // the size of the table stream in bytes
+	Pieces              int                  // the number of pieces (runs of text) in the piece table, or 0 if it can't be read. Each fast save (Flags.Complex) adds pieces for the text it changes, leaving the old text in the file
+	Flags               Flags                // the FibBase flags
+	LKey                uint32               // lKey from the FibBase: for an encrypted document, the XOR password verifier or the size of the EncryptionHeader
+	Encryption          string               // how an encrypted document is encrypted: EncryptionXOR, EncryptionRC4 or EncryptionCryptoAPI (or, for an encrypted OOXML document, a description of its encryption)
+	Metadata            []Property           // creating and last modifying applications
+	Properties          DocProperties        // title, author etc.
+	Languages           Languages            // .doc only: the languages recorded in the FIB and the text's formatting
+	CustomProperties    []Property           // the user-defined properties (e.g. a records system's document ID, which DOCPROPERTY fields can show), in the order they are stored
+	Associations        Associations         // the attached template, mail merge data source etc.
+	SavedBy             []SavedBy            // the save history (author and path of each of the last few saves), the most recent last
+	Bookmarks           []Bookmark           // in the order of their starts
+	Comments            []Comment            // in document order
+	Revisions           Revisions            // tracked changes
+	Objects             []Object             // embedded OLE objects, from the ObjectPool
+	Protection          Protection           // .doc only: how the document is protected against editing
+	FormFields          []FormField          // the text, check box and drop-down form fields, in the order of the regions and then of the fields (only a .doc from Word 97 on has their settings)
+	Pictures            []Picture            // .doc only: the pictures in the text, in the order of their formatting; nil if they couldn't be looked for (in a Word 6.0 or Word 95 document, or without the piece table)
+	Template            *Template            // for a template (Flags.Dot) from Word 97 on: its AutoText entries and command customisations; nil otherwise
+	MailMerge           MailMerge            // whether the document is a mail merge main document, and the merge fields and data source it uses
+	Hyperlinks          []Hyperlink          // the targets of the HYPERLINK fields, in the order of the regions and then of the fields
+	Unknown             []byte               // field codes (masked to their low 7 bits) with no name in the fieldNames table
+	Format              string               // the kind of document: FormatDOC, FormatOOXML or FormatRTF
+	Findings            map[string]Findings  // the findings of each registered Analyzer, keyed by the name it was registered under; nil if none are registered
+	Warnings            []string
+}
+
+// Field is a single field found in a document
+type Field struct {
+	Name string
+	Code byte   // the raw field type (flt) byte; only the low 7 bits are used to look up the name
+	CP   uint32 // the character position of the field's begin character
+	// the character positions of the field's separator and end characters, or 0 if it doesn't have one (which is normal for a separator, but a sign of damage for an end)
+	Separator, End uint32
+	Depth          int    // 1 for a field that isn't inside another
+	Instruction    string // the field's instruction text, e.g. MERGEFIELD LastName (empty if it couldn't be read)
+	Result         string // the field's result text, as it was last updated, if Options.Results asked for it (empty for a field without a separator)
+	// for a field in a textbox, footnote or endnote, the textbox story or note it is in, numbered from 1 in the order of the PlcftxbxTxt or in document order (0 if it can't be told),
+	// and, if Anchored, the CP of the textbox's anchor or the note's reference mark in the body (or, for a header/footer textbox, the header/footer), relative to the start of that region
+	Story    int
+	Anchor   uint32
+	Anchored bool
+}
+
+// Region is one of the parts of a document that can hold fields (the body, header/footer etc.)
+type Region struct {
+	Name        string
+	Key         string // short name, as used in the maps of a Report (body, header, footnote etc.)
+	Fields      []string
+	Occurrences []Field
+}
+
+// regionFields returns pointers to the region slices, in the same order as fieldRegions
+func (d *Report) regionFields() []*[]string {
+	return []*[]string{&d.BodyFields, &d.HeaderFields, &d.FootnoteFields, &d.CommentFields, &d.EndnoteFields, &d.TextboxFields, &d.HeaderTextboxFields}
+}
+
+// setRegion sets the fields of the region at index i of fieldRegions.
+// The names slice is never nil afterwards, even if there are no fields, so that the region is still reported.
+func (d *Report) setRegion(i int, fields []Field) {
+	names := make([]string, len(fields))
+	for j, f := range fields {
+		names[j] = f.Name
+	}
+	*d.regionFields()[i] = names
+	if d.Occurrences == nil {
+		d.Occurrences = make(map[string][]Field)
+	}
+	d.Occurrences[fieldRegions[i].key] = fields
+}
+
+// Regions returns the regions that have field data, in the order they are reported
+func (d *Report) Regions() []Region {
+	var regions []Region
+	for i, f := range d.regionFields() {
+		if *f != nil {
+			regions = append(regions, Region{fieldRegions[i].name, fieldRegions[i].key, *f, d.Occurrences[fieldRegions[i].key]})
+		}
+	}
+	return regions
+}
+
+// AllRegions returns all seven regions, in the order they are reported, including those without any field data
+func (d *Report) AllRegions() []Region {
+	regions := make([]Region, len(fieldRegions))
+	for i, f := range d.regionFields() {
+		regions[i] = Region{fieldRegions[i].name, fieldRegions[i].key, *f, d.Occurrences[fieldRegions[i].key]}
+	}
+	return regions
+}
+
+// Filter keeps only the fields for which keep returns true, dropping any regions left without fields
+func (d *Report) Filter(keep func(Field) bool) {
+	for i, r := range d.regionFields() {
+		var fields []Field
+		for _, f := range d.Occurrences[fieldRegions[i].key] {
+			if keep(f) {
+				fields = append(fields, f)
+			}
+		}
+		if len(fields) == 0 {
+			*r = nil
+			delete(d.Occurrences, fieldRegions[i].key)
+			continue
+		}
+		d.setRegion(i, fields)
+	}
+}
+
+// Counts returns the number of times each field type occurs across all the regions of the document
+func (d *Report) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, f := range d.regionFields() {
+		for _, name := range *f {
+			counts[name]++
+		}
+	}
+	return counts
+}
+
+// the regions of a document that can hold fields, each with the place in the FibRgFcLcb of the offset (fc) and size (lcb) of its field data.
+// The pairs are listed, in order, in the fib_bits.txt doc in this repo (which has separate lines for each fc and lcb).
+var fieldRegions = []struct {
+	name  string
+	key   string // short name used in structured output
+	index int    // the index of the fc/lcb pair, e.g. 16 for fcPlcfFldMom/lcbPlcfFldMom
+	text  int    // the place of the region's text in the document's text (see ccps); its fields' CPs are relative to the start of it
+}{
+	{"Document body", "body", 16, 0},
+	{"Header/footer", "header", 17, 2},
+	{"Footnote", "footnote", 18, 1},
+	{"Comment", "comment", 19, 4},
+	{"Endnote", "endnote", 48, 5},
+	{"Textbox", "textbox", 57, 6},
+	{"Header/footer textbox", "headertextbox", 59, 7},
+}
+
+// RegionKeys returns the short names of the regions (body, header, footnote etc.), in the order they are reported
+func RegionKeys() []string {
+	keys := make([]string, len(fieldRegions))
+	for i, fr := range fieldRegions {
+		keys[i] = fr.key
+	}
+	return keys
+}
+
+func wrapError(e error) error {
+	return fmt.Errorf("Error processing file: %w", e) // wrap so that callers can still test for our errors with errors.Is
+}
+
+// matchField reports whether a is the field character b (0x13, 0x14 or 0x15), from the fldch byte of a Fld.
+// Only the low 5 bits of fldch are the character type (ch); the high 3 bits are reserved, and Word doesn't always leave them clear (e.g. 0x74 for a separator),
+// so the mask is 0x1F. The flt byte that follows a begin is the field type, with its own, 7-bit, mask (see FieldName).
+func matchField(a, b byte) bool {
+	return a&0x1F == b
+}
+
+// Structure counts the field characters in a region's field data, and the deepest nesting of fields.
+// Every field has a begin and an end character (and usually a separator between its instructions and result), so equal numbers of begins and ends are expected.
+// The later counts are of characters that don't pair up, which are signs of damaged field data.
+type Structure struct {
+	Begins          int // 0x13
+	Separators      int // 0x14
+	Ends            int // 0x15
+	MaxDepth        int
+	Unclosed        int // fields whose begin has no matching end
+	StrayEnds       int // ends that don't close a field
+	StraySeparators int // separators outside any field, or after the first in a field
+	Invalid         int // entries in a .doc's PlcFld that aren't a begin, separator or end
+	Unordered       int // entries in a .doc's PlcFld whose CP isn't after the one before
+}
+
+// Malformed reports whether any of the field characters don't pair up, or the field data has entries that can't be field characters
+func (s Structure) Malformed() bool {
+	return s.Unclosed > 0 || s.StrayEnds > 0 || s.StraySeparators > 0 || s.Invalid > 0 || s.Unordered > 0
+}
+
+func malformedWarning(region string, st Structure) string {
+	w := fmt.Sprintf("%s region has %d unclosed fields, %d end markers and %d separators that don't belong to a field", region, st.Unclosed, st.StrayEnds, st.StraySeparators)
+	if st.Invalid > 0 {
+		w += fmt.Sprintf(", %d entries that aren't field characters", st.Invalid)
+	}
+	if st.Unordered > 0 {
+		w += fmt.Sprintf(", %d field characters out of order", st.Unordered)
+	}
+	return w + "; the field data may be malformed"
+}
+
+// process the field data, extracting the names of fields from their begin characters (see fieldnames.go) and pairing each begin with its separator and end.
+// Also returns the counts of the field characters and any field codes that have no entry in the fieldNames table.
+// lcb is the size of the field data given in the FIB, which is more than len(b) if only the start of it could be read: the number of fields, and so where the Flds start, depends on it.
+func processField(b []byte, lcb uint32) ([]Field, Structure, []byte) {
+	var fields []Field
+	var st Structure
+	var unknown []byte
+	if len(b) < 4 || lcb < 4 { // too short to hold even a single CP, so malformed
+		return nil, st, nil
+	}
+	numDataElements := int((lcb - 4) / 6) // the plex is n+1 4-byte CPs followed by n 2-byte Flds
+	ignore := numDataElements*4 + 4       // igore the CP section of the field data
+	var open []int                        // indexes in fields of the fields begun but not yet ended, innermost last
+	for i := 0; i < numDataElements*2 && ignore+i+1 < len(b); i = i + 2 {
+		cp := binary.LittleEndian.Uint32(b[i*2 : i*2+4]) // the Fld at i/2 is paired with the CP at the same index
+		if i > 0 && cp <= binary.LittleEndian.Uint32(b[i*2-4:i*2]) {
+			st.Unordered++
+		}
+		switch {
+		case matchField(b[ignore+i], 0x13): // the start of a field
+			st.Begins++
+			code := b[ignore+i+1]
+			name, ok := FieldName(code)
+			if !ok {
+				unknown = append(unknown, code&0x7F) // mask as FieldName does, so a code is reported the same way whether or not its high bit is set
+			}
+			fields = append(fields, Field{Name: name, Code: code, CP: cp, Depth: len(open) + 1})
+			open = append(open, len(fields)-1)
+			if len(open) > st.MaxDepth {
+				st.MaxDepth = len(open)
+			}
+		case matchField(b[ignore+i], 0x14):
+			st.Separators++
+			if len(open) == 0 || fields[open[len(open)-1]].Separator != 0 {
+				st.StraySeparators++
+				continue
+			}
+			fields[open[len(open)-1]].Separator = cp
+		case matchField(b[ignore+i], 0x15):
+			st.Ends++
+			if len(open) == 0 {
+				st.StrayEnds++
+				continue
+			}
+			fields[open[len(open)-1]].End = cp
+			open = open[:len(open)-1]
+		default:
+			st.Invalid++
+		}
+	}
+	st.Unclosed = len(open)
+	return fields, st, unknown
+}
+
+// Read is like Parse, for documents that come from an io.Reader.
+// The compound file format needs random access, so if r isn't also an io.ReaderAt (e.g. it is an archive member or a network stream) it is read into memory first.
+func Read(r io.Reader) (*Report, error) {
+	return ReadContext(context.Background(), r)
+}
+
+// ReadContext is like Read, but stops and returns ctx.Err() if ctx is cancelled (see ParseContext).
+func ReadContext(ctx context.Context, r io.Reader) (*Report, error) {
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		buf, err := io.ReadAll(r)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		ra = bytes.NewReader(buf)
+	}
+	return ParseContext(ctx, ra)
+}
+
+// the signature at the start of a compound file
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// sniff returns the format of a document from its signature (the zip signature for OOXML), or an empty string if it isn't one of the formats doctool reads
+func sniff(ra io.ReaderAt) string {
+	sig := make([]byte, len(cfbSignature))
+	n, _ := ra.ReadAt(sig, 0)
+	sig = sig[:n]
+	switch {
+	case bytes.Equal(sig, cfbSignature):
+		return FormatDOC
+	case bytes.HasPrefix(sig, []byte("PK\x03\x04")):
+		return FormatOOXML
+	case bytes.HasPrefix(sig, []byte("{\\rtf")):
+		return FormatRTF
+	}
+	return ""
+}
+
+// Parse reads a word doc (or an OOXML package or RTF document) and returns the fields found in each of its regions.
+// When the document has no field data at all, the report is returned along with ErrNoFields.
+// An encrypted document's report, returned along with ErrEncrypted (wrapped along with the method of encryption), only has its FIB version, flags, lKey and Encryption, and Macros.
+func Parse(ra io.ReaderAt) (*Report, error) {
+	return ParseContext(context.Background(), ra)
+}
+
+// ParseContext is like Parse, but stops and returns ctx.Err() if ctx is cancelled.
+// The context is checked between OLE entries and before each read from the table stream, so a single slow read can't be interrupted, but a batch run can stop promptly.
+func ParseContext(ctx context.Context, ra io.ReaderAt) (*Report, error) {
+	return ParseWithOptions(ctx, ra, Options{})
+}
+
+// Options change how a document is parsed. The zero value gives the same results as Parse.
+type Options struct {
+	// Lenient recovers what it can from a damaged .doc, with a warning for each part it had to guess at, rather than giving up on that part:
+	// a FIB that is cut short by the end of the WordDocument stream is read as though the missing bytes were zeros, and the field data of a region
+	// that runs past the end of the table stream is read up to the end of the stream.
+	Lenient bool
+	// MaxRead, if it is more than 0, is the most that is read into memory at once, in bytes: a part of a .doc (e.g. a region's field data or the piece table)
+	// that the FIB says is bigger is skipped with a warning, and an RTF document (which is read whole) that is bigger gives ErrTooBig.
+	// This stops damaged or hostile sizes from exhausting memory in a batch run.
+	MaxRead int64
+	// Logger, if it isn't nil, is given diagnostics about how a document is read: the streams found, the table stream chosen and why,
+	// where each region's field data is read from, and (as warnings) the parts that were clamped or skipped. At the debug level every read
+	// of the WordDocument and table streams is logged too. The report itself is not logged.
+	Logger *slog.Logger
+	// Results reads each field's result text (see Field.Result), the text Word last rendered for it, which can differ from what its instruction says it is,
+	// e.g. a DATE or DOCPROPERTY field that hasn't been updated. Like instructions, results are cut short at 4096 characters.
+	Results bool
+
+	walker *Walker // set by Walk
+}
+
+// limited is a stream whose reads are checked against Options.MaxRead before the buffer for them is allocated (see checkSize)
+type limited struct {
+	io.ReaderAt
+	max int64
+}
+
+// checkSize returns an error wrapping ErrTooBig if n bytes are more than r may read at once, which is only the case for a limited stream
+func checkSize(r io.ReaderAt, n int64) error {
+	if l, ok := r.(limited); ok && n > l.max {
+		return fmt.Errorf("%w: %d bytes, over the limit of %d", ErrTooBig, n, l.max)
+	}
+	return nil
+}
+
+// ParseWithOptions is like ParseContext, with Options.
+// Any analyzers registered with RegisterAnalyzer are then run over the document, unless it couldn't be read at all.
+func ParseWithOptions(ctx context.Context, ra io.ReaderAt, opts Options) (*Report, error) {
+	doc := &Document{File: ra}
+	res, err := parseDocument(ctx, ra, opts, doc)
+	if res != nil && ctx.Err() == nil {
+		res.analyze(doc)
+	}
+	return res, err
+}
+
+// parseDocument does the work of ParseWithOptions, setting the streams of parts that a .doc is read from as it finds them
+func parseDocument(ctx context.Context, ra io.ReaderAt, opts Options, parts *Document) (*Report, error) {
+	log := opts.logger()
+	// sniff the signature before handing over to mscfb: .docx and .docm files are zip packages, and RTF files are text
+	format := sniff(ra)
+	log.Debug("format", "format", format)
+	switch format {
+	case FormatOOXML:
+		return parseOOXML(ctx, ra, opts.Results)
+	case FormatRTF:
+		return parseRTF(ctx, ra, opts.MaxRead, opts.Results)
+	case "":
+		return nil, wrapError(ErrNotWord)
+	}
+	doc, err := mscfb.New(ra)
+	if err != nil {
+		return nil, wrapError(err) // not an OLE file?
+	}
+	parts.Entries = doc.File
+	res := &Report{Format: FormatDOC}
+	defer func() { // the warnings are the parts that were clamped, skipped or guessed at
+		for _, w := range res.Warnings {
+			log.Warn(w)
+		}
+	}()
+	// index the entries in one pass over the directory, rather than looking through them for each stream that is needed
+	idx := indexEntries(doc)
+	if log.Enabled(ctx, slog.LevelDebug) {
+		for _, entry := range doc.File {
+			log.Debug("entry", "name", entry.Name, "size", entry.Size)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var table *mscfb.File
+	table0, table1, wordDoc := idx.top["0Table"], idx.top["1Table"], idx.top["WordDocument"]
+	if wordDoc == nil {
+		if idx.top["EncryptedPackage"] != nil { // an encrypted .docx is a compound file wrapping the zip package
+			res.Format, res.Encryption = FormatOOXML, packageEncryption(idx.top["EncryptionInfo"])
+			return res, wrapError(fmt.Errorf("%w (%s)", ErrEncrypted, res.Encryption))
+		}
+		return nil, wrapError(ErrNoWordDocument) // without a FIB we can't tell which table stream to use, or where the fields are
+	}
+	fib, fcLcb, err := readFIB(wordDoc)
+	if err == ErrFibShort && opts.Lenient {
+		fib, fcLcb, err = readFIB(zeroPadded{wordDoc})
+		res.Warnings = append(res.Warnings, fmt.Sprintf("the FIB is cut short by the end of the WordDocument stream (%d bytes): the missing bytes were read as zeros", wordDoc.Size))
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	parts.WordDocument, parts.FIB = wordDoc, fib
+	res.NFib = binary.LittleEndian.Uint16(fib[2:4])
+	if !isWord6(res.NFib) {
+		res.CbRgFcLcb = cbRgFcLcb(fib)
+	}
+	res.Flags = decodeFlags(fib)
+	res.NFibBack, res.NFibNew = binary.LittleEndian.Uint16(fib[12:14]), nFibNew(fib)
+	res.LKey = binary.LittleEndian.Uint32(fib[14:18])
+	res.Languages.Install = binary.LittleEndian.Uint16(fib[6:8])
+	if res.Flags.FarEast && !isWord6(res.NFib) && binary.LittleEndian.Uint16(fib[32:34]) >= 14 { // lidFE is the 14th word of the FibRgW
+		res.Languages.FarEast = binary.LittleEndian.Uint16(fib[60:62])
+	}
+	log.Info("fib", "nfib", res.NFib, "cbrgfclcb", res.CbRgFcLcb, "whichtblstm", res.Flags.WhichTblStm, "size", wordDoc.Size)
+	if res.Flags.Encrypted { // the table stream is encrypted too, so any field data we found would be garbage
+		t := table0
+		if res.Flags.WhichTblStm {
+			t = table1
+		}
+		res.Encryption = docEncryption(isWord6(res.NFib), res.Flags, t)
+		res.Macros = idx.macros
+		return res, wrapError(fmt.Errorf("%w (%s)", ErrEncrypted, res.Encryption))
+	}
+	whichTable := TAB0 // set which table (0Table or 1Table) is the table stream. Do this because a doc can have both but only one will be referenced. It is marked by the fWhichTblStm bit of the FIB flags.
+	if res.Flags.WhichTblStm {
+		whichTable = TAB1
+	}
+	if isWord6(res.NFib) { // there is no separate table stream
+		whichTable = TABW
+	}
+	var perr error
+	res.Metadata, perr = readMetadata(fib, idx.top["SummaryInformation"])
+	if res.Properties, err = readProperties(idx.top["SummaryInformation"], idx.top["DocumentSummaryInformation"]); err != nil {
+		perr = err // it covers the SummaryInformation too, so a damaged one is only reported once
+	}
+	if perr != nil {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("the document properties can't be read: %v", perr))
+	}
+	if res.CustomProperties, err = readCustomProperties(idx.top["DocumentSummaryInformation"]); err != nil {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("the custom properties can't all be read: %v", err))
+	}
+	res.Macros = idx.macros
+	res.Objects = readObjects(doc, ansiCodePage(fib))
+	// set the table to either 0Table or 1Table stream
+	switch whichTable {
+	case TAB0:
+		if table0 == nil {
+			log.Error("table stream not found", "want", "0Table", "fwhichtblstm", false, "has1table", table1 != nil)
+			return nil, wrapError(ErrTable)
+		}
+		table = table0
+	case TAB1:
+		if table1 == nil {
+			log.Error("table stream not found", "want", "1Table", "fwhichtblstm", true, "has0table", table0 != nil)
+			return nil, wrapError(ErrTable)
+		}
+		table = table1
+	case TABW:
+		table = wordDoc
+	}
+	res.Table, res.TableSize = table.Name, table.Size
+	parts.Table = table
+	// a document can have both table streams; note the one that isn't referenced (we may have stopped iterating before reaching it)
+	other := "0Table"
+	if table.Name == "0Table" {
+		other = "1Table"
+	}
+	if whichTable != TABW && idx.top[other] != nil {
+		res.Unreferenced = other
+	}
+	log.Info("table stream", "name", table.Name, "size", table.Size, "unreferenced", res.Unreferenced)
+	// the streams that the tables and text are read from, with any limit on the size of each read
+	var tableR, docR io.ReaderAt = table, wordDoc
+	if log.Enabled(ctx, slog.LevelDebug) {
+		tableR, docR = logged{table, table.Name, log}, logged{wordDoc, wordDoc.Name, log}
+	}
+	if opts.MaxRead > 0 {
+		tableR, docR = limited{tableR, opts.MaxRead}, limited{docR, opts.MaxRead}
+	}
+	// Get offsets (in table stream) and sizes of field data from the FibRgFcLcb section of the FIB.
+	// Regions whose pairs are beyond the end of a short FibRgFcLcb are left out of Sizes and Offsets, with a warning.
+	res.Sizes, res.Offsets = make(map[string]uint32), make(map[string]uint32)
+	for _, fr := range fieldRegions {
+		p := fr.index * 8
+		if p+8 > len(fcLcb) {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s field data can't be located: the FIB only has %d of the fc/lcb pairs that locate parts of the document (cbRgFcLcb is %d)", fr.name, len(fcLcb)/8, res.CbRgFcLcb))
+			continue
+		}
+		lcb := binary.LittleEndian.Uint32(fcLcb[p+4 : p+8]) // Interpret the bytes as an unsigned 32-bit integer in little endian order
+		res.Sizes[fr.key] = lcb
+		res.Offsets[fr.key] = binary.LittleEndian.Uint32(fcLcb[p : p+4])
+		res.TotalSize += uint64(lcb) // a uint64 so that the total can't wrap around to zero
+	}
+	// the piece table and the lengths of the parts of the text are needed to read the instruction text of each field (and the text of comments)
+	pieces, err := loadPieces(tableR, table.Size, fib, fcLcb)
+	res.Pieces = len(pieces)
+	counts := ccps(fib)
+	log.Info("text", "pieces", res.Pieces, "ccps", counts)
+	res.readTables(tableR, docR, table.Size, wordDoc.Size, fcLcb, pieces, counts)
+	if res.Flags.Dot && !isWord6(res.NFib) {
+		res.readTemplate(docR, wordDoc.Size, tableR, table.Size, fib, fcLcb)
+	}
+	if !isWord6(res.NFib) && pieces != nil && counts != nil {
+		var data io.ReaderAt
+		var dataSize int64
+		if f := idx.top["Data"]; f != nil {
+			data, dataSize = f, f.Size
+		}
+		if err := res.readPictures(docR, wordDoc.Size, data, dataSize, tableR, table.Size, fcLcb, pieces, counts, ansiCodePage(fib)); err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("the pictures can't all be found: %v", err))
+		}
+	}
+	if res.TotalSize == 0 {
+		res.setMailMerge()      // a main document may not have any merge fields yet
+		return res, ErrNoFields // no fields
+	}
+	if err != nil {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("field instructions can't be read: %v", err))
+	}
+	if pieces != nil && counts == nil {
+		res.Warnings = append(res.Warnings, "field instructions can't be read: the FIB doesn't have the lengths of the parts of the document")
+	}
+	stories := readStories(tableR, table.Size, fcLcb, res.NFib) // read once, for the regions with a Walk (each placed before it is handed over) or all together after them
+	// now for each offset and length pair, read just those bytes from the table stream (after checking that they are within the bounds of the stream).
+	// This keeps memory use down to the size of the largest field region, rather than the whole table stream.
+	for i, fr := range fieldRegions {
+		o, l := res.Offsets[fr.key], res.Sizes[fr.key]
+		if l == 0 {
+			continue
+		}
+		if lcb := res.Sizes[fr.key]; lcb < 4 || (lcb-4)%6 != 0 { // without the right size, there's no telling where the CPs end and the Flds start
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s field data is %d bytes, which isn't the size of a PlcFld (4 bytes, and 6 for each field character), so it was skipped", fr.name, lcb))
+			continue
+		}
+		if uint64(o)+uint64(l) > uint64(table.Size) { // add as uint64 so that large values can't wrap around and pass the check
+			if !opts.Lenient || int64(o) >= table.Size {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s field data (offset %d, length %d) is outside the table stream (%d bytes)", fr.name, o, l, table.Size))
+				continue
+			}
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s field data (offset %d, length %d) runs past the end of the table stream (%d bytes): only the first %d bytes were read", fr.name, o, l, table.Size, table.Size-int64(o)))
+			l = uint32(table.Size - int64(o))
+		}
+		if err := checkSize(tableR, int64(l)); err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s field data (offset %d, length %d) was skipped: %v", fr.name, o, l, err))
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		log.Info("field data", "region", fr.key, "offset", o, "length", l)
+		bp := getBuf(int(l))
+		buf := *bp
+		if n, err := tableR.ReadAt(buf, int64(o)); n < len(buf) { // ReadAt can return io.EOF along with all the bytes, so check n rather than err
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				putBuf(bp)
+				return nil, wrapError(err)
+			}
+			// the stream has fewer readable bytes than its declared size: parse what we have rather than reporting a malformed document as empty
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s field data (offset %d, length %d) was truncated to %d bytes: the table stream is shorter than its declared size (%d bytes)", fr.name, o, l, n, table.Size))
+			if n == 0 {
+				putBuf(bp)
+				continue
+			}
+			buf = buf[:n]
+		}
+		fields, st, unknown := processField(buf, res.Sizes[fr.key]) // the size in the FIB, as l may have been cut to fit the table stream
+		putBuf(bp)
+		res.Unknown = append(res.Unknown, unknown...)
+		if len(fields) == 0 { // there is field data, but none of it starts with the 0x13 begin marker
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s region has %d bytes of field data but no field begin markers; the document may use a field-marking scheme doctool does not support", fr.name, l))
+		}
+		if pieces != nil && counts != nil {
+			var base uint32
+			for _, c := range counts[:fr.text] {
+				base += c
+			}
+			if err := readInstructions(docR, pieces, base, fields, opts.Results); err != nil {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s field instructions can't all be read: %v", fr.name, err))
+			}
+		}
+		res.setRegion(i, fields)
+		if st.Malformed() {
+			res.Warnings = append(res.Warnings, malformedWarning(fr.name, st))
+		}
+		if res.Structure == nil {
+			res.Structure = make(map[string]Structure)
+		}
+		res.Structure[fr.key] = st
+		if opts.walker != nil { // place the fields of notes and textboxes while they are still held, then hand them over
+			res.setStories(stories)
+			if err := res.walkRegion(i, opts.walker); err != nil {
+				return nil, err
+			}
+		}
+	}
+	res.setStories(stories)
+	res.setHyperlinks()
+	res.setPictureFields()
+	res.setFormFields()
+	res.setMailMerge()
+	if data := idx.top["Data"]; data != nil && res.Hyperlinks != nil && !isWord6(res.NFib) && pieces != nil && counts != nil { // Word 6.0 and Word 95 don't store hyperlink data
+		if err := res.readHlinks(docR, wordDoc.Size, data, data.Size, tableR, table.Size, fcLcb, pieces, counts, ansiCodePage(fib)); err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("the hyperlink data can't be read: %v", err))
+		}
+	}
+	if data := idx.top["Data"]; data != nil && res.FormFields != nil && !isWord6(res.NFib) && pieces != nil && counts != nil { // Word 6.0 and Word 95 FFData are laid out differently
+		if err := res.readFormFields(docR, wordDoc.Size, data, data.Size, tableR, table.Size, fcLcb, pieces, counts); err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("the form fields' settings can't be read: %v", err))
+		}
+	}
+	return res, nil
+}
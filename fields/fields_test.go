@@ -0,0 +1,218 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// parseFixture parses a file in testdata
+func parseFixture(t testing.TB, name string) (*Report, error) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// regionNames gives the field names of each region that has any, keyed by region
+func regionNames(res *Report) map[string][]string {
+	names := make(map[string][]string)
+	if res == nil {
+		return names
+	}
+	for _, r := range res.AllRegions() {
+		if len(r.Fields) > 0 {
+			names[r.Key] = r.Fields
+		}
+	}
+	return names
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		file       string
+		fields     map[string][]string // the field names in each region that has any, in document order
+		hyperlinks []string            // the targets of the HYPERLINK fields
+		err        error
+	}{
+		{"headerfooter.doc", map[string][]string{"body": {"date"}, "header": {"file size"}}, nil, nil},
+		{"hyperlink.doc", map[string][]string{"body": {"hyperlink"}, "header": {"file size"}}, []string{"http://ex.co/a"}, nil},
+		{"nofields.doc", map[string][]string{}, nil, ErrNoFields},
+		{"encrypted.doc", map[string][]string{}, nil, ErrEncrypted},
+		{"notable.doc", map[string][]string{}, nil, ErrTable},
+		{"shortfib.doc", map[string][]string{}, nil, ErrFibShort},
+		{"noworddocument.doc", map[string][]string{}, nil, ErrNoWordDocument},
+		{"notword.txt", map[string][]string{}, nil, ErrNotWord},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			res, err := parseFixture(t, tt.file)
+			if tt.err == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got := regionNames(res); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("got fields %v, want %v", got, tt.fields)
+			}
+			var targets []string
+			if res != nil {
+				for _, h := range res.Hyperlinks {
+					targets = append(targets, h.Target)
+				}
+			}
+			if !reflect.DeepEqual(targets, tt.hyperlinks) {
+				t.Errorf("got hyperlinks %v, want %v", targets, tt.hyperlinks)
+			}
+		})
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	res, err := parseFixture(t, "headerfooter.doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"body": `DATE \@ "d/MM/yyyy h:mm:ss am/pm"`, "header": `FILESIZE   \* MERGEFORMAT`}
+	for key, instr := range want {
+		if occs := res.Occurrences[key]; len(occs) != 1 || occs[0].Instruction != instr {
+			t.Errorf("%s: got %+v, want one field with the instruction %q", key, occs, instr)
+		}
+	}
+}
+
+func TestMatchField(t *testing.T) {
+	tests := []struct {
+		fldch, ch byte
+		want      bool
+	}{
+		{0x13, 0x13, true},
+		{0x14, 0x14, true},
+		{0x15, 0x15, true},
+		{0x74, 0x14, true}, // the reserved bits set, as Word does
+		{0xD5, 0x15, true},
+		{0x13, 0x14, false},
+		{0x01, 0x13, false},
+	}
+	for _, tt := range tests {
+		if got := matchField(tt.fldch, tt.ch); got != tt.want {
+			t.Errorf("matchField(0x%02X, 0x%02X) = %v, want %v", tt.fldch, tt.ch, got, tt.want)
+		}
+	}
+}
+
+// fld is a Fld: the field character (fldch) and, for a begin, the field type (flt)
+type fld struct {
+	cp        uint32
+	fldch, ft byte
+}
+
+// plcFld builds the field data of a region: the CP of each Fld, then a final CP, then the Flds
+func plcFld(flds ...fld) []byte {
+	var b []byte
+	for _, f := range flds {
+		b = binary.LittleEndian.AppendUint32(b, f.cp)
+	}
+	var last uint32
+	if len(flds) > 0 {
+		last = flds[len(flds)-1].cp + 1
+	}
+	b = binary.LittleEndian.AppendUint32(b, last)
+	for _, f := range flds {
+		b = append(b, f.fldch, f.ft)
+	}
+	return b
+}
+
+func TestProcessField(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		fields []Field
+		st     Structure
+	}{
+		{
+			name:   "one field",
+			data:   plcFld(fld{0, 0x13, 0x1F}, fld{20, 0x14, 0}, fld{31, 0x15, 0}),
+			fields: []Field{{Name: "date", Code: 0x1F, CP: 0, Separator: 20, End: 31, Depth: 1}},
+			st:     Structure{Begins: 1, Separators: 1, Ends: 1, MaxDepth: 1},
+		},
+		{
+			name: "nested",
+			data: plcFld(fld{0, 0x13, 0x58}, fld{2, 0x13, 0x1F}, fld{5, 0x14, 0}, fld{8, 0x15, 0}, fld{10, 0x14, 0}, fld{12, 0x15, 0}),
+			fields: []Field{
+				{Name: "hyperlink", Code: 0x58, CP: 0, Separator: 10, End: 12, Depth: 1},
+				{Name: "date", Code: 0x1F, CP: 2, Separator: 5, End: 8, Depth: 2},
+			},
+			st: Structure{Begins: 2, Separators: 2, Ends: 2, MaxDepth: 2},
+		},
+		{
+			name:   "reserved bits set",
+			data:   plcFld(fld{0, 0x93, 0x1F}, fld{4, 0x74, 0}, fld{9, 0xD5, 0}),
+			fields: []Field{{Name: "date", Code: 0x1F, CP: 0, Separator: 4, End: 9, Depth: 1}},
+			st:     Structure{Begins: 1, Separators: 1, Ends: 1, MaxDepth: 1},
+		},
+		{
+			name:   "damaged",
+			data:   plcFld(fld{0, 0x15, 0}, fld{2, 0x14, 0}, fld{4, 0x01, 0}, fld{3, 0x13, 0x1F}),
+			fields: []Field{{Name: "date", Code: 0x1F, CP: 3, Depth: 1}},
+			st:     Structure{Begins: 1, Separators: 1, Ends: 1, MaxDepth: 1, Unclosed: 1, StrayEnds: 1, StraySeparators: 1, Invalid: 1, Unordered: 1},
+		},
+		{
+			name: "too short",
+			data: []byte{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, st, _ := processField(tt.data, uint32(len(tt.data)))
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("got fields %+v, want %+v", fields, tt.fields)
+			}
+			if st != tt.st {
+				t.Errorf("got structure %+v, want %+v", st, tt.st)
+			}
+		})
+	}
+}
+
+// TestFieldCodeMask checks that a field type (flt) with its high bit set is named as the plain code is, and that an unknown one is reported masked
+func TestFieldCodeMask(t *testing.T) {
+	fields, _, unknown := processField(plcFld(fld{0, 0x13, 0x1F | 0x80}, fld{9, 0x15, 0}, fld{10, 0x13, 0x60 | 0x80}, fld{19, 0x15, 0}), 4*5+2*4)
+	if len(fields) != 2 {
+		t.Fatalf("got %d fields, want 2", len(fields))
+	}
+	if fields[0].Name != "date" || fields[0].Code != 0x9F {
+		t.Errorf("got %q with code 0x%02X, want date with the raw code 0x9F", fields[0].Name, fields[0].Code)
+	}
+	if fields[1].Name != "unknown (0x60)" || fields[1].Code != 0xE0 {
+		t.Errorf("got %q with code 0x%02X, want unknown (0x60) with the raw code 0xE0", fields[1].Name, fields[1].Code)
+	}
+	if len(unknown) != 1 || unknown[0] != 0x60 {
+		t.Errorf("got unknown codes %X, want 60", unknown)
+	}
+	if name, known := FieldName(0x1F | 0x80); name != "date" || !known {
+		t.Errorf("FieldName(0x9F) = %q, %v, want date, true", name, known)
+	}
+}
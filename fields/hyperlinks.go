@@ -0,0 +1,270 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// Hyperlink is the target of a HYPERLINK field
+type Hyperlink struct {
+	Region   string // the key of the region the field is in (body, header etc.)
+	CP       uint32 // the character position of the field's begin character, relative to the start of the region
+	Target   string // the URL or path from the field's instruction text; empty for a link to a bookmark in the document
+	Location string // the bookmark (or, for a web page, the anchor) that the link goes to, from the \l switch
+	// the URL or path and location stored in the field's hyperlink data (the HFD in a .doc's Data stream), which is what Word follows when the link is clicked.
+	// They are empty if there is no hyperlink data, and normally match the instruction text, as Word updates both together.
+	Hlink, HlinkLocation string
+}
+
+// setHyperlinks lists the targets of the hyperlink fields in the document, from their instruction text
+func (d *Report) setHyperlinks() {
+	d.Hyperlinks = nil
+	for _, r := range d.Regions() {
+		for _, f := range r.Occurrences {
+			if f.Name != "hyperlink" {
+				continue
+			}
+			_, args := Args(f.Instruction)
+			h := Hyperlink{Region: r.Key, CP: f.CP, Location: switchValue(f.Instruction, `\l`)}
+			if len(args) > 0 {
+				h.Target = args[0]
+			}
+			d.Hyperlinks = append(d.Hyperlinks, h)
+		}
+	}
+}
+
+// the sprm that gives the location in the Data stream of the data for a picture, an OLE object or a form field, or, applied to the separator of a HYPERLINK field, the field's hyperlink data
+const sprmCPicLocation = 0x6A03
+
+// CLSID_StdHlink, in its byte order in the file, which starts the Hyperlink object in the HFD
+var clsidStdHlink = []byte{0xD0, 0xC9, 0xEA, 0x79, 0xF9, 0xBA, 0xCE, 0x11, 0x8C, 0x82, 0x00, 0xAA, 0x00, 0x4B, 0xA9, 0x0B}
+
+// the longest hyperlink data read from the Data stream
+const maxHlink = 8192
+
+// readHlinks adds the targets stored in the hyperlink data of each hyperlink field. The data is located by the sprmCPicLocation in the character formatting (see scanChpx)
+// of the field's separator: it is the offset in the Data stream of a NilPICFAndBinData (a 32-bit size lcb, a 16-bit cbHeader of 0x44, and the rest of the 0x44 byte header) with the HFD in its binData.
+// codePage is the code page of a file moniker's 8-bit path (see ansiCodePage).
+func (d *Report) readHlinks(doc io.ReaderAt, docSize int64, data io.ReaderAt, dataSize int64, table io.ReaderAt, tableSize int64, fcLcb []byte, pieces []piece, counts []uint32, codePage uint16) error {
+	bte, err := readTableData(table, tableSize, fcLcb, 12)
+	if err != nil || bte == nil {
+		return err
+	}
+	bases := make(map[string]uint32)
+	for _, fr := range fieldRegions {
+		for _, c := range counts[:fr.text] {
+			bases[fr.key] += c
+		}
+	}
+	runs, err := locationRuns(doc, docSize, bte)
+	if err != nil {
+		return err
+	}
+	for i, h := range d.Hyperlinks {
+		var sep uint32
+		for _, f := range d.Occurrences[h.Region] {
+			if f.CP == h.CP {
+				sep = f.Separator
+				break
+			}
+		}
+		if off, ok := runLocation(runs, pieces, bases[h.Region]+sep); sep != 0 && ok {
+			d.Hyperlinks[i].Hlink, d.Hyperlinks[i].HlinkLocation = readHFD(data, dataSize, off, codePage)
+		}
+	}
+	return nil
+}
+
+// locationRun is a run of the document's text whose character formatting has a sprmCPicLocation
+type locationRun struct{ fcStart, fcEnd, location uint32 }
+
+// locationRuns lists the runs of the document's text that have a sprmCPicLocation (see scanChpx)
+func locationRuns(doc io.ReaderAt, docSize int64, bte []byte) ([]locationRun, error) {
+	var runs []locationRun
+	err := scanChpx(doc, docSize, bte, func(fcStart, fcEnd uint32, grpprl []byte) {
+		forSprms(grpprl, func(sprm uint16, operand []byte) {
+			if sprm == sprmCPicLocation {
+				runs = append(runs, locationRun{fcStart, fcEnd, binary.LittleEndian.Uint32(operand)})
+			}
+		})
+	})
+	return runs, err
+}
+
+// runLocation returns the sprmCPicLocation of the character at a character position in the document's text, or false if it has none (or isn't in the piece table)
+func runLocation(runs []locationRun, pieces []piece, cp uint32) (uint32, bool) {
+	fc, ok := fcOf(pieces, cp)
+	if !ok {
+		return 0, false
+	}
+	for _, r := range runs {
+		if fc >= r.fcStart && fc < r.fcEnd {
+			return r.location, true
+		}
+	}
+	return 0, false
+}
+
+// readHFD reads the target and location from the HFD at the given offset in the Data stream.
+// The HFD's Hyperlink object (see parseHyperlink) follows CLSID_StdHlink, which comes after a byte of flags.
+func readHFD(data io.ReaderAt, dataSize int64, off uint32, codePage uint16) (string, string) {
+	b := readBinData(data, dataSize, off, maxHlink)
+	i := bytes.Index(b, clsidStdHlink)
+	if i < 0 || i > 8 {
+		return "", ""
+	}
+	return parseHyperlink(b[i+16:], codePage)
+}
+
+// readBinData returns the binData (up to max bytes of it) of the NilPICFAndBinData at the given offset in the Data stream, or nil if it can't be read
+func readBinData(data io.ReaderAt, dataSize int64, off uint32, max int64) []byte {
+	if int64(off)+6 > dataSize {
+		return nil
+	}
+	hdr := make([]byte, 6)
+	if _, err := data.ReadAt(hdr, int64(off)); err != nil {
+		return nil
+	}
+	lcb, cbHeader := int64(binary.LittleEndian.Uint32(hdr)), int64(binary.LittleEndian.Uint16(hdr[4:]))
+	if cbHeader != 0x44 || lcb <= cbHeader || int64(off)+lcb > dataSize {
+		return nil
+	}
+	if lcb-cbHeader > max {
+		lcb = cbHeader + max
+	}
+	b := make([]byte, lcb-cbHeader)
+	if n, _ := data.ReadAt(b, int64(off)+cbHeader); n < len(b) {
+		return nil
+	}
+	return b
+}
+
+// the flags of a Hyperlink object that say which of its optional parts are present
+const (
+	hlstmfHasMoniker        = 0x01
+	hlstmfHasLocationStr    = 0x08
+	hlstmfHasDisplayName    = 0x10
+	hlstmfHasFrameName      = 0x80
+	hlstmfMonikerSavedAsStr = 0x100
+)
+
+// parseHyperlink reads the target and location from a Hyperlink object (MS-OSHARED): a 32-bit streamVersion and 32-bit flags,
+// then, if their flags are set, the display name, the target frame name, the moniker (or, if hlstmfMonikerSavedAsStr is set, a string) that has the target, and the location.
+// The strings are HyperlinkStrings: a 32-bit count of characters, including a terminating null, and that many UTF-16 characters.
+func parseHyperlink(b []byte, codePage uint16) (target, location string) {
+	if len(b) < 8 {
+		return "", ""
+	}
+	flags := binary.LittleEndian.Uint32(b[4:])
+	i := 8
+	str := func() (string, bool) {
+		if i+4 > len(b) {
+			return "", false
+		}
+		l := int(binary.LittleEndian.Uint32(b[i:])) * 2
+		if l > len(b)-i-4 {
+			return "", false
+		}
+		s := strings.TrimRight(utf16String(b[i+4:i+4+l]), "\x00")
+		i += 4 + l
+		return s, true
+	}
+	for _, f := range []uint32{hlstmfHasDisplayName, hlstmfHasFrameName} {
+		if flags&f != 0 {
+			if _, ok := str(); !ok {
+				return "", ""
+			}
+		}
+	}
+	if flags&hlstmfHasMoniker != 0 {
+		if flags&hlstmfMonikerSavedAsStr != 0 {
+			var ok bool
+			if target, ok = str(); !ok {
+				return "", ""
+			}
+		} else {
+			var n int
+			if target, n = parseMoniker(b[i:], codePage); n == 0 {
+				return target, ""
+			}
+			i += n
+		}
+	}
+	if flags&hlstmfHasLocationStr != 0 {
+		location, _ = str()
+	}
+	return target, location
+}
+
+// the CLSIDs, in their byte order in the file, of the monikers that hyperlinks use for URLs and files
+var (
+	clsidURLMoniker  = []byte{0xE0, 0xC9, 0xEA, 0x79, 0xF9, 0xBA, 0xCE, 0x11, 0x8C, 0x82, 0x00, 0xAA, 0x00, 0x4B, 0xA9, 0x0B}
+	clsidFileMoniker = []byte{0x03, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}
+)
+
+// parseMoniker reads the target of a URL moniker or a file moniker, returning it along with the number of bytes read.
+// It returns a size of 0 if the moniker is of another kind, or is damaged, as the size of the Hyperlink object's later parts then can't be known.
+// A URL moniker has a 32-bit size followed by the null-terminated UTF-16 URL (and sometimes more data, within the size).
+// A file moniker has a 16-bit count of "..\" prefixes, an 8-bit path (with a 32-bit size, including the null), 24 bytes of other data,
+// and then, if the path has characters that don't fit in 8 bits, a 32-bit size, a 32-bit size of the UTF-16 path that follows it, and 2 bytes of other data.
+func parseMoniker(b []byte, codePage uint16) (string, int) {
+	if len(b) < 20 {
+		return "", 0
+	}
+	clsid, b := b[:16], b[16:]
+	switch {
+	case bytes.Equal(clsid, clsidURLMoniker):
+		l := int(binary.LittleEndian.Uint32(b))
+		if l > len(b)-4 {
+			return "", 0
+		}
+		u := b[4 : 4+l]
+		for j := 0; j+2 <= len(u); j += 2 {
+			if u[j] == 0 && u[j+1] == 0 {
+				u = u[:j]
+				break
+			}
+		}
+		return utf16String(u), 16 + 4 + l
+	case bytes.Equal(clsid, clsidFileMoniker):
+		if len(b) < 6 {
+			return "", 0
+		}
+		anti := int(binary.LittleEndian.Uint16(b))
+		l := int(binary.LittleEndian.Uint32(b[2:]))
+		i := 6 + l + 24
+		if i+4 > len(b) {
+			return "", 0
+		}
+		path := strings.Repeat(`..\`, anti) + decodeString(bytes.TrimRight(b[6:6+l], "\x00"), codePage)
+		if cb := int(binary.LittleEndian.Uint32(b[i:])); cb > 0 {
+			if cb > len(b)-i-4 || cb < 6 {
+				return "", 0
+			}
+			ul := int(binary.LittleEndian.Uint32(b[i+4:]))
+			if ul <= cb-6 {
+				path = strings.Repeat(`..\`, anti) + utf16String(b[i+10:i+10+ul])
+			}
+			i += cb
+		}
+		return path, 16 + i + 4
+	}
+	return "", 0
+}
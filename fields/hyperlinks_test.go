@@ -0,0 +1,47 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// fileMoniker returns a file moniker with an 8-bit path and no UTF-16 path
+func fileMoniker(path string) []byte {
+	b := append([]byte{}, clsidFileMoniker...)
+	b = binary.LittleEndian.AppendUint16(b, 1) // one ..\
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(path)+1))
+	b = append(b, path...)
+	b = append(b, 0)
+	b = append(b, make([]byte, 24)...)
+	return binary.LittleEndian.AppendUint32(b, 0)
+}
+
+func TestParseMonikerCodePage(t *testing.T) {
+	b := fileMoniker("\xcf\xf0\xe8.doc")
+	for _, test := range []struct {
+		codePage uint16
+		want     string
+	}{
+		{1252, `..\Ïðè.doc`},
+		{1251, `..\При.doc`},
+	} {
+		got, n := parseMoniker(b, test.codePage)
+		if got != test.want || n != len(b) {
+			t.Errorf("code page %d: got %q (%d bytes), want %q (%d bytes)", test.codePage, got, n, test.want, len(b))
+		}
+	}
+}
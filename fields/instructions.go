@@ -0,0 +1,124 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import "strings"
+
+// Args splits a field's instruction text into its keyword (e.g. INCLUDETEXT) and its arguments, leaving out any switches and their values.
+// Arguments may be quoted, and backslashes are escaped by doubling them, so "\\\\server\\share" is the argument \\server\share.
+// Only the arguments before the first switch (a backslash followed by anything but another backslash, e.g. \l or \* MERGEFORMAT) are returned.
+func Args(instruction string) (keyword string, args []string) {
+	toks := tokenise(instruction)
+	if len(toks) == 0 {
+		return "", nil
+	}
+	for _, t := range toks[1:] {
+		if t.isSwitch {
+			break
+		}
+		args = append(args, t.text)
+	}
+	return toks[0].text, args
+}
+
+// switchValue returns the value that follows a switch (e.g. \l) in a field's instruction text, or an empty string if the switch isn't there or has no value
+func switchValue(instruction, sw string) string {
+	toks := tokenise(instruction)
+	for i, t := range toks {
+		if t.isSwitch && strings.EqualFold(t.text, sw) && i+1 < len(toks) && !toks[i+1].isSwitch {
+			return toks[i+1].text
+		}
+	}
+	return ""
+}
+
+type token struct {
+	text     string
+	isSwitch bool
+}
+
+// tokenise splits instruction text on white space, keeping quoted strings together and unescaping doubled backslashes and \" within them
+func tokenise(s string) []token {
+	var toks []token
+	var sb strings.Builder
+	var inToken, isSwitch, inQuotes bool
+	end := func() {
+		if inToken {
+			toks = append(toks, token{sb.String(), isSwitch})
+		}
+		sb.Reset()
+		inToken, isSwitch = false, false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			if inQuotes {
+				inQuotes = false
+				end()
+				continue
+			}
+			end()
+			inToken, inQuotes = true, true
+		case !inQuotes && (c == ' ' || c == '\t' || c == '\r' || c == '\n'):
+			end()
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '\\' || (inQuotes && s[i+1] == '"')):
+			sb.WriteByte(s[i+1])
+			inToken = true
+			i++
+		default:
+			if !inToken && !inQuotes && c == '\\' { // a single backslash starting a token (a double one is caught above)
+				isSwitch = true
+			}
+			sb.WriteByte(c)
+			inToken = true
+		}
+	}
+	end()
+	return toks
+}
+
+// the types of field that pull in content from outside the document, and the argument (counting from 0) that names the target.
+// LINK fields name the application (e.g. Excel.Sheet.8) before the file. For DDE fields, whose application and topic can be a command line, all the arguments are the target (-1).
+var externalFields = map[string]int{
+	"include":         0,
+	"include text":    0,
+	"include picture": 0,
+	"import":          0,
+	"hyperlink":       0,
+	"link":            1,
+	"dde":             -1,
+	"dde auto":        -1,
+}
+
+// External reports whether the field pulls in content from outside the document, along with the path or URL of that content if it can be found in the instruction text.
+// A hyperlink that only goes to a bookmark in the document (HYPERLINK \l "name") isn't external.
+func (f Field) External() (target string, ok bool) {
+	n, ok := externalFields[f.Name]
+	if !ok {
+		return "", false
+	}
+	_, args := Args(f.Instruction)
+	if f.Name == "hyperlink" && len(args) == 0 && f.Instruction != "" {
+		return "", false
+	}
+	switch {
+	case n < 0:
+		target = strings.Join(args, " ")
+	case n < len(args):
+		target = args[n]
+	}
+	return target, true
+}
@@ -0,0 +1,248 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Languages are the languages recorded in a .doc, as Windows language identifiers (LIDs): see LanguageName and LanguageTag
+type Languages struct {
+	Install uint16          // lid from the FibBase: the language of the copy of Word that saved the document (not necessarily of the text)
+	FarEast uint16          // lidFE from the FibRgW97, for a document saved by an East Asian copy of Word (fFarEast), or 0
+	Default uint16          // the language of the Normal style, which text without a language of its own is in, or 0 if it can't be found
+	Text    []LanguageCount // the languages the text is marked with, the most used first; text with no language of its own counts as the Default
+}
+
+// LanguageCount is the number of characters of a document's text in a language
+type LanguageCount struct {
+	LID   uint16
+	Chars int
+}
+
+// Primary returns the language most of the text is in, ignoring text marked not to be proofed, or, if the text can't be read, the Default or (failing that) the Install language
+func (l Languages) Primary() uint16 {
+	for _, lc := range l.Text {
+		if lc.LID != lidNoProofing {
+			return lc.LID
+		}
+	}
+	if l.Default != 0 {
+		return l.Default
+	}
+	return l.Install
+}
+
+// the character sprms that give the language of a run: sprmCRgLid0 (for text other than East Asian), and sprmCRgLid0_80, which
+// Word 97 wrote and its successors still write alongside it
+const (
+	sprmCRgLid0    = 0x4873
+	sprmCRgLid0_80 = 0x486D
+)
+
+const lidNoProofing = 0x0400 // the language of text that isn't to be spell checked, such as code
+
+// languages are the names and IETF tags of the commonest Windows language identifiers
+var languages = map[uint16]struct{ name, tag string }{
+	lidNoProofing: {"No proofing", ""},
+	0x0401:        {"Arabic (Saudi Arabia)", "ar-SA"},
+	0x0402:        {"Bulgarian", "bg-BG"},
+	0x0403:        {"Catalan", "ca-ES"},
+	0x0404:        {"Chinese (Taiwan)", "zh-TW"},
+	0x0405:        {"Czech", "cs-CZ"},
+	0x0406:        {"Danish", "da-DK"},
+	0x0407:        {"German (Germany)", "de-DE"},
+	0x0408:        {"Greek", "el-GR"},
+	0x0409:        {"English (United States)", "en-US"},
+	0x040A:        {"Spanish (Traditional Sort)", "es-ES"},
+	0x040B:        {"Finnish", "fi-FI"},
+	0x040C:        {"French (France)", "fr-FR"},
+	0x040D:        {"Hebrew", "he-IL"},
+	0x040E:        {"Hungarian", "hu-HU"},
+	0x040F:        {"Icelandic", "is-IS"},
+	0x0410:        {"Italian (Italy)", "it-IT"},
+	0x0411:        {"Japanese", "ja-JP"},
+	0x0412:        {"Korean", "ko-KR"},
+	0x0413:        {"Dutch (Netherlands)", "nl-NL"},
+	0x0414:        {"Norwegian (Bokmål)", "nb-NO"},
+	0x0415:        {"Polish", "pl-PL"},
+	0x0416:        {"Portuguese (Brazil)", "pt-BR"},
+	0x0418:        {"Romanian", "ro-RO"},
+	0x0419:        {"Russian", "ru-RU"},
+	0x041A:        {"Croatian", "hr-HR"},
+	0x041B:        {"Slovak", "sk-SK"},
+	0x041D:        {"Swedish", "sv-SE"},
+	0x041E:        {"Thai", "th-TH"},
+	0x041F:        {"Turkish", "tr-TR"},
+	0x0421:        {"Indonesian", "id-ID"},
+	0x0422:        {"Ukrainian", "uk-UA"},
+	0x0424:        {"Slovenian", "sl-SI"},
+	0x0425:        {"Estonian", "et-EE"},
+	0x0426:        {"Latvian", "lv-LV"},
+	0x0427:        {"Lithuanian", "lt-LT"},
+	0x0429:        {"Persian", "fa-IR"},
+	0x042A:        {"Vietnamese", "vi-VN"},
+	0x0439:        {"Hindi", "hi-IN"},
+	0x0804:        {"Chinese (PRC)", "zh-CN"},
+	0x0807:        {"German (Switzerland)", "de-CH"},
+	0x0809:        {"English (United Kingdom)", "en-GB"},
+	0x080A:        {"Spanish (Mexico)", "es-MX"},
+	0x080C:        {"French (Belgium)", "fr-BE"},
+	0x0810:        {"Italian (Switzerland)", "it-CH"},
+	0x0813:        {"Dutch (Belgium)", "nl-BE"},
+	0x0814:        {"Norwegian (Nynorsk)", "nn-NO"},
+	0x0816:        {"Portuguese (Portugal)", "pt-PT"},
+	0x0C07:        {"German (Austria)", "de-AT"},
+	0x0C09:        {"English (Australia)", "en-AU"},
+	0x0C0A:        {"Spanish (Modern Sort)", "es-ES"},
+	0x0C0C:        {"French (Canada)", "fr-CA"},
+	0x1009:        {"English (Canada)", "en-CA"},
+	0x100C:        {"French (Switzerland)", "fr-CH"},
+	0x1409:        {"English (New Zealand)", "en-NZ"},
+	0x1809:        {"English (Ireland)", "en-IE"},
+	0x1C09:        {"English (South Africa)", "en-ZA"},
+}
+
+// LanguageName returns the name of a Windows language identifier, with the identifier, e.g. "English (United Kingdom) (0x0809)", or "unknown (0xNNNN)"
+func LanguageName(lid uint16) string {
+	if l, ok := languages[lid]; ok {
+		return fmt.Sprintf("%s (0x%04X)", l.name, lid)
+	}
+	return fmt.Sprintf("unknown (0x%04X)", lid)
+}
+
+// LanguageTag returns the IETF language tag of a Windows language identifier, e.g. "en-GB", or an empty string if it isn't known
+func LanguageTag(lid uint16) string {
+	return languages[lid].tag
+}
+
+// readLanguages reads the language of the Normal style from the stylesheet, and counts the characters of the text in each language from the sprms in the
+// character formatting of the document's text (see scanChpx). Only runs in the piece table are counted, so that the text a fast save has left behind is ignored.
+func (l *Languages) readLanguages(table io.ReaderAt, tableSize int64, fcLcb []byte, doc io.ReaderAt, docSize int64, pieces []piece) error {
+	if stsh, err := readTableData(table, tableSize, fcLcb, 1); err == nil && stsh != nil {
+		l.Default = normalLanguage(stsh)
+	}
+	bte, err := readTableData(table, tableSize, fcLcb, 12)
+	if err != nil || bte == nil || pieces == nil {
+		return err
+	}
+	counts := make(map[uint16]int)
+	err = scanChpx(doc, docSize, bte, func(fcStart, fcEnd uint32, grpprl []byte) {
+		chars := pieceChars(pieces, fcStart, fcEnd)
+		if chars == 0 {
+			return
+		}
+		lid := runLanguage(grpprl)
+		if lid == 0 {
+			lid = l.Default
+		}
+		if lid != 0 {
+			counts[lid] += chars
+		}
+	})
+	for lid, n := range counts {
+		l.Text = append(l.Text, LanguageCount{lid, n})
+	}
+	sort.Slice(l.Text, func(i, j int) bool {
+		if l.Text[i].Chars != l.Text[j].Chars {
+			return l.Text[i].Chars > l.Text[j].Chars
+		}
+		return l.Text[i].LID < l.Text[j].LID
+	})
+	return err
+}
+
+// pieceChars returns the number of characters of the text in the piece table that lie between two FCs of the WordDocument stream
+func pieceChars(pieces []piece, fcStart, fcEnd uint32) int {
+	var n int
+	for _, p := range pieces {
+		size := uint32(2)
+		if p.compressed {
+			size = 1
+		}
+		start, end := p.fc, p.fc+(p.cpEnd-p.cpStart)*size
+		if fcStart > start {
+			start = fcStart
+		}
+		if fcEnd < end {
+			end = fcEnd
+		}
+		if end > start {
+			n += int((end - start) / size)
+		}
+	}
+	return n
+}
+
+// normalLanguage returns the language of the Normal style (the first in the stylesheet), or 0 if it doesn't have one.
+// The STSH starts with the size of the STSHI, which gives the number of styles (cstd) and the size of the fixed part of each style (cbSTDBaseInFile), then has each style
+// as its size and the STD: the fixed part, with the style kind (stk, the low 4 bits at offset 2) and the number of UPXs (cupx, the low 4 bits at offset 4); the name,
+// as a 16-bit count, the UTF-16 characters and a null; and the UPXs, each a 16-bit size and that many bytes, padded to an even size. The character formatting of
+// a paragraph style (stk 1) is its second UPX, and of a character style (stk 2) its first.
+func normalLanguage(stsh []byte) uint16 {
+	if len(stsh) < 2 {
+		return 0
+	}
+	cbStshi := int(binary.LittleEndian.Uint16(stsh))
+	if cbStshi < 4 || 2+cbStshi+2 > len(stsh) {
+		return 0
+	}
+	cbBase := int(binary.LittleEndian.Uint16(stsh[4:]))
+	p := 2 + cbStshi
+	cbStd := int(binary.LittleEndian.Uint16(stsh[p:]))
+	if cbStd < cbBase+2 || p+2+cbStd > len(stsh) || cbBase < 6 {
+		return 0
+	}
+	std := stsh[p+2 : p+2+cbStd]
+	stk, cupx := int(std[2]&0x0F), int(std[4]&0x0F)
+	chpx := 1 // the index of the UPX with the character formatting
+	if stk == 2 {
+		chpx = 0
+	} else if stk != 1 {
+		return 0
+	}
+	i := cbBase + 2 + int(binary.LittleEndian.Uint16(std[cbBase:]))*2 + 2 // after the name
+	for u := 0; u < cupx && i+2 <= len(std); u++ {
+		cb := int(binary.LittleEndian.Uint16(std[i:]))
+		if i+2+cb > len(std) {
+			return 0
+		}
+		if u == chpx {
+			return runLanguage(std[i+2 : i+2+cb])
+		}
+		i += 2 + cb + cb%2
+	}
+	return 0
+}
+
+// runLanguage returns the language given by the sprms of some character formatting, or 0 if they don't give one
+func runLanguage(grpprl []byte) uint16 {
+	var lid, lid80 uint16
+	forSprms(grpprl, func(sprm uint16, operand []byte) {
+		switch sprm {
+		case sprmCRgLid0:
+			lid = binary.LittleEndian.Uint16(operand)
+		case sprmCRgLid0_80:
+			lid80 = binary.LittleEndian.Uint16(operand)
+		}
+	})
+	if lid == 0 {
+		return lid80
+	}
+	return lid
+}
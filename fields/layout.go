@@ -0,0 +1,115 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Layout is what a layout-affecting field (EQ, SYMBOL or ADVANCE) draws or moves, read from its instruction text.
+// These fields render nothing, or the wrong thing, when a conversion drops or misreads them, so they are worth checking by hand.
+type Layout struct {
+	// for SYMBOL: the character code (given in decimal, or in hex with 0x; a negative code is taken as 16 bits), or 0 if it can't be read,
+	// the font (\f; empty for the font of the text), the size in points (\s) and how the code is read: ANSI (the default), Unicode (\u) or Shift-JIS (\j)
+	Char     uint32
+	Font     string
+	Size     string
+	Encoding string
+	// for ADVANCE: the moves, in the order given
+	Moves []Move
+	// for EQ: the switches that build the equation (e.g. f for a fraction, r for a radical), without their backslashes or options, in the order they first appear
+	Switches []string
+}
+
+// Move is one of the switches of an ADVANCE field: the distance in points and its direction, by switch:
+// d (down), u (up), l (left), r (right), x (to that distance from the left edge of the column) or y (to that distance from the top of the line)
+type Move struct {
+	Switch string
+	Points string
+}
+
+// EQSwitches names the switches of an EQ field
+var EQSwitches = map[string]string{
+	"a": "array",
+	"b": "bracket",
+	"d": "displace",
+	"f": "fraction",
+	"i": "integral",
+	"l": "list",
+	"o": "overstrike",
+	"r": "radical",
+	"s": "superscript or subscript",
+	"x": "box",
+}
+
+// eqSwitch matches the single-letter switches of an EQ field, and not their options (e.g. \up8 or \co2)
+var eqSwitch = regexp.MustCompile(`\\([a-zA-Z])(?:[^a-zA-Z]|$)`)
+
+// Layout reports whether the field is an EQ, SYMBOL or ADVANCE field, along with what it draws or moves
+func (f Field) Layout() (Layout, bool) {
+	var l Layout
+	switch f.Name {
+	case "symbol":
+		if _, args := Args(f.Instruction); len(args) > 0 {
+			if v, err := strconv.ParseInt(args[0], 0, 32); err == nil {
+				if v < 0 {
+					v &= 0xFFFF
+				}
+				l.Char = uint32(v)
+			}
+		}
+		l.Font, l.Size, l.Encoding = switchValue(f.Instruction, `\f`), switchValue(f.Instruction, `\s`), "ANSI"
+		if hasSwitch(f.Instruction, `\u`) {
+			l.Encoding = "Unicode"
+		} else if hasSwitch(f.Instruction, `\j`) {
+			l.Encoding = "Shift-JIS"
+		}
+	case "advance":
+		toks := tokenise(f.Instruction)
+		for i, t := range toks {
+			if sw := strings.ToLower(strings.TrimPrefix(t.text, `\`)); t.isSwitch && len(sw) == 1 && strings.Contains("dulrxy", sw) {
+				m := Move{Switch: sw}
+				if i+1 < len(toks) && !toks[i+1].isSwitch {
+					m.Points = toks[i+1].text
+				}
+				l.Moves = append(l.Moves, m)
+			}
+		}
+	case "eq":
+		seen := make(map[string]bool)
+		for _, m := range eqSwitch.FindAllStringSubmatch(f.Instruction, -1) {
+			sw := strings.ToLower(m[1])
+			if _, ok := EQSwitches[sw]; ok && !seen[sw] {
+				seen[sw] = true
+				l.Switches = append(l.Switches, sw)
+			}
+		}
+	default:
+		return l, false
+	}
+	return l, true
+}
+
+// hasSwitch reports whether a field's instruction text has a switch (e.g. \u), with or without a value
+func hasSwitch(instruction, sw string) bool {
+	for _, t := range tokenise(instruction) {
+		if t.isSwitch && strings.EqualFold(t.text, sw) {
+			return true
+		}
+	}
+	return false
+}
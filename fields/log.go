@@ -0,0 +1,50 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+// discard is a slog.Handler that drops every record, for when Options has no Logger
+type discard struct{}
+
+func (discard) Enabled(context.Context, slog.Level) bool  { return false }
+func (discard) Handle(context.Context, slog.Record) error { return nil }
+func (d discard) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discard) WithGroup(string) slog.Handler           { return d }
+
+// logger returns Options.Logger, or a logger that drops everything if it is nil
+func (o Options) logger() *slog.Logger {
+	if o.Logger == nil {
+		return slog.New(discard{})
+	}
+	return o.Logger
+}
+
+// logged is a stream whose reads are logged at the debug level: the stream, the offset and length asked for, and the bytes read
+type logged struct {
+	io.ReaderAt
+	name string
+	log  *slog.Logger
+}
+
+func (l logged) ReadAt(p []byte, off int64) (int, error) {
+	n, err := l.ReaderAt.ReadAt(p, off)
+	l.log.Debug("read", "stream", l.name, "offset", off, "length", len(p), "read", n)
+	return n, err
+}
@@ -0,0 +1,171 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"encoding/xml"
+	"path"
+	"strings"
+)
+
+// MailMerge describes the document's part in a mail merge: whether it is a main document (one that is merged with a data source to make letters, labels etc.),
+// the merge fields it uses and where its data comes from. The data source is often a spreadsheet or database of personal data.
+type MailMerge struct {
+	MainDocument   bool     // the document is set up as a mail merge main document
+	Fields         []string // the names of the merge fields (the columns of the data source) used by MERGEFIELD fields, in the order they are first used
+	DataSource     string   // the path of the data source (or, for an OOXML document, its URL); for a .doc, from the SttbfAssoc, or else a DATA field
+	HeaderDocument string   // the path of the header document, which names the data source's columns when the data source doesn't
+	Connection     string   // the connection string used to open the data source (Word 2002 and later, and OOXML)
+	Query          string   // the query that selects the records from the data source
+	Databases      []string // the data sources (the \d switch) or connection strings (\c) of DATABASE fields, which insert the results of a query
+}
+
+// Active reports whether the document is a main document or refers to any mail merge data
+func (m MailMerge) Active() bool {
+	return m.MainDocument || len(m.Fields) > 0 || m.DataSource != "" || m.HeaderDocument != "" || m.Connection != "" || len(m.Databases) > 0
+}
+
+// setMailMerge fills in the parts of the mail merge description that come from the fields' instruction text and the SttbfAssoc
+func (d *Report) setMailMerge() {
+	m := &d.MailMerge
+	m.Fields, m.Databases = nil, nil
+	seen := make(map[string]bool)
+	for _, r := range d.Regions() {
+		for _, f := range r.Occurrences {
+			_, args := Args(f.Instruction)
+			switch f.Name {
+			case "merge field":
+				if len(args) > 0 && !seen[args[0]] {
+					seen[args[0]] = true
+					m.Fields = append(m.Fields, args[0])
+				}
+			case "data": // DATA datafile [headerfile], from the mail merge of Word for Windows 2.0 and earlier
+				if len(args) > 0 && m.DataSource == "" {
+					m.DataSource = args[0]
+				}
+				if len(args) > 1 && m.HeaderDocument == "" {
+					m.HeaderDocument = args[1]
+				}
+			case "database":
+				for _, sw := range []string{`\d`, `\c`} {
+					if v := switchValue(f.Instruction, sw); v != "" {
+						m.Databases = append(m.Databases, v)
+					}
+				}
+			}
+		}
+	}
+	if d.Associations.DataSource != "" {
+		m.DataSource = d.Associations.DataSource
+	}
+	if d.Associations.HeaderDocument != "" {
+		m.HeaderDocument = d.Associations.HeaderDocument
+	}
+}
+
+// the ids of the ODSO properties with the connection string and the query, whose values are null-terminated UTF-16 strings
+const (
+	odsoConnection = 0x0000
+	odsoQuery      = 0x0001
+)
+
+// readODSO reads the connection string and query from the ODSO (office data source object, pair 127 of the FibRgFcLcb, which Word 2002 and later write for a main document).
+// It is a run of properties, each a 16-bit id and a 16-bit size followed by that many bytes; a size of 0 means the size is in the 32 bits that follow.
+func readODSO(b []byte) (connection, query string) {
+	for i := 0; i+4 <= len(b); {
+		id, cb := binary.LittleEndian.Uint16(b[i:]), int(binary.LittleEndian.Uint16(b[i+2:]))
+		i += 4
+		if cb == 0 {
+			if i+4 > len(b) {
+				break
+			}
+			cb = int(binary.LittleEndian.Uint32(b[i:]))
+			i += 4
+		}
+		if cb > len(b)-i {
+			break
+		}
+		switch id {
+		case odsoConnection:
+			connection = strings.TrimRight(utf16String(b[i:i+cb]), "\x00")
+		case odsoQuery:
+			query = strings.TrimRight(utf16String(b[i:i+cb]), "\x00")
+		}
+		i += cb
+	}
+	return connection, query
+}
+
+// readMailMergeSettings reads the mail merge settings (w:mailMerge) from the settings part of an OOXML document, with the data source from the settings part's relationships
+func readMailMergeSettings(files map[string]*zip.File, main string) MailMerge {
+	var m MailMerge
+	settings := relTargets(files, main, "settings")
+	if len(settings) == 0 || files[settings[0]] == nil {
+		return m
+	}
+	rc, err := files[settings[0]].Open()
+	if err != nil {
+		return m
+	}
+	defer rc.Close()
+	type val struct {
+		Val string `xml:"val,attr"`
+	}
+	var s struct {
+		MailMerge *struct {
+			MainDocumentType *val `xml:"mainDocumentType"`
+			ConnectString    val  `xml:"connectString"`
+			Query            val  `xml:"query"`
+			DataSource       struct {
+				ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+			} `xml:"dataSource"`
+		} `xml:"mailMerge"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&s); err != nil || s.MailMerge == nil {
+		return m
+	}
+	mm := s.MailMerge
+	m.MainDocument = mm.MainDocumentType != nil
+	m.Connection, m.Query = mm.ConnectString.Val, mm.Query.Val
+	if mm.DataSource.ID != "" {
+		m.DataSource = relTarget(files, settings[0], mm.DataSource.ID)
+	}
+	return m
+}
+
+// relTarget returns the target of the source part's relationship with the given id, as it is written (so an external target is a URL)
+func relTarget(files map[string]*zip.File, source, id string) string {
+	f, ok := files[path.Join(path.Dir(source), "_rels", path.Base(source)+".rels")]
+	if !ok {
+		return ""
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	var rels relationships
+	if err := xml.NewDecoder(rc).Decode(&rels); err != nil {
+		return ""
+	}
+	for _, rel := range rels.Relationship {
+		if rel.ID == id {
+			return rel.Target
+		}
+	}
+	return ""
+}
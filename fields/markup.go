@@ -0,0 +1,156 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// markupRegion collects the fields of a region of a document in which fields are marked up in the text (OOXML and RTF), pairing their begins, separators and ends as processField does.
+// CPs are counted through the region's text (across all its parts, e.g. every header and footer), with each field character, tab, break and paragraph mark as a single character,
+// so they approximate the CPs Word would give the same text in a .doc.
+type markupRegion struct {
+	cp     uint32
+	fields []Field
+	open   []int // indexes in fields of the fields begun but not yet ended, innermost last
+	st     Structure
+	// results collects each field's result text (the text between its separator and its end) as well as its instruction
+	results bool
+}
+
+func (r *markupRegion) begin() {
+	r.st.Begins++
+	r.fields = append(r.fields, Field{CP: r.cp, Depth: len(r.open) + 1})
+	r.open = append(r.open, len(r.fields)-1)
+	if len(r.open) > r.st.MaxDepth {
+		r.st.MaxDepth = len(r.open)
+	}
+	r.cp++
+}
+
+// text adds to the instruction of every open field that hasn't reached its separator, so an outer field's instruction includes any fields nested in it, as readText gives for a .doc
+func (r *markupRegion) text(s string) {
+	for _, i := range r.open {
+		if f := &r.fields[i]; f.Separator == 0 && len(f.Instruction) < maxInstruction {
+			f.Instruction += s
+		}
+	}
+	r.result(s)
+	r.cp += uint32(utf8.RuneCountInString(s))
+}
+
+// mark counts a paragraph mark or break, which is a newline in the results of the open fields (but not in their instructions)
+func (r *markupRegion) mark() {
+	r.result("\n")
+	r.cp++
+}
+
+// result adds to the result of the open fields that are past their separators, if results are collected, out to the innermost field that is still in its instruction:
+// as readResult gives for a .doc, an outer field's result includes the results of any fields nested in it, but not their instructions
+func (r *markupRegion) result(s string) {
+	if !r.results {
+		return
+	}
+	for j := len(r.open) - 1; j >= 0; j-- {
+		f := &r.fields[r.open[j]]
+		if f.Separator == 0 {
+			return
+		}
+		if len(f.Result) < maxInstruction {
+			f.Result += s
+		}
+	}
+}
+
+func (r *markupRegion) separate() {
+	r.st.Separators++
+	if len(r.open) == 0 || r.fields[r.open[len(r.open)-1]].Separator != 0 {
+		r.st.StraySeparators++
+	} else {
+		f := &r.fields[r.open[len(r.open)-1]]
+		f.Separator = r.cp
+		nameField(f)
+	}
+	r.cp++
+}
+
+func (r *markupRegion) end() {
+	r.st.Ends++
+	if len(r.open) == 0 {
+		r.st.StrayEnds++
+	} else {
+		f := &r.fields[r.open[len(r.open)-1]]
+		f.End = r.cp
+		f.Result = strings.TrimSpace(f.Result)
+		nameField(f)
+		r.open = r.open[:len(r.open)-1]
+	}
+	r.cp++
+}
+
+// finish names any fields left unclosed at the end of the region
+func (r *markupRegion) finish() {
+	for _, i := range r.open {
+		nameField(&r.fields[i])
+	}
+	r.st.Unclosed = len(r.open)
+}
+
+// nameField tidies up the field's instruction and names it from its keyword, once the whole instruction has been read.
+// A keyword that isn't in fieldKeywords (e.g. one added in a later version of Word) is used as the name, in lower case.
+func nameField(f *Field) {
+	if f.Name != "" {
+		return
+	}
+	f.Instruction = strings.TrimSpace(f.Instruction)
+	keyword, _ := Args(f.Instruction)
+	if code, ok := keywordCode(keyword); ok {
+		f.Code = code
+		f.Name, _ = FieldName(code)
+		return
+	}
+	if keyword == "" {
+		f.Code = 0x01
+		f.Name, _ = FieldName(0x01)
+		return
+	}
+	f.Name = strings.ToLower(keyword)
+}
+
+// setMarkupRegions sets the regions of the report that have any field characters, returning false if none do
+func (d *Report) setMarkupRegions(regions []markupRegion) bool {
+	var found bool
+	for i, fr := range fieldRegions {
+		r := &regions[i]
+		if r.st.Begins+r.st.Separators+r.st.Ends == 0 {
+			continue
+		}
+		r.finish()
+		found = true
+		d.setRegion(i, r.fields)
+		if r.st.Malformed() {
+			d.Warnings = append(d.Warnings, malformedWarning(fr.name, r.st))
+		}
+		if d.Structure == nil {
+			d.Structure = make(map[string]Structure)
+		}
+		d.Structure[fr.key] = r.st
+	}
+	d.setHyperlinks()
+	d.setFormFields()
+	d.setMailMerge()
+	return found
+}
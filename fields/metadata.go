@@ -0,0 +1,205 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/msoleps"
+)
+
+// Property is a labelled piece of document metadata
+type Property struct {
+	Name  string
+	Value string
+}
+
+// creators are the known values of the wMagicCreated and wMagicRevised creator IDs in the FIB
+var creators = map[uint16]string{
+	0x6A62: "Microsoft Word",
+}
+
+func creator(id uint16) string {
+	if name, ok := creators[id]; ok {
+		return fmt.Sprintf("%s (0x%04X)", name, id)
+	}
+	return fmt.Sprintf("unknown (0x%04X)", id)
+}
+
+// findEntry returns the top-level stream with the given name, or nil if the compound file doesn't have one
+func findEntry(doc *mscfb.Reader, name string) *mscfb.File {
+	for _, entry := range doc.File {
+		if entry.Name == name && len(entry.Path) == 0 {
+			return entry
+		}
+	}
+	return nil
+}
+
+// entries indexes the entries of a compound file, from a single pass over its directory
+type entries struct {
+	top    map[string]*mscfb.File // the top-level streams and storages, by name (the first of any with the same name), as findEntry finds them
+	macros bool                   // the file contains a VBA project: it has an entry that macroEntry reports
+}
+
+// indexEntries indexes the entries of a compound file. Only the directory is read, not the streams.
+func indexEntries(doc *mscfb.Reader) entries {
+	idx := entries{top: make(map[string]*mscfb.File)}
+	for _, entry := range doc.File {
+		if _, ok := idx.top[entry.Name]; !ok && len(entry.Path) == 0 {
+			idx.top[entry.Name] = entry
+		}
+		if !idx.macros && macroEntry(entry) {
+			idx.macros = true
+		}
+	}
+	return idx
+}
+
+// macroEntry reports whether an entry of a compound file is part of a VBA project.
+// Word keeps macros in a top-level Macros storage, with the project in a VBA storage (holding the _VBA_PROJECT stream) beneath it,
+// so the whole path of the entry is checked, not just its name.
+func macroEntry(entry *mscfb.File) bool {
+	for _, name := range append(entry.Path, entry.Name) {
+		switch name {
+		case "Macros", "VBA", "_VBA_PROJECT", "_VBA_PROJECT_CUR":
+			return true
+		}
+	}
+	return false
+}
+
+var errPropertySet = errors.New("malformed property set")
+
+// readPropertySet reads a property set stream. msoleps can panic on a damaged one (e.g. one with an offset past its end), so the panic is recovered as an
+// errPropertySet, and is a problem of the one document rather than of the whole run.
+func readPropertySet(stream *mscfb.File) (ps *msoleps.Reader, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ps, err = nil, fmt.Errorf("%w: %s: %v", errPropertySet, stream.Name, r)
+		}
+	}()
+	return msoleps.NewFrom(io.NewSectionReader(stream, 0, stream.Size)) // a section reader, so the stream can be read again
+}
+
+// readMetadata reports the creating and last modifying applications.
+// The SummaryInformation property set only names the creating application (AppName), so the creator IDs are also read from the FIB:
+// wMagicCreated (offset 34, the start of the FibRgW) identifies the application that created the file and wMagicRevised (offset 36) the one that last saved it.
+// A mismatch between the two is a sign the document has been converted or edited by another tool.
+// The error is an errPropertySet if the SummaryInformation is too damaged to read; a property set that msoleps just rejects is left out without one.
+func readMetadata(fib []byte, summary *mscfb.File) ([]Property, error) {
+	var props []Property
+	var perr error
+	if summary != nil {
+		ps, err := readPropertySet(summary)
+		if errors.Is(err, errPropertySet) {
+			perr = err
+		}
+		if err == nil {
+			for _, p := range ps.Property {
+				if p.Name == "AppName" {
+					props = append(props, Property{"Creating application (SummaryInformation)", p.String()})
+				}
+			}
+		}
+	}
+	if isWord6(binary.LittleEndian.Uint16(fib[2:4])) || binary.LittleEndian.Uint16(fib[32:34]) < 2 { // older FIBs, and a FibRgW (counted by csw) that is too short, don't have the creator IDs
+		return props, perr
+	}
+	props = append(props,
+		Property{"Creating application (FIB)", creator(binary.LittleEndian.Uint16(fib[34:36]))},
+		Property{"Last modifying application (FIB)", creator(binary.LittleEndian.Uint16(fib[36:38]))},
+	)
+	return props, perr
+}
+
+// DocProperties are the document properties recorded in the SummaryInformation and DocumentSummaryInformation property sets
+// (or, for OOXML, in the core and extended properties parts). Times are in UTC. Empty values weren't recorded.
+type DocProperties struct {
+	Title       string
+	Subject     string
+	Author      string
+	LastSavedBy string
+	Created     string
+	Modified    string // the time the document was last saved
+	Application string
+	Template    string
+	Company     string
+}
+
+// readProperties reads the document properties from the property set streams, either of which can be nil.
+// The error is an errPropertySet if either is too damaged to read (see readPropertySet); the properties of the other are still read.
+func readProperties(summary, docSummary *mscfb.File) (DocProperties, error) {
+	var dp DocProperties
+	var perr error
+	// the msoleps names of the properties, and where in dp they go
+	set := map[string]*string{
+		"Title":        &dp.Title,
+		"Subject":      &dp.Subject,
+		"Author":       &dp.Author,
+		"LastAuthor":   &dp.LastSavedBy,
+		"CreateTime":   &dp.Created,
+		"LastSaveTime": &dp.Modified,
+		"AppName":      &dp.Application,
+		"Template":     &dp.Template,
+		"Company":      &dp.Company,
+	}
+	for _, stream := range []*mscfb.File{summary, docSummary} {
+		if stream == nil {
+			continue
+		}
+		ps, err := readPropertySet(stream)
+		if errors.Is(err, errPropertySet) && perr == nil {
+			perr = err
+		}
+		if err != nil {
+			continue
+		}
+		codePage := propertySetCodePage(stream)
+		for _, p := range ps.Property {
+			if v, ok := set[p.Name]; ok && *v == "" {
+				if *v = p.String(); !utf8.ValidString(*v) { // msoleps leaves 8-bit strings in the property set's code page
+					*v = decodeString([]byte(*v), codePage)
+				}
+			}
+		}
+	}
+	return dp, perr
+}
+
+// propertySetCodePage returns the code page of the strings in the first section of a property set stream (its property 1, see readCustomProperties), or 1252 if it can't be read
+func propertySetCodePage(stream *mscfb.File) uint16 {
+	buf := make([]byte, 4096)
+	n, _ := stream.ReadAt(buf, 0)
+	if buf = buf[:n]; n < 48 || binary.LittleEndian.Uint32(buf[24:]) == 0 {
+		return 1252
+	}
+	sec := int(binary.LittleEndian.Uint32(buf[44:]))
+	if sec < 0 || sec+8 > n {
+		return 1252
+	}
+	for i, m := 0, int(binary.LittleEndian.Uint32(buf[sec+4:])); i < m && sec+16+i*8 <= n; i++ {
+		id, o := binary.LittleEndian.Uint32(buf[sec+8+i*8:]), sec+int(binary.LittleEndian.Uint32(buf[sec+12+i*8:]))
+		if id == 1 && o >= sec && o+6 <= n && binary.LittleEndian.Uint16(buf[o:]) == 0x0002 { // VT_I2
+			return binary.LittleEndian.Uint16(buf[o+4:])
+		}
+	}
+	return 1252
+}
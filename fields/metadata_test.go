@@ -0,0 +1,122 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// TestIndexEntries checks that the index of a compound file's entries has the top-level streams, and not the streams of the same names in its storages
+// (here an embedded document's WordDocument and 1Table, which come before the document's own in the directory), and that it finds the VBA project
+func TestIndexEntries(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "nested.doc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := mscfb.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := indexEntries(doc)
+	for _, name := range []string{"WordDocument", "1Table", "SummaryInformation", "DocumentSummaryInformation", "ObjectPool", "Macros"} {
+		entry := idx.top[name]
+		if entry == nil {
+			t.Errorf("%s is missing from the index", name)
+			continue
+		}
+		if len(entry.Path) != 0 {
+			t.Errorf("%s: got the entry in %v, want the top-level one", name, entry.Path)
+		}
+		if entry != findEntry(doc, name) {
+			t.Errorf("%s: the index and findEntry give different entries", name)
+		}
+	}
+	if wd := idx.top["WordDocument"]; wd != nil && wd.Size != 4096 {
+		t.Errorf("got a WordDocument stream of %d bytes, want the document's own (4096 bytes)", wd.Size)
+	}
+	for _, name := range []string{"VBA", "_VBA_PROJECT", "_1234567890"} {
+		if idx.top[name] != nil {
+			t.Errorf("%s is in a storage, but is in the index of top-level entries", name)
+		}
+	}
+	if !idx.macros {
+		t.Error("the VBA project wasn't found")
+	}
+	res, err := parseFixture(t, "nested.doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Macros || res.Table != "1Table" || len(res.BodyFields) != 1 {
+		t.Errorf("got macros %v, table %s and body fields %v, want the document's own fields and tables, with macros", res.Macros, res.Table, res.BodyFields)
+	}
+}
+
+// openCFB opens a fixture as a compound file
+func openCFB(t *testing.T, name string) *mscfb.Reader {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	doc, err := mscfb.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// TestReadMetadataDamaged checks that a property set that msoleps panics on gives an errPropertySet, and that the creator IDs in the FIB are still reported
+func TestReadMetadataDamaged(t *testing.T) {
+	idx := indexEntries(openCFB(t, "badprops.doc"))
+	fib := make([]byte, 512)
+	if _, err := idx.top["WordDocument"].ReadAt(fib, 0); err != nil {
+		t.Fatal(err)
+	}
+	props, err := readMetadata(fib, idx.top["SummaryInformation"])
+	if !errors.Is(err, errPropertySet) {
+		t.Errorf("got error %v, want %v", err, errPropertySet)
+	}
+	if len(props) != 2 {
+		t.Errorf("got properties %v, want the two from the FIB", props)
+	}
+}
+
+// TestParseDamagedPropertySet checks that a property set that msoleps panics on is a warning, and doesn't stop the document's fields being read
+func TestParseDamagedPropertySet(t *testing.T) {
+	res, err := parseFixture(t, "badprops.doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warned int
+	for _, w := range res.Warnings {
+		if strings.Contains(w, errPropertySet.Error()) {
+			warned++
+		}
+	}
+	if warned != 1 {
+		t.Errorf("got warnings %q, want one for the malformed property set", res.Warnings)
+	}
+	if len(regionNames(res)) == 0 {
+		t.Error("the fields weren't read")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"errors"
+	"io"
+)
+
+var errNotes = errors.New("malformed note tables")
+
+// the note regions, with the fc/lcb pairs of their tables: the PlcffndRef (or PlcfendRef), which has the CP in the body of each note's reference mark followed by an FRD
+// (2 bytes) for each, and the PlcffndTxt (or PlcfendTxt), which has the CP of the start of each note's text in the footnote (or endnote) part of the text, and one more for the end
+var noteTables = []struct {
+	name, key string
+	ref, txt  int
+}{
+	{"Footnote", "footnote", 2, 3},
+	{"Endnote", "endnote", 46, 47},
+}
+
+// noteStories are the CPs of a note table's reference marks, and of the start of each of its notes' text (and the end of the last), which place the fields of the notes
+type noteStories struct{ refs, starts []uint32 }
+
+// readNoteStories reads the note table at the given pairs of the FibRgFcLcb
+func readNoteStories(table io.ReaderAt, tableSize int64, fcLcb []byte, refIndex, txtIndex int) (*noteStories, error) {
+	ref, err := readTableData(table, tableSize, fcLcb, refIndex)
+	if err != nil {
+		return nil, err
+	}
+	txt, err := readTableData(table, tableSize, fcLcb, txtIndex)
+	if err != nil {
+		return nil, err
+	}
+	n := (len(ref) - 4) / 6
+	if n < 1 || len(txt)/4 < n+1 {
+		return nil, errNotes
+	}
+	return &noteStories{plcCPs(ref, n), plcCPs(txt, n)}, nil
+}
+
+// place sets the Story of each field to the number of the note it is in, counting the notes from 1 in document order, and its Anchor to the CP of the note's reference mark.
+// It returns the number of fields that aren't in any note.
+func (s *noteStories) place(fields []Field) int {
+	var missing int
+	for i := range fields {
+		n := plcIndex(s.starts, fields[i].CP)
+		if n < 0 {
+			missing++
+			continue
+		}
+		fields[i].Story, fields[i].Anchor, fields[i].Anchored = n+1, s.refs[n], true
+	}
+	return missing
+}
@@ -0,0 +1,213 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// Object is an embedded OLE object, from a storage in the document's ObjectPool
+type Object struct {
+	Name   string // the name of the object's storage, e.g. _1234567890 (Word uses the same number to refer to it from the document's text)
+	CLSID  string // the class of the object's server; empty if the storage doesn't record one
+	Type   string // a description of the class, e.g. "Microsoft Excel Worksheet", from the known CLSIDs or else the object's CompObj stream; empty if neither has one
+	ProgID string // the server's ProgID, e.g. Excel.Sheet.8, from the CompObj stream
+	File   string // for an object wrapped by the Packager (or embedded as native data), the name of the file it contains
+	// Control is set if the object is an ActiveX control: its ObjInfo has fOCX set, it has an OCXNAME stream, or its CLSID is that of a known control.
+	// ControlName is the control's name, e.g. CommandButton1, from its OCXNAME stream.
+	Control     bool
+	ControlName string
+}
+
+// the class of objects made by the Packager, which wraps any file (including executables and scripts) in an OLE object
+const clsidPackager = "0003000C-0000-0000-C000-000000000046"
+
+// Packaged reports whether the object is a file wrapped by the Packager
+func (o Object) Packaged() bool {
+	return o.CLSID == clsidPackager || o.ProgID == "Package"
+}
+
+// clsids maps the CLSIDs of common OLE servers to descriptions of their objects
+var clsids = map[string]string{
+	"00020810-0000-0000-C000-000000000046": "Microsoft Excel 5.0 Worksheet",
+	"00020811-0000-0000-C000-000000000046": "Microsoft Excel 5.0 Chart",
+	"00020820-0000-0000-C000-000000000046": "Microsoft Excel Worksheet",
+	"00020821-0000-0000-C000-000000000046": "Microsoft Excel Chart",
+	"00020830-0000-0000-C000-000000000046": "Microsoft Excel Worksheet (2007 and later)",
+	"00020900-0000-0000-C000-000000000046": "Microsoft Word 6.0 Document",
+	"00020906-0000-0000-C000-000000000046": "Microsoft Word Document",
+	"F4754C9B-64F5-4B40-8AF4-679732AC0607": "Microsoft Word Document (2007 and later)",
+	"64818D10-4F9B-11CF-86EA-00AA00B929E8": "Microsoft PowerPoint Presentation",
+	"64818D11-4F9B-11CF-86EA-00AA00B929E8": "Microsoft PowerPoint Slide",
+	"0002CE02-0000-0000-C000-000000000046": "Microsoft Equation 3.0",
+	"00021A14-0000-0000-C000-000000000046": "Microsoft Visio Drawing",
+	"0003000A-0000-0000-C000-000000000046": "Paintbrush Picture",
+	"B801CA65-A1FC-11D0-85AD-444553540000": "Adobe Acrobat Document",
+	clsidPackager:                          "Packager",
+}
+
+// controls maps the CLSIDs of common ActiveX controls to descriptions of them
+var controls = map[string]string{
+	"D7053240-CE69-11CD-A777-00DD01143C57": "Microsoft Forms 2.0 CommandButton",
+	"8BD21D10-EC42-11CE-9E0D-00AA006002F3": "Microsoft Forms 2.0 TextBox",
+	"8BD21D20-EC42-11CE-9E0D-00AA006002F3": "Microsoft Forms 2.0 ListBox",
+	"8BD21D30-EC42-11CE-9E0D-00AA006002F3": "Microsoft Forms 2.0 ComboBox",
+	"8BD21D40-EC42-11CE-9E0D-00AA006002F3": "Microsoft Forms 2.0 CheckBox",
+	"8BD21D50-EC42-11CE-9E0D-00AA006002F3": "Microsoft Forms 2.0 OptionButton",
+	"8BD21D60-EC42-11CE-9E0D-00AA006002F3": "Microsoft Forms 2.0 ToggleButton",
+	"978C9E23-D4B0-11CE-BF2D-00AA003F40D0": "Microsoft Forms 2.0 Label",
+	"4C599241-6926-101B-9992-00000B65C6F9": "Microsoft Forms 2.0 Image",
+	"DFD181E0-5E2F-11CE-A449-00AA004A803D": "Microsoft Forms 2.0 ScrollBar",
+	"79176FB0-B7F2-11CE-97EF-00AA006D2776": "Microsoft Forms 2.0 SpinButton",
+	"6E182020-F460-11CE-9BCD-00AA00608E01": "Microsoft Forms 2.0 Frame",
+	"46E31370-3F7A-11CE-BED6-00AA00611080": "Microsoft Forms 2.0 MultiPage",
+	"EAE50EB0-4A62-11CE-BED6-00AA00611080": "Microsoft Forms 2.0 TabStrip",
+	"6BF52A52-394A-11D3-B153-00C04F79FAA6": "Windows Media Player",
+	"22D6F312-B0F6-11D0-94AB-0080C74C7E95": "Windows Media Player 6.4",
+	"D27CDB6E-AE6D-11CF-96B8-444553540000": "Shockwave Flash Object",
+	"8856F961-340A-11D0-A96B-00C04FD705A2": "Microsoft Web Browser",
+}
+
+// ClassName returns a description of the OLE server with a CLSID (as given by mscfb's File.ID), e.g. "Microsoft Excel Worksheet", or an empty string if it isn't one doctool knows
+func ClassName(clsid string) string {
+	if c, ok := controls[clsid]; ok {
+		return c
+	}
+	return clsids[clsid]
+}
+
+// readObjects lists the embedded objects in the document's ObjectPool storage, which has a storage for each object, holding the object's own streams.
+// Each object's storage records the CLSID of its server, and most have a CompObj stream (named \x01CompObj), which has the server's description (AnsiUserType) and ProgID.
+// Packaged files, and other objects embedded as native data, have an Ole10Native stream (\x01Ole10Native) that starts with the name of the file.
+// An ActiveX control's storage has an ObjInfo stream (\x03ObjInfo) whose ODT has fOCX set, and its name is in an OCXNAME stream (\x03OCXNAME).
+// codePage is the code page of the 8-bit strings in the CompObj and Ole10Native streams (see ansiCodePage).
+func readObjects(doc *mscfb.Reader, codePage uint16) []Object {
+	var objects []Object
+	index := make(map[string]int)
+	for _, entry := range doc.File {
+		switch {
+		case len(entry.Path) == 1 && entry.Path[0] == "ObjectPool" && entry.FileInfo().IsDir():
+			index[entry.Name] = len(objects)
+			objects = append(objects, Object{Name: entry.Name, CLSID: entry.ID()})
+		case len(entry.Path) == 2 && entry.Path[0] == "ObjectPool":
+			i, ok := index[entry.Path[1]] // a storage is listed before the streams in it
+			if !ok {
+				continue
+			}
+			switch entry.Name {
+			case "CompObj":
+				objects[i].Type, objects[i].ProgID = readCompObj(entry, codePage)
+			case "Ole10Native":
+				objects[i].File = readOle10Native(entry, codePage)
+			case "ObjInfo":
+				if b := readStart(entry, 2); len(b) == 2 && binary.LittleEndian.Uint16(b)&0x1000 != 0 { // fOCX is bit 12 of the ODT's flags
+					objects[i].Control = true
+				}
+			case "OCXNAME":
+				objects[i].Control, objects[i].ControlName = true, readOCXName(entry)
+			}
+		}
+	}
+	for i := range objects {
+		if objects[i].CLSID == "00000000-0000-0000-0000-000000000000" {
+			objects[i].CLSID = ""
+		}
+		if v, ok := clsids[objects[i].CLSID]; ok {
+			objects[i].Type = v
+		}
+		if v, ok := controls[objects[i].CLSID]; ok {
+			objects[i].Type, objects[i].Control = v, true
+		}
+	}
+	return objects
+}
+
+// readStart returns up to the first n bytes of a stream
+func readStart(f *mscfb.File, n int64) []byte {
+	if f.Size < n {
+		n = f.Size
+	}
+	b := make([]byte, n)
+	m, _ := f.ReadAt(b, 0)
+	return b[:m]
+}
+
+// readCompObj reads the AnsiUserType and AnsiProgID from a CompObj stream: after a 28 byte header come the AnsiUserType (a 32-bit length, including the terminating null, and the string),
+// the AnsiClipboardFormat (a 32-bit marker: 0 for none, 0xFFFFFFFF or 0xFFFFFFFE followed by a 32-bit format ID, or else the length of a string that follows) and then the AnsiProgID.
+func readCompObj(f *mscfb.File, codePage uint16) (string, string) {
+	b := readStart(f, 4096)
+	str := func(i int) (string, int) {
+		if i+4 > len(b) {
+			return "", len(b)
+		}
+		l := int(binary.LittleEndian.Uint32(b[i:]))
+		i += 4
+		if l > len(b)-i {
+			return "", len(b)
+		}
+		return decodeString(bytes.TrimRight(b[i:i+l], "\x00"), codePage), i + l
+	}
+	userType, i := str(28)
+	if i+4 <= len(b) {
+		switch binary.LittleEndian.Uint32(b[i:]) {
+		case 0:
+			i += 4
+		case 0xFFFFFFFF, 0xFFFFFFFE:
+			i += 8
+		default:
+			_, i = str(i)
+		}
+	}
+	progID, _ := str(i)
+	return userType, progID
+}
+
+// readOle10Native reads the label (usually the name of the file) from an Ole10Native stream: after the 32-bit size of the native data and a 16-bit flag comes the label, as a null-terminated string
+func readOle10Native(f *mscfb.File, codePage uint16) string {
+	b := readStart(f, 1024)
+	if len(b) < 6 {
+		return ""
+	}
+	b = b[6:]
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return decodeString(b[:i], codePage)
+	}
+	return ""
+}
+
+// readOCXName reads the name of an ActiveX control from an OCXNAME stream: UTF-16 text, with a terminating null
+func readOCXName(f *mscfb.File) string {
+	b := readStart(f, 1024)
+	for i := 0; i+1 < len(b); i += 2 {
+		if b[i] == 0 && b[i+1] == 0 {
+			return utf16String(b[:i])
+		}
+	}
+	return utf16String(b[:len(b)/2*2])
+}
+
+// Controls returns the embedded objects that are ActiveX controls, in the order of the ObjectPool
+func (d *Report) Controls() []Object {
+	var cs []Object
+	for _, o := range d.Objects {
+		if o.Control {
+			cs = append(cs, o)
+		}
+	}
+	return cs
+}
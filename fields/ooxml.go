@@ -0,0 +1,329 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// OOXML (.docx, .docm) documents are zip packages of XML parts. Fields are marked up in the text itself, either as a w:fldSimple element
+// (with the instruction in its w:instr attribute and the result as its content) or as runs of w:fldChar begin/separate/end elements with the instruction in w:instrText runs.
+// They have no field codes, so fields are named from the keyword of their instruction text.
+
+// the relationship types (the last part of the Type URI) of the parts related to the main document part that hold fields, with the index in fieldRegions of the region each is reported as
+var ooxmlParts = []struct {
+	typ    string
+	region int
+}{
+	{"header", 1},
+	{"footer", 1},
+	{"footnotes", 2},
+	{"comments", 3},
+	{"endnotes", 4},
+}
+
+// the regions that fields in textboxes (w:txbxContent) are reported as: the textbox region for the main document, and the header/footer textbox region for headers and footers
+var ooxmlTextboxes = map[int]int{0: 5, 1: 6}
+
+// readerSize returns the size of ra, which zip needs. If ra doesn't have a Size or Stat method, it is read into memory to find out.
+func readerSize(ra io.ReaderAt) (io.ReaderAt, int64, error) {
+	switch r := ra.(type) {
+	case interface{ Size() int64 }:
+		return ra, r.Size(), nil
+	case interface{ Stat() (os.FileInfo, error) }:
+		if fi, err := r.Stat(); err == nil {
+			return ra, fi.Size(), nil
+		}
+	}
+	buf, err := io.ReadAll(io.NewSectionReader(ra, 0, math.MaxInt64))
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(buf), int64(len(buf)), nil
+}
+
+type relationships struct {
+	Relationship []struct {
+		ID         string `xml:"Id,attr"`
+		Type       string `xml:"Type,attr"`
+		Target     string `xml:"Target,attr"`
+		TargetMode string `xml:"TargetMode,attr"`
+	}
+}
+
+// relTargets returns the names of the parts that the source part (or the package, if source is empty) has relationships of the given type with
+func relTargets(files map[string]*zip.File, source, typ string) []string {
+	relsName := "_rels/.rels"
+	if source != "" {
+		relsName = path.Join(path.Dir(source), "_rels", path.Base(source)+".rels")
+	}
+	f, ok := files[relsName]
+	if !ok {
+		return nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+	var rels relationships
+	if err := xml.NewDecoder(rc).Decode(&rels); err != nil {
+		return nil
+	}
+	var targets []string
+	for _, rel := range rels.Relationship {
+		if rel.TargetMode == "External" || !strings.HasSuffix(rel.Type, "/"+typ) {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			targets = append(targets, rel.Target[1:])
+		} else {
+			targets = append(targets, path.Join(path.Dir(source), rel.Target))
+		}
+	}
+	return targets
+}
+
+// scanPart reads the fields in an XML part into region, or into textbox for fields in textboxes (if it isn't nil), and returns the name of the part's root element.
+// The VML fallback (mc:Fallback) for a drawing is skipped, as it repeats the content of the drawing's textboxes.
+func scanPart(ctx context.Context, r io.Reader, region, textbox *markupRegion) (string, error) {
+	dec := xml.NewDecoder(r)
+	var root string
+	var inText bool
+	var inTextbox int
+	current := region
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return root, nil
+		}
+		if err != nil {
+			return root, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root == "" {
+				root = t.Name.Local
+			}
+			switch t.Name.Local {
+			case "Fallback":
+				if err := dec.Skip(); err != nil {
+					return root, err
+				}
+			case "txbxContent":
+				inTextbox++
+				if textbox != nil {
+					current = textbox
+				}
+			case "fldSimple":
+				current.begin()
+				for _, a := range t.Attr {
+					if a.Name.Local == "instr" {
+						current.text(a.Value)
+					}
+				}
+				current.separate()
+			case "fldChar":
+				for _, a := range t.Attr {
+					if a.Name.Local != "fldCharType" {
+						continue
+					}
+					switch a.Value {
+					case "begin":
+						current.begin()
+					case "separate":
+						current.separate()
+					case "end":
+						current.end()
+					}
+				}
+			case "t", "instrText", "delText", "delInstrText":
+				inText = true
+			case "tab":
+				current.text("\t")
+			case "br", "cr":
+				current.mark()
+			case "noBreakHyphen", "softHyphen", "sym":
+				current.cp++
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "txbxContent":
+				if inTextbox--; inTextbox == 0 {
+					current = region
+				}
+			case "fldSimple":
+				current.end()
+			case "t", "instrText", "delText", "delInstrText":
+				inText = false
+			case "p":
+				if err := ctx.Err(); err != nil {
+					return root, err
+				}
+				current.mark() // the paragraph mark
+			}
+		case xml.CharData:
+			if inText {
+				current.text(string(t))
+			}
+		}
+	}
+}
+
+// readPart opens a part of the package and scans it (see scanPart)
+func readPart(ctx context.Context, f *zip.File, region, textbox *markupRegion) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	return scanPart(ctx, rc, region, textbox)
+}
+
+// openTarget opens the first part the package has a relationship of the given type with, or returns nil
+func openTarget(files map[string]*zip.File, typ string) io.ReadCloser {
+	targets := relTargets(files, "", typ)
+	if len(targets) == 0 || files[targets[0]] == nil {
+		return nil
+	}
+	rc, err := files[targets[0]].Open()
+	if err != nil {
+		return nil
+	}
+	return rc
+}
+
+// readPackageProperties reads the document properties from the package's core (docProps/core.xml) and extended (docProps/app.xml) properties parts,
+// along with the application that last saved the package, as metadata
+func readPackageProperties(files map[string]*zip.File) ([]Property, DocProperties) {
+	var props []Property
+	var dp DocProperties
+	if rc := openTarget(files, "extended-properties"); rc != nil {
+		var app struct {
+			Application string
+			AppVersion  string
+			Template    string
+			Company     string
+		}
+		if err := xml.NewDecoder(rc).Decode(&app); err == nil {
+			dp.Application, dp.Template, dp.Company = app.Application, app.Template, app.Company
+			if app.Application != "" {
+				if app.AppVersion != "" {
+					app.Application += " " + app.AppVersion
+				}
+				props = append(props, Property{"Last saving application (app.xml)", app.Application})
+			}
+		}
+		rc.Close()
+	}
+	if rc := openTarget(files, "core-properties"); rc != nil {
+		var core struct {
+			Title          string `xml:"title"`
+			Subject        string `xml:"subject"`
+			Creator        string `xml:"creator"`
+			LastModifiedBy string `xml:"lastModifiedBy"`
+			Created        string `xml:"created"`
+			Modified       string `xml:"modified"`
+		}
+		if err := xml.NewDecoder(rc).Decode(&core); err == nil {
+			dp.Title, dp.Subject, dp.Author, dp.LastSavedBy = core.Title, core.Subject, core.Creator, core.LastModifiedBy
+			dp.Created, dp.Modified = w3cdtf(core.Created), w3cdtf(core.Modified)
+		}
+		rc.Close()
+	}
+	return props, dp
+}
+
+// w3cdtf gives a time from the core properties in the same form as those from a property set, or as it is if it can't be parsed
+func w3cdtf(s string) string {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC().String()
+	}
+	return s
+}
+
+// parseOOXML reports the fields in an OOXML package, in the same regions as a .doc.
+// The package's main part is found through its relationships (so it needn't be word/document.xml), and the headers, footers, footnotes, comments and endnotes through the main part's.
+// A package whose main part isn't a WordprocessingML document (e.g. a spreadsheet) gives ErrOOXML.
+func parseOOXML(ctx context.Context, ra io.ReaderAt, results bool) (*Report, error) {
+	ra, size, err := readerSize(ra)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	main := relTargets(files, "", "officeDocument")
+	if len(main) == 0 || files[main[0]] == nil {
+		return nil, wrapError(ErrOOXML)
+	}
+	res := &Report{Format: FormatOOXML}
+	res.Metadata, res.Properties = readPackageProperties(files)
+	res.CustomProperties = readPackageCustom(files)
+	res.Macros = len(relTargets(files, main[0], "vbaProject")) > 0
+	res.MailMerge = readMailMergeSettings(files, main[0])
+	regions := make([]markupRegion, len(fieldRegions))
+	for i := range regions {
+		regions[i].results = results
+	}
+	root, err := readPart(ctx, files[main[0]], &regions[0], &regions[ooxmlTextboxes[0]])
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if root != "document" {
+		return nil, wrapError(ErrOOXML)
+	}
+	if err != nil {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("%s can't all be read: %v", main[0], err))
+	}
+	for _, p := range ooxmlParts {
+		for _, name := range relTargets(files, main[0], p.typ) {
+			f, ok := files[name]
+			if !ok {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s part %s is missing from the package", fieldRegions[p.region].name, name))
+				continue
+			}
+			var textbox *markupRegion
+			if tb, ok := ooxmlTextboxes[p.region]; ok {
+				textbox = &regions[tb]
+			}
+			if _, err := readPart(ctx, f, &regions[p.region], textbox); err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s can't all be read: %v", name, err))
+			}
+		}
+	}
+	if !res.setMarkupRegions(regions) {
+		return res, ErrNoFields
+	}
+	return res, nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Picture is a picture in the text of a .doc: a picture character (0x01) whose character formatting locates a PICF in the Data stream, which is followed by the image
+type Picture struct {
+	Region string // the key of the region the picture is in (body, header etc.)
+	CP     uint32 // the character position of the picture, relative to the start of the region
+	Offset uint32 // the offset of its PICF in the Data stream
+	Format string // the format of the stored image: EMF, WMF, PICT, JPEG, PNG, DIB or TIFF, or empty if no image is stored (or it can't be read)
+	Size   uint32 // the size in bytes of the stored image (its OfficeArtBlip record, or the metafile), or 0 if none is stored
+	Link   string // for a linked picture, the path of the file it is linked to
+	// for the result of an INCLUDEPICTURE field, the character position of the field's begin character (in the same region), and whether it is in one
+	Field    uint32
+	Included bool
+	Damaged  bool // the PICF, or the records after it, run past the end of the Data stream or are malformed
+}
+
+// Stored reports whether the image itself is in the document, rather than only linked to
+func (p Picture) Stored() bool {
+	return p.Size > 0
+}
+
+// the character sprms that say what a character with a sprmCPicLocation stands for: a special character (fSpec), an OLE object whose storage in the ObjectPool
+// the location names (fOle2) and a form field whose FFData the location gives (fData)
+const (
+	sprmCFSpec = 0x0855
+	sprmCFOle2 = 0x080A
+	sprmCFData = 0x0806
+)
+
+// the mapping modes (mfpf.mm) of a PICF that mean an OfficeArt shape and its image follow, rather than a bare Windows metafile: MM_SHAPE and, for a linked picture, MM_SHAPEFILE, where the path of the file comes first
+const (
+	mmShape     = 0x0064
+	mmShapeFile = 0x0066
+)
+
+// the OfficeArt record types of the images stored after a PICF: an OfficeArtFBSE, which describes the image and is followed by it, or the image's own record (an OfficeArtBlip)
+const (
+	recFBSE        = 0xF007
+	recBlipMin     = 0xF018
+	recBlipMax     = 0xF117
+	recSpContainer = 0xF004
+)
+
+// blipFormats are the formats of images, by their OfficeArtBlip record type
+var blipFormats = map[uint16]string{
+	0xF01A: "EMF",
+	0xF01B: "WMF",
+	0xF01C: "PICT",
+	0xF01D: "JPEG",
+	0xF01E: "PNG",
+	0xF01F: "DIB",
+	0xF029: "TIFF",
+	0xF02A: "JPEG", // CMYK
+}
+
+// fbseFormats are the formats of images, by the btWin32 of their OfficeArtFBSE
+var fbseFormats = map[byte]string{
+	0x02: "EMF",
+	0x03: "WMF",
+	0x04: "PICT",
+	0x05: "JPEG",
+	0x06: "PNG",
+	0x07: "DIB",
+	0x11: "TIFF",
+	0x12: "JPEG", // CMYK
+}
+
+// the most characters of a run of formatted text looked at for picture characters: a picture's run is normally just its own character
+const maxPictureRun = 64
+
+// readPictures lists the pictures in the document's text. They are found from the sprms in the character formatting (see scanChpx): a picture is a special character 0x01
+// (an OLE object is one too, but is marked with fOle2) with a sprmCPicLocation giving the offset of its PICF in the Data stream. Runs outside the piece table are ignored.
+// data can be nil, if the document has no Data stream (and so no pictures). Word 6.0 and Word 95 documents, whose sprms are different, aren't read.
+// codePage is the code page of the path of a linked picture (see ansiCodePage).
+func (d *Report) readPictures(doc io.ReaderAt, docSize int64, data io.ReaderAt, dataSize int64, table io.ReaderAt, tableSize int64, fcLcb []byte, pieces []piece, counts []uint32, codePage uint16) error {
+	d.Pictures = []Picture{}
+	if data == nil {
+		return nil
+	}
+	bte, err := readTableData(table, tableSize, fcLcb, 12)
+	if err != nil || bte == nil {
+		return err
+	}
+	return scanChpx(doc, docSize, bte, func(fcStart, fcEnd uint32, grpprl []byte) {
+		var spec, object, found bool
+		var location uint32
+		forSprms(grpprl, func(sprm uint16, operand []byte) {
+			switch sprm {
+			case sprmCFSpec:
+				spec = operand[0]&0x7F == 1
+			case sprmCFOle2, sprmCFData:
+				object = object || operand[0]&0x7F == 1
+			case sprmCPicLocation:
+				location, found = binary.LittleEndian.Uint32(operand), true
+			}
+		})
+		if !spec || object || !found {
+			return
+		}
+		for _, p := range pieces {
+			size := uint32(2)
+			if p.compressed {
+				size = 1
+			}
+			end := p.fc + (p.cpEnd-p.cpStart)*size
+			if fcStart >= end || fcEnd <= p.fc {
+				continue
+			}
+			start := fcStart
+			if start < p.fc {
+				start = p.fc
+			} else {
+				start -= (start - p.fc) % size
+			}
+			if fcEnd < end {
+				end = fcEnd
+			}
+			for n, fc := 0, start; fc+size <= end && n < maxPictureRun; n, fc = n+1, fc+size {
+				c := make([]byte, size)
+				if _, err := doc.ReadAt(c, int64(fc)); err != nil || c[0] != 0x01 || (size == 2 && c[1] != 0) {
+					continue
+				}
+				pic := readPICF(data, dataSize, location, codePage)
+				pic.Region, pic.CP = regionOf(p.cpStart+(fc-p.fc)/size, counts)
+				d.Pictures = append(d.Pictures, pic)
+			}
+		}
+	})
+}
+
+// regionOf returns the key of the region that a character position in the document's text is in, along with the position relative to the start of the region
+func regionOf(cp uint32, counts []uint32) (string, uint32) {
+	var base uint32
+	for i, c := range counts {
+		if cp < base+c {
+			for _, fr := range fieldRegions {
+				if fr.text == i {
+					return fr.key, cp - base
+				}
+			}
+			break
+		}
+		base += c
+	}
+	return "", cp
+}
+
+// readPICF reads the picture whose PICF is at the given offset in the Data stream. The PICF starts with the 32-bit size (lcb) of it and all that follows it,
+// a 16-bit cbHeader of 0x44, and the mfpf, whose 16-bit mm says what follows the 0x44 bytes: for MM_SHAPEFILE, the path of the linked file (an 8-bit count of characters,
+// then the 8-bit characters), and, for it and MM_SHAPE, an OfficeArtInlineSpContainer: the shape's OfficeArtSpContainer, then the OfficeArtFBSE (or the bare OfficeArtBlip) with the image.
+// Any other mm is a Windows metafile mapping mode, used by older versions of Word, and the rest of the lcb bytes are the metafile.
+func readPICF(data io.ReaderAt, dataSize int64, off uint32, codePage uint16) Picture {
+	pic := Picture{Offset: off}
+	hdr := make([]byte, 8)
+	if int64(off)+0x44 > dataSize {
+		pic.Damaged = true
+		return pic
+	}
+	if _, err := data.ReadAt(hdr, int64(off)); err != nil {
+		pic.Damaged = true
+		return pic
+	}
+	lcb, cbHeader, mm := int64(binary.LittleEndian.Uint32(hdr)), int64(binary.LittleEndian.Uint16(hdr[4:])), binary.LittleEndian.Uint16(hdr[6:])
+	end := int64(off) + lcb
+	if cbHeader != 0x44 || lcb < cbHeader || end > dataSize {
+		pic.Damaged = true
+		return pic
+	}
+	pos := int64(off) + cbHeader
+	if mm != mmShape && mm != mmShapeFile {
+		if lcb > cbHeader {
+			pic.Format, pic.Size = "WMF", uint32(lcb-cbHeader)
+		}
+		return pic
+	}
+	if mm == mmShapeFile {
+		cch := make([]byte, 1)
+		if pos+1 > end {
+			pic.Damaged = true
+			return pic
+		}
+		data.ReadAt(cch, pos)
+		name := make([]byte, cch[0])
+		if pos+1+int64(cch[0]) > end {
+			pic.Damaged = true
+			return pic
+		}
+		data.ReadAt(name, pos+1)
+		pic.Link = decodeString(name, codePage)
+		pos += 1 + int64(cch[0])
+	}
+	rh := make([]byte, 8)
+	for first := true; pos < end; first = false {
+		if pos+8 > end {
+			pic.Damaged = true
+			return pic
+		}
+		if _, err := data.ReadAt(rh, pos); err != nil {
+			pic.Damaged = true
+			return pic
+		}
+		recType, recLen := binary.LittleEndian.Uint16(rh[2:]), int64(binary.LittleEndian.Uint32(rh[4:]))
+		if pos+8+recLen > end || (first && recType != recSpContainer) {
+			pic.Damaged = true
+			return pic
+		}
+		switch {
+		case recType == recFBSE:
+			fbse := make([]byte, 36) // btWin32, btMacOS, the rgbUid, tag, size, cRef, foDelay, four bytes with cbName, then the name and the image
+			if recLen < 36 {
+				pic.Damaged = true
+				return pic
+			}
+			data.ReadAt(fbse, pos+8)
+			if recLen >= 36+int64(fbse[33])+8 { // the image's OfficeArtBlip is there, and not elsewhere (e.g. in a linked picture, just the FBSE is kept)
+				pic.Format, pic.Size = fbseFormats[fbse[0]], binary.LittleEndian.Uint32(fbse[20:])
+			}
+			return pic
+		case recType >= recBlipMin && recType <= recBlipMax:
+			pic.Format, pic.Size = blipFormats[recType], uint32(8+recLen)
+			return pic
+		}
+		pos += 8 + recLen
+	}
+	return pic
+}
+
+// setPictureFields marks the pictures that are the results of INCLUDEPICTURE fields, which are between a field's separator and end characters
+func (d *Report) setPictureFields() {
+	for i, p := range d.Pictures {
+		for _, f := range d.Occurrences[p.Region] {
+			if f.Name == "include picture" && f.Separator != 0 && p.CP > f.Separator && p.CP < f.End {
+				d.Pictures[i].Field, d.Pictures[i].Included = f.CP, true // later fields are nested in earlier ones, so the last match is the innermost
+			}
+		}
+	}
+}
+
+// FieldPicture returns the picture in the result of an INCLUDEPICTURE field in a region, or false if its result has none, or the pictures weren't read
+func (d *Report) FieldPicture(region string, f Field) (Picture, bool) {
+	for _, p := range d.Pictures {
+		if p.Included && p.Region == region && p.Field == f.CP {
+			return p, true
+		}
+	}
+	return Picture{}, false
+}
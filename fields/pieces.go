@@ -0,0 +1,278 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+var errClx = errors.New("malformed piece table (Clx)")
+
+// piece is a run of the document's text in the WordDocument stream, as listed in the piece table
+type piece struct {
+	cpStart, cpEnd uint32 // the character positions of the run
+	fc             uint32 // the byte offset of the run in the WordDocument stream
+	compressed     bool   // one byte per character, rather than UTF-16
+	codePage       uint16 // the code page of a compressed run (see textCodePage)
+}
+
+// readPieces reads the piece table (the PlcPcd in the Pcdt) from the Clx. The Clx starts with any number of Prcs (0x01, a 16-bit size and that many bytes),
+// which are skipped, and then has the Pcdt (0x02, a 32-bit size and the PlcPcd): n+1 CPs followed by n 8-byte Pcds, each with the fc of its run at offset 2.
+// Word 97 and later mark 8-bit runs with bit 30 of the fc (and double their offset); Word 6.0 and Word 95 runs are always 8-bit.
+// codePage is the code page of the 8-bit runs. The CPs must be in ascending order, so that no piece ends before it starts.
+func readPieces(clx []byte, word6 bool, codePage uint16) ([]piece, error) {
+	i := 0
+	for i < len(clx) && clx[i] == 0x01 {
+		if i+3 > len(clx) {
+			return nil, errClx
+		}
+		i += 3 + int(binary.LittleEndian.Uint16(clx[i+1:i+3]))
+	}
+	if i+5 > len(clx) || clx[i] != 0x02 {
+		return nil, errClx
+	}
+	lcb := int(binary.LittleEndian.Uint32(clx[i+1 : i+5]))
+	plc := clx[i+5:]
+	if lcb < 4 || lcb > len(plc) {
+		return nil, errClx
+	}
+	plc = plc[:lcb]
+	n := (len(plc) - 4) / 12
+	pieces := make([]piece, n)
+	for j := range pieces {
+		pcd := plc[(n+1)*4+j*8:]
+		p := piece{
+			cpStart:  binary.LittleEndian.Uint32(plc[j*4:]),
+			cpEnd:    binary.LittleEndian.Uint32(plc[j*4+4:]),
+			fc:       binary.LittleEndian.Uint32(pcd[2:6]),
+			codePage: codePage,
+		}
+		if p.cpEnd < p.cpStart { // else the length of the run would wrap around, and a read of it would be gigabytes
+			return nil, errClx
+		}
+		switch {
+		case word6:
+			p.compressed = true
+		case p.fc&0x40000000 != 0:
+			p.compressed = true
+			p.fc = (p.fc & 0x3FFFFFFF) / 2
+		}
+		pieces[j] = p
+	}
+	return pieces, nil
+}
+
+// loadPieces reads the piece table from the Clx, which is located by pair 33 of the FibRgFcLcb (fcClx/lcbClx).
+// A Word 6.0 or Word 95 document that wasn't fast saved has no Clx: its text is in a single 8-bit run starting at fcMin (offset 24 of the FIB).
+func loadPieces(table io.ReaderAt, tableSize int64, fib, fcLcb []byte) ([]piece, error) {
+	word6 := isWord6(binary.LittleEndian.Uint16(fib[2:4]))
+	if len(fcLcb) < 34*8 {
+		return nil, errClx
+	}
+	fc, lcb := binary.LittleEndian.Uint32(fcLcb[33*8:]), binary.LittleEndian.Uint32(fcLcb[33*8+4:])
+	if lcb == 0 {
+		if word6 {
+			return []piece{{0, ^uint32(0), binary.LittleEndian.Uint32(fib[24:28]), true, textCodePage(fib)}}, nil
+		}
+		return nil, errClx
+	}
+	if uint64(fc)+uint64(lcb) > uint64(tableSize) {
+		return nil, errClx
+	}
+	if err := checkSize(table, int64(lcb)); err != nil {
+		return nil, err
+	}
+	clx := make([]byte, lcb)
+	if n, err := table.ReadAt(clx, int64(fc)); n < len(clx) {
+		if err == nil || err == io.EOF {
+			err = errClx
+		}
+		return nil, err
+	}
+	return readPieces(clx, word6, textCodePage(fib))
+}
+
+// fcOf returns the offset in the WordDocument stream of the character at a character position, or false if the piece table doesn't cover it
+func fcOf(pieces []piece, cp uint32) (uint32, bool) {
+	for _, p := range pieces {
+		if cp >= p.cpStart && cp < p.cpEnd {
+			if p.compressed {
+				return p.fc + cp - p.cpStart, true
+			}
+			return p.fc + (cp-p.cpStart)*2, true
+		}
+	}
+	return 0, false
+}
+
+// the longest instruction returned by readText, so a damaged CP can't cause a huge read
+const maxInstruction = 4096
+
+// readText returns the text between two character positions (start inclusive, end exclusive), leaving out any field characters.
+// Text beyond maxInstruction characters is dropped.
+func readText(doc io.ReaderAt, pieces []piece, start, end uint32) (string, error) {
+	text, err := readChars(doc, pieces, start, end)
+	return strings.Map(func(r rune) rune {
+		if r == 0x13 || r == 0x14 || r == 0x15 { // the characters of nested fields
+			return -1
+		}
+		return r
+	}, text), err
+}
+
+// readResult returns a field's result text between two character positions, as readText does, but as it is shown rather than as it is stored:
+// the instructions of any fields nested in it are left out (their results are kept), paragraph marks and breaks become newlines, and other control characters are dropped.
+func readResult(doc io.ReaderAt, pieces []piece, start, end uint32) (string, error) {
+	text, err := readChars(doc, pieces, start, end)
+	var fields []bool // for each field nested in the result, innermost last, whether the text is in its instructions, as for writeText
+	var sb strings.Builder
+	for _, r := range text {
+		switch r {
+		case 0x13:
+			fields = append(fields, true)
+			continue
+		case 0x14:
+			if len(fields) > 0 {
+				fields[len(fields)-1] = false
+			}
+			continue
+		case 0x15:
+			if len(fields) > 0 {
+				fields = fields[:len(fields)-1]
+			}
+			continue
+		case 0x0D, 0x0B, 0x0C, 0x07:
+			r = '\n'
+		case 0x1E: // non-breaking hyphen
+			r = '-'
+		}
+		if r < 0x20 && r != '\t' && r != '\n' {
+			continue
+		}
+		hidden := false
+		for _, f := range fields {
+			hidden = hidden || f
+		}
+		if !hidden {
+			sb.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(sb.String()), err
+}
+
+// readChars returns the text between two character positions as it is stored, field characters and all, for readText and readResult
+func readChars(doc io.ReaderAt, pieces []piece, start, end uint32) (string, error) {
+	if end < start {
+		return "", nil
+	}
+	if end-start > maxInstruction { // not end > start+maxInstruction, which wraps around for a damaged CP near 2^32
+		end = start + maxInstruction
+	}
+	var sb strings.Builder
+	for _, p := range pieces {
+		if p.cpEnd <= start || p.cpStart >= end {
+			continue
+		}
+		from, to := start, end
+		if from < p.cpStart {
+			from = p.cpStart
+		}
+		if to > p.cpEnd {
+			to = p.cpEnd
+		}
+		if from >= to {
+			continue
+		}
+		size := uint32(2)
+		if p.compressed {
+			size = 1
+		}
+		if err := checkSize(doc, int64(to-from)*int64(size)); err != nil {
+			return sb.String(), err
+		}
+		bp := getBuf(int((to - from) * size))
+		buf := *bp
+		if n, err := doc.ReadAt(buf, int64(p.fc)+int64(from-p.cpStart)*int64(size)); n < len(buf) {
+			putBuf(bp)
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return sb.String(), err
+		}
+		if p.compressed {
+			sb.WriteString(decodeString(buf, p.codePage))
+		} else {
+			u := make([]uint16, len(buf)/2)
+			for i := range u {
+				u[i] = binary.LittleEndian.Uint16(buf[i*2:])
+			}
+			sb.WriteString(string(utf16.Decode(u)))
+		}
+		putBuf(bp)
+	}
+	return sb.String(), nil
+}
+
+// readInstructions sets the instruction text of each field: the text between its begin character and its separator (or its end, if it has no separator),
+// and, if results is set, the result text of each field with a separator, between that and its end.
+// The fields' CPs are offset by base, the start of their region's text.
+func readInstructions(doc io.ReaderAt, pieces []piece, base uint32, fields []Field, results bool) error {
+	for i, f := range fields {
+		end := f.Separator
+		if end == 0 {
+			end = f.End
+		}
+		if end <= f.CP {
+			continue
+		}
+		text, err := readText(doc, pieces, base+f.CP+1, base+end)
+		if err != nil {
+			return err
+		}
+		fields[i].Instruction = strings.TrimSpace(text)
+		if results && f.Separator != 0 && f.End > f.Separator {
+			if fields[i].Result, err = readResult(doc, pieces, base+f.Separator+1, base+f.End); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ccps returns the number of characters in each part of the document's text, which are stored one after the other:
+// the main document, footnotes, headers, macros (unused), comments, endnotes, textboxes and header textboxes.
+// They are in the FibRgLw (from its fourth value on), or at offset 52 of a Word 6.0 or Word 95 FIB.
+func ccps(fib []byte) []uint32 {
+	off := 52
+	if !isWord6(binary.LittleEndian.Uint16(fib[2:4])) {
+		l := 34 + int(binary.LittleEndian.Uint16(fib[32:34]))*2
+		if binary.LittleEndian.Uint16(fib[l:l+2]) < 11 { // cslw: the FibRgLw is too short
+			return nil
+		}
+		off = l + 2 + 12
+	}
+	if off+32 > len(fib) {
+		return nil
+	}
+	c := make([]uint32, 8)
+	for i := range c {
+		c[i] = binary.LittleEndian.Uint32(fib[off+i*4:])
+	}
+	return c
+}
@@ -0,0 +1,73 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// clxOf builds a Clx holding just a Pcdt, with a piece for each pair of neighbouring CPs, all of them 8-bit runs at the start of the stream
+func clxOf(cps ...uint32) []byte {
+	var plc []byte
+	for _, cp := range cps {
+		plc = binary.LittleEndian.AppendUint32(plc, cp)
+	}
+	for range cps[1:] {
+		plc = append(plc, 0, 0)
+		plc = binary.LittleEndian.AppendUint32(plc, 0x40000000)
+		plc = append(plc, 0, 0)
+	}
+	clx := append([]byte{0x02}, binary.LittleEndian.AppendUint32(nil, uint32(len(plc)))...)
+	return append(clx, plc...)
+}
+
+func TestReadPieces(t *testing.T) {
+	pieces, err := readPieces(clxOf(0, 10, 25), false, 1252)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pieces) != 2 || pieces[1].cpStart != 10 || pieces[1].cpEnd != 25 || !pieces[1].compressed {
+		t.Errorf("got pieces %+v, want 0-10 and 10-25, both compressed", pieces)
+	}
+	for _, cps := range [][]uint32{{50, 10}, {0, 100, 50, 200}} {
+		if _, err := readPieces(clxOf(cps...), false, 1252); err != errClx {
+			t.Errorf("readPieces with the CPs %v: got error %v, want errClx", cps, err)
+		}
+	}
+}
+
+// TestReadCharsBackwardsPiece checks that a piece that ends before it starts (which readPieces rejects) gives no text rather than a huge read
+func TestReadCharsBackwardsPiece(t *testing.T) {
+	text, err := readChars(bytes.NewReader([]byte("some text")), []piece{{cpStart: 50, cpEnd: 10, compressed: true}}, 0, 100)
+	if err != nil || text != "" {
+		t.Errorf("got %q, %v, want no text", text, err)
+	}
+}
+
+// TestReadCharsNearMaxCP checks that a range of CPs near 2^32 is read, rather than being lost when the maxInstruction limit wraps around
+func TestReadCharsNearMaxCP(t *testing.T) {
+	doc := bytes.NewReader(bytes.Repeat([]byte("a"), 4096))
+	pieces := []piece{{cpStart: 0xFFFFF000, cpEnd: 0xFFFFFFFF, compressed: true, codePage: 1252}}
+	text, err := readChars(doc, pieces, 0xFFFFF800, 0xFFFFF810)
+	if err != nil || text != strings.Repeat("a", 16) {
+		t.Errorf("got %q, %v, want 16 characters", text, err)
+	}
+	if text, err := readChars(doc, pieces, 0xFFFFF810, 0xFFFFF800); err != nil || text != "" {
+		t.Errorf("an end before the start: got %q, %v, want no text", text, err)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import "sync"
+
+// bufPool holds the buffers that field data and text are read into, so that a run over many documents reuses them rather than allocating new ones for each
+var bufPool = sync.Pool{New: func() any { return new([]byte) }}
+
+// the capacity of the largest buffer put back in bufPool, so that one big document doesn't leave its buffers behind
+const maxPooled = 1 << 20
+
+// getBuf returns a buffer of n bytes from bufPool. Its contents are undefined. Hand it back with putBuf once nothing refers to it.
+func getBuf(n int) *[]byte {
+	b := bufPool.Get().(*[]byte)
+	if cap(*b) < n {
+		*b = make([]byte, n)
+	}
+	*b = (*b)[:n]
+	return b
+}
+
+// putBuf puts a buffer from getBuf back in bufPool
+func putBuf(b *[]byte) {
+	if cap(*b) <= maxPooled {
+		bufPool.Put(b)
+	}
+}
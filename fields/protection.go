@@ -0,0 +1,159 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Protection is how a .doc is protected against editing, from the flags in its Dop (and, for the read-only recommendation, the FIB). Protection can be turned off
+// by anyone who knows the password (and the password isn't strong: Word only keeps a hash of it), so it records intent rather than enforcing it.
+type Protection struct {
+	Forms               bool // fProtEnabled: only form fields can be filled in
+	Comments            bool // fLockAtn: only comments can be added
+	Revisions           bool // fLockRev: changes are tracked, and tracking can't be turned off
+	ReadOnlyRecommended bool // Word suggests opening the document read-only (fReadOnlyRecommended in the FIB or the Dop)
+	WriteReservation    bool // a password is needed to save changes to the document (fWriteReservation)
+}
+
+// Protected reports whether editing is restricted at all
+func (p Protection) Protected() bool {
+	return p.Forms || p.Comments || p.Revisions || p.ReadOnlyRecommended || p.WriteReservation
+}
+
+// readProtection reads the protection flags from the Dop, which Word 6.0 and later all start the same way, and the FIB's flags
+func readProtection(dop []byte, flags Flags) Protection {
+	p := Protection{ReadOnlyRecommended: flags.ReadOnlyRecommended, WriteReservation: flags.WriteReservation}
+	if len(dop) > 7 {
+		p.Comments = dop[6]&0x10 != 0
+		p.ReadOnlyRecommended = p.ReadOnlyRecommended || dop[6]&0x40 != 0
+		p.Forms = dop[7]&0x02 != 0
+		p.WriteReservation = p.WriteReservation || dop[7]&0x20 != 0
+		p.Revisions = dop[7]&0x40 != 0
+	}
+	return p
+}
+
+// FormField is a text, check box or drop-down form field (FORMTEXT, FORMCHECKBOX or FORMDROPDOWN), with the settings Word stores for it in an FFData
+type FormField struct {
+	Region  string   // the key of the region the field is in (body, header etc.)
+	CP      uint32   // the character position of the field's begin character, relative to the start of the region
+	Type    string   // the field's name: form text, form checkbox or form dropdown
+	Name    string   // the form field's name, which is also the name of the bookmark Word puts round it
+	Default string   // a text field's default text, a check box's default state (checked or unchecked), or a drop-down's default entry
+	Entries []string // a drop-down's entries
+	// the macros run when the reader enters and leaves the field
+	EntryMacro, ExitMacro string
+}
+
+// the types of form field, from the iType of an FFData
+const (
+	iTypeText = iota
+	iTypeCheckBox
+	iTypeDropDown
+)
+
+// setFormFields lists the form fields in the document, from their occurrences
+func (d *Report) setFormFields() {
+	d.FormFields = nil
+	for _, r := range d.Regions() {
+		for _, f := range r.Occurrences {
+			if f.Name == "form text" || f.Name == "form checkbox" || f.Name == "form dropdown" {
+				d.FormFields = append(d.FormFields, FormField{Region: r.Key, CP: f.CP, Type: f.Name})
+			}
+		}
+	}
+}
+
+// readFormFields adds the settings stored in the FFData of each form field. Like a hyperlink's HFD (see readHlinks), a form field's FFData is the binData of a
+// NilPICFAndBinData in the Data stream, located by the sprmCPicLocation in the character formatting of the field's begin character (which also has sprmCFData set).
+func (d *Report) readFormFields(doc io.ReaderAt, docSize int64, data io.ReaderAt, dataSize int64, table io.ReaderAt, tableSize int64, fcLcb []byte, pieces []piece, counts []uint32) error {
+	bte, err := readTableData(table, tableSize, fcLcb, 12)
+	if err != nil || bte == nil {
+		return err
+	}
+	bases := make(map[string]uint32)
+	for _, fr := range fieldRegions {
+		for _, c := range counts[:fr.text] {
+			bases[fr.key] += c
+		}
+	}
+	runs, err := locationRuns(doc, docSize, bte)
+	if err != nil {
+		return err
+	}
+	for i, ff := range d.FormFields {
+		if off, ok := runLocation(runs, pieces, bases[ff.Region]+ff.CP); ok {
+			if b := readBinData(data, dataSize, off, maxFFData); b != nil {
+				d.FormFields[i].parseFFData(b)
+			}
+		}
+	}
+	return nil
+}
+
+// the longest FFData read from the Data stream
+const maxFFData = 65536
+
+// parseFFData reads the settings of a form field from its FFData: a 32-bit version (0xFFFFFFFF), 16 bits of flags that start with the 2-bit iType,
+// the 16-bit cch (maximum length) and hps (check box size), then Xstzs (a 16-bit count of characters, that many UTF-16 characters, and a null) for the name,
+// the default text (only for a text field), then, for a check box or drop-down, the 16-bit wDef (the default state, or index of the default entry), then Xstzs
+// for the text format, the help text, the status bar text and the entry and exit macros, and, for a drop-down, the entries in a STTB.
+func (ff *FormField) parseFFData(b []byte) {
+	if len(b) < 10 {
+		return
+	}
+	iType := int(binary.LittleEndian.Uint16(b[4:]) & 0x03)
+	i := 10
+	xstz := func() string {
+		if i+2 > len(b) {
+			return ""
+		}
+		l := int(binary.LittleEndian.Uint16(b[i:])) * 2
+		if i+2+l+2 > len(b) {
+			i = len(b)
+			return ""
+		}
+		s := utf16String(b[i+2 : i+2+l])
+		i += 2 + l + 2
+		return s
+	}
+	ff.Name = xstz()
+	if iType == iTypeText {
+		ff.Default = xstz()
+	} else if i+2 <= len(b) {
+		def := int(binary.LittleEndian.Uint16(b[i:]))
+		i += 2
+		if iType == iTypeCheckBox {
+			ff.Default = "unchecked"
+			if def != 0 {
+				ff.Default = "checked"
+			}
+		}
+		defer func() {
+			if def < len(ff.Entries) {
+				ff.Default = ff.Entries[def]
+			}
+		}()
+	}
+	for j := 0; j < 3; j++ { // the text format, help text and status bar text
+		xstz()
+	}
+	ff.EntryMacro, ff.ExitMacro = xstz(), xstz()
+	if iType == iTypeDropDown && i < len(b) {
+		ff.Entries, _, _ = readSttb(b[i:], 0)
+	}
+}
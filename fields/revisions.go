@@ -0,0 +1,105 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Revisions summarises the tracked changes (revision marks) in a document that haven't been accepted or rejected
+type Revisions struct {
+	Tracking   bool     // fRevMarking is set in the Dop: Word is tracking changes
+	Insertions int      // the number of runs of text marked as inserted
+	Deletions  int      // the number of runs of text marked as deleted
+	Authors    []string // the authors of the marked runs, from the SttbfRMark, in the order they were found
+}
+
+// Pending reports whether the document has tracked changes that haven't been accepted or rejected
+func (r Revisions) Pending() bool {
+	return r.Insertions > 0 || r.Deletions > 0
+}
+
+// the sprms that mark character runs as revisions
+const (
+	sprmCFRMarkDel    = 0x0800
+	sprmCFRMarkIns    = 0x0801
+	sprmCIbstRMark    = 0x4804 // the index in the SttbfRMark of the author of an insertion
+	sprmCIbstRMarkDel = 0x4863 // and of a deletion
+)
+
+// readRevisions finds the runs of text marked as inserted or deleted, from the sprms in the character formatting of the document's text (see scanChpx).
+// Runs outside the piece table (if there is one) are ignored, as fast saves leave the formatting of text that is no longer in the document.
+// Whether Word is tracking changes is bit 7 of byte 5 of the Dop (pair 31), and the authors' names are in the SttbfRMark (pair 51).
+func readRevisions(table io.ReaderAt, tableSize int64, fcLcb []byte, doc io.ReaderAt, docSize int64, pieces []piece) (Revisions, error) {
+	var rev Revisions
+	dop, err := readTableData(table, tableSize, fcLcb, 31)
+	if err != nil {
+		return rev, err
+	}
+	if len(dop) > 5 {
+		rev.Tracking = dop[5]&0x80 != 0
+	}
+	bte, err := readTableData(table, tableSize, fcLcb, 12)
+	if err != nil || bte == nil {
+		return rev, err
+	}
+	var authors []string
+	if b, err := readTableData(table, tableSize, fcLcb, 51); err == nil && b != nil {
+		authors, _, _ = readSttb(b, 0)
+	}
+	seen := make(map[string]bool)
+	addAuthor := func(ibst int) {
+		if ibst < len(authors) && !seen[authors[ibst]] {
+			seen[authors[ibst]] = true
+			rev.Authors = append(rev.Authors, authors[ibst])
+		}
+	}
+	live := func(fcStart, fcEnd uint32) bool {
+		if pieces == nil {
+			return true
+		}
+		for _, p := range pieces {
+			size := uint32(2)
+			if p.compressed {
+				size = 1
+			}
+			if fcStart < p.fc+(p.cpEnd-p.cpStart)*size && fcEnd > p.fc {
+				return true
+			}
+		}
+		return false
+	}
+	err = scanChpx(doc, docSize, bte, func(fcStart, fcEnd uint32, grpprl []byte) {
+		if !live(fcStart, fcEnd) {
+			return
+		}
+		forSprms(grpprl, func(sprm uint16, operand []byte) {
+			switch sprm {
+			case sprmCFRMarkIns, sprmCFRMarkDel: // a ToggleOperand: 0x01 (or 0x81, the opposite of the style's, which is never marked) means marked
+				if operand[0]&0x7F == 1 {
+					if sprm == sprmCFRMarkIns {
+						rev.Insertions++
+					} else {
+						rev.Deletions++
+					}
+				}
+			case sprmCIbstRMark, sprmCIbstRMarkDel:
+				addAuthor(int(binary.LittleEndian.Uint16(operand)))
+			}
+		})
+	})
+	return rev, err
+}
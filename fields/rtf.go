@@ -0,0 +1,326 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// RTF documents mark up fields as groups: {\field{\*\fldinst INSTRUCTION}{\fldrslt RESULT}}, which can nest inside each other's instructions and results.
+// Like OOXML fields, they have no field codes, so they are named from the keyword of their instruction.
+
+// the destinations (a group's first control word) that start a region other than the body, as indexes in fieldRegions.
+// An endnote is a footnote group with \ftnalt; a textbox (shape text, or the text of an old-style drawing object) is reported in the textbox region of the body or the header/footer it is in.
+var rtfRegions = map[string]int{
+	"header": 1, "headerl": 1, "headerr": 1, "headerf": 1,
+	"footer": 1, "footerl": 1, "footerr": 1, "footerf": 1,
+	"footnote":   2,
+	"annotation": 3,
+	"shptxt":     5, "dptxbxtext": 5,
+}
+
+// the destinations whose content isn't part of the document's text, so is skipped (as are any others marked ignorable with \*, apart from those that hold text).
+// Shape results (\shprslt) and \nonshppict repeat the content of a shape for readers that don't understand it.
+var rtfSkip = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true, "pict": true,
+	"listtable": true, "listoverridetable": true, "revtbl": true, "rsidtbl": true, "filetbl": true,
+	"sp": true, "shprslt": true, "nonshppict": true,
+}
+
+// the ignorable (\*) destinations that do hold text
+var rtfKeep = map[string]bool{"fldinst": true, "annotation": true, "shpinst": true, "do": true}
+
+// the control words that stand for a single character of the document's text
+var rtfChars = map[string]string{
+	"par": "\n", "sect": "\n", "line": "\n", "page": "\n", "column": "\n", "cell": "\n", "row": "\n",
+	"tab": "\t", "emdash": "—", "endash": "–", "bullet": "•",
+	"lquote": "‘", "rquote": "’", "ldblquote": "“", "rdblquote": "”",
+}
+
+type rtfGroup struct {
+	region int  // index in fieldRegions
+	field  bool // the group is a \field, so closing it ends the field
+	first  bool // no control word or text has been read in the group yet, so the next control word is its destination
+	uc     int  // the number of fallback characters after each \u character (\uc)
+}
+
+// rtfParser walks the groups and control words of an RTF document
+type rtfParser struct {
+	b       []byte
+	i       int
+	groups  []rtfGroup
+	regions []markupRegion
+	skip    int // the number of fallback characters still to skip after a \u character
+	// the code page of the document's 8-bit text (\ansicpg), and the first byte of a double-byte character whose second byte hasn't been read yet
+	codePage uint16
+	lead     byte
+}
+
+func (p *rtfParser) group() *rtfGroup {
+	return &p.groups[len(p.groups)-1]
+}
+
+func (p *rtfParser) region() *markupRegion {
+	return &p.regions[p.group().region]
+}
+
+// text adds a character of the document's text, unless it is the fallback for a \u character
+func (p *rtfParser) text(s string) {
+	p.group().first = false
+	if p.skip > 0 {
+		p.skip--
+		return
+	}
+	p.region().text(s)
+}
+
+// text8 adds an 8-bit character of the document's text, in the document's code page. In a double-byte code page (Japanese, Chinese or Korean),
+// a lead byte is held until the character's second byte is read.
+func (p *rtfParser) text8(c byte) {
+	if p.skip > 0 {
+		p.text("")
+		return
+	}
+	if p.lead != 0 {
+		b := []byte{p.lead, c}
+		p.lead = 0
+		p.text(decodeString(b, p.codePage))
+		return
+	}
+	if leadByte(p.codePage, c) {
+		p.group().first, p.lead = false, c
+		return
+	}
+	p.text(decodeString([]byte{c}, p.codePage))
+}
+
+// leadByte reports whether a byte starts a double-byte character in a code page
+func leadByte(codePage uint16, c byte) bool {
+	switch codePage {
+	case 932: // Shift-JIS
+		return c >= 0x81 && c <= 0x9F || c >= 0xE0 && c <= 0xFC
+	case 936, 949, 950: // GBK, EUC-KR (Unified Hangul Code) and Big5
+		return c >= 0x81 && c <= 0xFE
+	}
+	return false
+}
+
+// skipGroup skips to the end of the current group (whose opening brace has been read), including any groups within it, leaving p.i at its closing brace
+func (p *rtfParser) skipGroup() {
+	for depth := 1; depth > 0 && p.i+1 < len(p.b); {
+		p.i++
+		switch p.b[p.i] {
+		case '\\':
+			if word, n, ok := p.controlWord(); ok && word == "bin" { // binary data can contain braces
+				p.i += n
+			} else if !ok {
+				p.i++ // an escaped brace or backslash
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+	}
+	p.groups = p.groups[:len(p.groups)-1]
+}
+
+// controlWord reads the control word that starts at the backslash at p.i, leaving p.i at its last byte (including the space that can end it).
+// It returns the word and its parameter. ok is false if there isn't a control word there, just a control symbol (e.g. \{ or \'e9), which controlWord doesn't read.
+func (p *rtfParser) controlWord() (word string, param int, ok bool) {
+	start := p.i + 1
+	j := start
+	for j < len(p.b) && (p.b[j] >= 'a' && p.b[j] <= 'z' || p.b[j] >= 'A' && p.b[j] <= 'Z') {
+		j++
+	}
+	if j == start {
+		return "", 0, false
+	}
+	word = string(p.b[start:j])
+	k := j
+	if k < len(p.b) && p.b[k] == '-' {
+		k++
+	}
+	for k < len(p.b) && p.b[k] >= '0' && p.b[k] <= '9' {
+		k++
+	}
+	if k > j {
+		param, _ = strconv.Atoi(string(p.b[j:k]))
+	}
+	if k < len(p.b) && p.b[k] == ' ' {
+		k++
+	}
+	p.i = k - 1
+	return word, param, true
+}
+
+// destination handles the first control word of a group, which can start a field, a field's instruction or result, another region, or a part of the file that isn't text
+func (p *rtfParser) destination(word string, ignorable bool) {
+	g := p.group()
+	switch {
+	case word == "field":
+		g.field = true
+		p.region().begin()
+	case word == "fldrslt":
+		p.region().separate()
+	case rtfSkip[word] || (ignorable && !rtfKeep[word]):
+		p.skipGroup()
+	default:
+		if r, ok := rtfRegions[word]; ok {
+			if r == 5 && g.region == 1 { // a textbox in a header or footer
+				r = 6
+			}
+			g.region = r
+		}
+	}
+}
+
+func (p *rtfParser) parse(ctx context.Context) error {
+	p.groups = []rtfGroup{{uc: 1}}
+	for ; p.i < len(p.b); p.i++ {
+		switch c := p.b[p.i]; c {
+		case '{':
+			g := *p.group()
+			g.field, g.first = false, true
+			p.groups = append(p.groups, g)
+		case '}':
+			if len(p.groups) == 1 { // the end of the document's group, or an unmatched brace
+				continue
+			}
+			if p.group().field {
+				p.region().end()
+			}
+			p.groups = p.groups[:len(p.groups)-1]
+		case '\r', '\n':
+		case '\\':
+			if p.i+1 >= len(p.b) {
+				continue
+			}
+			switch n := p.b[p.i+1]; n {
+			case '\\', '{', '}':
+				p.i++
+				p.text(string(n))
+				continue
+			case '~':
+				p.i++
+				p.text(" ")
+				continue
+			case '_':
+				p.i++
+				p.text("‑")
+				continue
+			case '-':
+				p.i++
+				continue
+			case '\'':
+				if p.i+3 < len(p.b) {
+					if v, err := strconv.ParseUint(string(p.b[p.i+2:p.i+4]), 16, 8); err == nil {
+						p.text8(byte(v))
+					}
+				}
+				p.i += 3
+				continue
+			case '\r', '\n':
+				p.i++
+				p.text("\n")
+				continue
+			case '*':
+				p.i++
+				for p.i+1 < len(p.b) && (p.b[p.i+1] == ' ' || p.b[p.i+1] == '\r' || p.b[p.i+1] == '\n') {
+					p.i++
+				}
+				if p.i+1 < len(p.b) && p.b[p.i+1] == '\\' {
+					p.i++
+					if word, _, ok := p.controlWord(); ok {
+						p.group().first = false
+						p.destination(word, true)
+					}
+				}
+				continue
+			}
+			word, param, ok := p.controlWord()
+			if !ok {
+				p.i++
+				continue
+			}
+			if p.group().first {
+				p.group().first = false
+				p.destination(word, false)
+			}
+			switch word {
+			case "bin":
+				p.i += param
+			case "uc":
+				p.group().uc = param
+			case "ansicpg":
+				p.codePage = uint16(param)
+			case "u":
+				if param < 0 {
+					param += 65536
+				}
+				p.text(string(rune(param)))
+				p.skip = p.group().uc
+			case "ftnalt":
+				p.group().region = 4
+			case "par":
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				p.text("\n")
+			default:
+				if s, ok := rtfChars[word]; ok {
+					p.text(s)
+				}
+			}
+		default:
+			p.text8(c)
+		}
+	}
+	return nil
+}
+
+// parseRTF reports the fields in an RTF document, in the same regions as a .doc.
+// RTF has no random access structure, so the whole document is read into memory (unless it is over maxRead bytes, if that is more than 0).
+func parseRTF(ctx context.Context, ra io.ReaderAt, maxRead int64, results bool) (*Report, error) {
+	r := io.NewSectionReader(ra, 0, math.MaxInt64)
+	var b []byte
+	var err error
+	if maxRead > 0 {
+		if b, err = io.ReadAll(io.LimitReader(r, maxRead+1)); err == nil && int64(len(b)) > maxRead {
+			err = fmt.Errorf("%w: the RTF document is over the limit of %d bytes", ErrTooBig, maxRead)
+		}
+	} else {
+		b, err = io.ReadAll(r)
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	p := &rtfParser{b: b, regions: make([]markupRegion, len(fieldRegions)), codePage: 1252}
+	for i := range p.regions {
+		p.regions[i].results = results
+	}
+	if err := p.parse(ctx); err != nil {
+		return nil, err
+	}
+	res := &Report{Format: FormatRTF}
+	if !res.setMarkupRegions(p.regions) {
+		return res, ErrNoFields
+	}
+	return res, nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// SlackText is a run of text found in a .doc's WordDocument stream outside anything the document uses: text left behind by fast saves (which append
+// their changes and update the piece table, rather than rewriting the file), or by deletions that Word hasn't got round to writing over.
+type SlackText struct {
+	Offset  int64  // where the run starts in the WordDocument stream
+	Length  int64  // the run's size in bytes
+	Unicode bool   // whether the run is UTF-16 text, rather than 8-bit text (in the code page of the document's text, see textCodePage)
+	Text    string // the text, with paragraph marks as newlines
+}
+
+// the fewest characters in a run of slack text, at least half of which must be letters, so that the odd printable byte among binary data isn't reported
+const minSlack = 8
+
+// Slack returns the runs of text in a .doc's WordDocument stream that aren't part of its text as given by the piece table, in the order they are found.
+// Everything else the stream holds is set aside first: the FIB, the character and paragraph formatting pages (FKPs) and, for Word 6.0 and Word 95
+// documents, the tables that follow the text. What is left is searched for runs of UTF-16 and 8-bit text.
+func Slack(ra io.ReaderAt) ([]SlackText, error) {
+	td, err := openText(ra)
+	if err != nil {
+		return nil, err
+	}
+	size := td.wordDoc.Size
+	stream := make([]byte, size)
+	if n, err := td.wordDoc.ReadAt(stream, 0); int64(n) < size {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, wrapError(err)
+	}
+	used := td.usedRanges(size)
+	codePage := textCodePage(td.fib)
+	var runs []SlackText
+	var from int64
+	for _, u := range used {
+		if u[0] > from {
+			runs = append(runs, slackRuns(stream[from:u[0]], from, codePage)...)
+		}
+		if u[1] > from {
+			from = u[1]
+		}
+	}
+	if from < size {
+		runs = append(runs, slackRuns(stream[from:], from, codePage)...)
+	}
+	return runs, nil
+}
+
+// usedRanges returns the ranges of the WordDocument stream (start inclusive, end exclusive) that the document uses, sorted by their starts
+func (td *textDoc) usedRanges(size int64) [][2]int64 {
+	var used [][2]int64
+	add := func(start, end int64) {
+		if end > size {
+			end = size
+		}
+		if start < end {
+			used = append(used, [2]int64{start, end})
+		}
+	}
+	word6 := isWord6(binary.LittleEndian.Uint16(td.fib[2:4]))
+	add(0, int64(len(td.fib)))
+	var total uint32 // the length of the whole text, for the piece that stands in for a Word 6.0 piece table
+	for _, c := range td.counts {
+		total += c
+	}
+	for _, p := range td.pieces {
+		end := p.cpEnd
+		if end-p.cpStart > total {
+			end = p.cpStart + total
+		}
+		n := int64(end - p.cpStart)
+		if !p.compressed {
+			n *= 2
+		}
+		add(int64(p.fc), int64(p.fc)+n)
+	}
+	if word6 {
+		add(0, int64(binary.LittleEndian.Uint32(td.fib[24:28]))) // up to fcMin, where the text starts
+		for i := 0; i+8 <= len(td.fcLcb); i += 8 {
+			fc, lcb := binary.LittleEndian.Uint32(td.fcLcb[i:]), binary.LittleEndian.Uint32(td.fcLcb[i+4:])
+			add(int64(fc), int64(fc)+int64(lcb))
+		}
+	}
+	for _, index := range []int{12, 13} { // the PlcBteChpx and PlcBtePapx: the FCs bounding each FKP, then its page number
+		bte, err := readTableData(td.table, td.table.Size, td.fcLcb, index)
+		if err != nil || bte == nil {
+			continue
+		}
+		pnSize := 4
+		if word6 {
+			pnSize = 2
+		}
+		n := (len(bte) - 4) / (4 + pnSize)
+		for i := 0; i < n; i++ {
+			var pn int64
+			if word6 {
+				pn = int64(binary.LittleEndian.Uint16(bte[(n+1)*4+i*2:]))
+			} else {
+				pn = int64(binary.LittleEndian.Uint32(bte[(n+1)*4+i*4:]) & 0x3FFFFF)
+			}
+			add(pn*512, pn*512+512)
+		}
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i][0] < used[j][0] })
+	return used
+}
+
+// slackRuns returns the runs of text in part of the WordDocument stream, which starts at offset base.
+// At each position a UTF-16 run is looked for first (text is stored as UTF-16 unless every character of a piece is in Windows-1252), then an 8-bit one, in codePage.
+func slackRuns(b []byte, base int64, codePage uint16) []SlackText {
+	decode := byteDecoder(codePage)
+	var runs []SlackText
+	for i := 0; i < len(b); {
+		if (base+int64(i))%2 == 0 {
+			if n := utf16Run(b[i:]); n >= minSlack {
+				u := make([]uint16, n)
+				for j := range u {
+					u[j] = binary.LittleEndian.Uint16(b[i+j*2:])
+				}
+				runs = append(runs, SlackText{base + int64(i), int64(n * 2), true, cleanText(string(utf16.Decode(u)))})
+				i += n * 2
+				continue
+			}
+		}
+		if n := byteRun(b[i:], decode); n >= minSlack {
+			runs = append(runs, SlackText{base + int64(i), int64(n), false, cleanText(decodeString(b[i:i+n], codePage))})
+			i += n
+			continue
+		}
+		i++
+	}
+	return runs
+}
+
+// slackChar reports whether a character could be part of a document's text: a printable character or a paragraph mark, tab or line break
+func slackChar(r rune) bool {
+	return r == '\r' || r == '\t' || r == '\v' || r >= 0x20 && unicode.IsPrint(r)
+}
+
+// utf16Run returns the number of characters in the run of UTF-16 text at the start of b, or 0 if it isn't long enough or has too few letters.
+// Only characters from the Latin, Greek, Cyrillic, Armenian and Hebrew blocks and general punctuation are taken as text: 8-bit text read as UTF-16 gives mostly CJK characters.
+func utf16Run(b []byte) int {
+	var n, letters int
+	for ; n*2+2 <= len(b); n++ {
+		r := rune(binary.LittleEndian.Uint16(b[n*2:]))
+		if r >= 0x0600 && (r < 0x2000 || r >= 0x20D0) || !slackChar(r) {
+			break
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if n < minSlack || letters*2 < n {
+		return 0
+	}
+	return n
+}
+
+// byteRun returns the number of bytes in the run of 8-bit text at the start of b, as decoded by decode (see byteDecoder), or 0 if it isn't long enough or has too few letters
+func byteRun(b []byte, decode func(byte) rune) int {
+	var n, letters int
+	for ; n < len(b); n++ {
+		r := decode(b[n])
+		if r == utf8.RuneError || !slackChar(r) { // the bytes that the code page leaves undefined, too
+			break
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if n < minSlack || letters*2 < n {
+		return 0
+	}
+	return n
+}
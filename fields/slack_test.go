@@ -0,0 +1,34 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import "testing"
+
+// TestSlackRunsCodePage checks that 8-bit slack text is found and decoded in the document's code page
+func TestSlackRunsCodePage(t *testing.T) {
+	b := []byte("\x00\x01\xcf\xf0\xe8\xe2\xe5\xf2, \xec\xe8\xf0\x00")
+	for _, test := range []struct {
+		codePage uint16
+		want     string
+	}{
+		{1251, "Привет, мир"},
+		{1252, "Ïðèâåò, ìèð"},
+	} {
+		runs := slackRuns(b, 0, test.codePage)
+		if len(runs) != 1 || runs[0].Text != test.want || runs[0].Offset != 2 || runs[0].Unicode {
+			t.Errorf("code page %d: got %+v, want one 8-bit run of %q at offset 2", test.codePage, runs, test.want)
+		}
+	}
+}
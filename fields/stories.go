@@ -0,0 +1,72 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"fmt"
+	"io"
+)
+
+// storyTable places the fields of a note or textbox region in its stories (see noteStories and textboxStories)
+type storyTable struct {
+	key, name     string
+	errFormat     string // the warning for a table that can't be read, given the region's name and the error
+	missingFormat string // the warning for fields that can't be placed, given their number and the region's name
+	stories       interface{ place([]Field) int }
+	err           error // why the table can't be read, if it can't
+}
+
+// readStories reads the note and textbox tables, once for all of their regions. Word 6.0 and Word 95 textboxes aren't read, as those versions
+// place drawing objects with the FDOAs of a PlcfdoaMom instead.
+func readStories(table io.ReaderAt, tableSize int64, fcLcb []byte, nFib uint16) []storyTable {
+	var tables []storyTable
+	for _, nt := range noteTables {
+		st := storyTable{key: nt.key, name: nt.name, errFormat: "the notes that the %s fields are in can't be told: %v", missingFormat: "%d %s fields aren't in the text of any note"}
+		if s, err := readNoteStories(table, tableSize, fcLcb, nt.ref, nt.txt); err != nil {
+			st.err = err
+		} else {
+			st.stories = s
+		}
+		tables = append(tables, st)
+	}
+	if isWord6(nFib) {
+		return tables
+	}
+	for _, tt := range textboxTables {
+		st := storyTable{key: tt.key, name: tt.name, errFormat: "the textboxes that the %s fields are in can't be told: %v", missingFormat: "the anchors of the textboxes that %d %s fields are in can't be found"}
+		if s, err := readTextboxStories(table, tableSize, fcLcb, tt.txt, tt.bkd, tt.spa); err != nil {
+			st.err = err
+		} else {
+			st.stories = s
+		}
+		tables = append(tables, st)
+	}
+	return tables
+}
+
+// setStories fills in the Story and Anchor of the fields in footnotes, endnotes and textboxes, noting any that can't be told in the warnings.
+// Only the regions with fields are placed, so with a Walk, each region is placed while it is held and before it is handed over.
+func (d *Report) setStories(tables []storyTable) {
+	for _, st := range tables {
+		if len(d.Occurrences[st.key]) == 0 {
+			continue
+		}
+		if st.err != nil {
+			d.Warnings = append(d.Warnings, fmt.Sprintf(st.errFormat, st.name, st.err))
+		} else if n := st.stories.place(d.Occurrences[st.key]); n > 0 {
+			d.Warnings = append(d.Warnings, fmt.Sprintf(st.missingFormat, n, st.name))
+		}
+	}
+}
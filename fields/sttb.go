@@ -0,0 +1,158 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errSttb = errors.New("malformed string table (STTB)")
+
+// readTableData reads the data located by the fc/lcb pair at index in the FibRgFcLcb from the table stream.
+// It returns nil, and no error, if the FIB doesn't have the pair or the data is empty.
+func readTableData(table io.ReaderAt, tableSize int64, fcLcb []byte, index int) ([]byte, error) {
+	if len(fcLcb) < (index+1)*8 {
+		return nil, nil
+	}
+	fc, lcb := binary.LittleEndian.Uint32(fcLcb[index*8:]), binary.LittleEndian.Uint32(fcLcb[index*8+4:])
+	if lcb == 0 {
+		return nil, nil
+	}
+	if uint64(fc)+uint64(lcb) > uint64(tableSize) {
+		return nil, errors.New("outside the table stream")
+	}
+	if err := checkSize(table, int64(lcb)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, lcb)
+	if n, err := table.ReadAt(buf, int64(fc)); n < len(buf) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readSttb reads the strings of a string table (STTB), along with the extra data (cbExtra bytes) that follows each of them, if any.
+// From Word 97 on, an STTB starts with fExtend (0xFFFF if the strings are UTF-16, each with a 16-bit length; otherwise they are 8-bit with an 8-bit length),
+// the 16-bit count of strings (cData) and cbExtra. A Word 6.0 or Word 95 STTB is just its 16-bit size in bytes (including the size itself) followed by 8-bit strings:
+// codePage is the code page of those strings (see textCodePage), or 0 for an STTB from Word 97 on.
+func readSttb(b []byte, codePage uint16) (strs []string, extra [][]byte, err error) {
+	if len(b) < 2 {
+		return nil, nil, errSttb
+	}
+	if codePage != 0 {
+		size := int(binary.LittleEndian.Uint16(b))
+		if size > len(b) {
+			return nil, nil, errSttb
+		}
+		for i := 2; i < size; {
+			l := int(b[i])
+			if i+1+l > size {
+				return strs, nil, errSttb
+			}
+			strs = append(strs, decodeString(b[i+1:i+1+l], codePage))
+			i += 1 + l
+		}
+		return strs, nil, nil
+	}
+	if len(b) < 6 {
+		return nil, nil, errSttb
+	}
+	extended := binary.LittleEndian.Uint16(b) == 0xFFFF
+	i := 0
+	if extended {
+		i = 2
+	}
+	cData, cbExtra := int(binary.LittleEndian.Uint16(b[i:])), int(binary.LittleEndian.Uint16(b[i+2:]))
+	i += 4
+	for j := 0; j < cData; j++ {
+		var s string
+		if extended {
+			if i+2 > len(b) {
+				return strs, extra, errSttb
+			}
+			l := int(binary.LittleEndian.Uint16(b[i:]))
+			i += 2
+			if i+l*2 > len(b) {
+				return strs, extra, errSttb
+			}
+			s = utf16String(b[i : i+l*2])
+			i += l * 2
+		} else {
+			if i+1 > len(b) {
+				return strs, extra, errSttb
+			}
+			l := int(b[i])
+			if i+1+l > len(b) {
+				return strs, extra, errSttb
+			}
+			s = decodeString(b[i+1:i+1+l], 1252) // from Word 97 on, 8-bit strings are always Windows-1252 (see textCodePage)
+			i += 1 + l
+		}
+		if i+cbExtra > len(b) {
+			return strs, extra, errSttb
+		}
+		strs = append(strs, s)
+		extra = append(extra, b[i:i+cbExtra])
+		i += cbExtra
+	}
+	return strs, extra, nil
+}
+
+// Associations are the strings in the document's SttbfAssoc (pair 32 of the FibRgFcLcb) that associate it with other files and people. Empty strings weren't recorded.
+type Associations struct {
+	Template       string // the path of the attached template (ibstAssocDot)
+	DataSource     string // the mail merge data source (ibstAssocDataDoc)
+	HeaderDocument string // the mail merge header document (ibstAssocHeaderDoc)
+	Author         string // ibstAssocAuthor
+	LastSavedBy    string // ibstAssocLastRevBy
+}
+
+// readAssociations reads the SttbfAssoc, whose strings are in a fixed order: ibstAssocDot is 1, ibstAssocAuthor 6, ibstAssocLastRevBy 7, ibstAssocDataDoc 8 and ibstAssocHeaderDoc 9
+// codePage is as for readSttb.
+func readAssociations(b []byte, codePage uint16) (Associations, error) {
+	strs, _, err := readSttb(b, codePage)
+	get := func(i int) string {
+		if i < len(strs) {
+			return strs[i]
+		}
+		return ""
+	}
+	return Associations{get(1), get(8), get(9), get(6), get(7)}, err
+}
+
+// SavedBy is an entry in the document's save history: who saved it, and where
+type SavedBy struct {
+	Author string
+	Path   string
+}
+
+// readSavedBy reads the SttbSavedBy (pair 71 of the FibRgFcLcb), which pairs the author and path of each of the last few saves, the most recent last.
+// Word doesn't always keep it up to date (and Office 2002 and later stopped recording it), but it is often the only trace of where a document has been.
+func readSavedBy(b []byte) ([]SavedBy, error) {
+	strs, _, err := readSttb(b, 0)
+	var saves []SavedBy
+	for i := 0; i+1 < len(strs); i += 2 {
+		saves = append(saves, SavedBy{strs[i], strs[i+1]})
+	}
+	if err == nil && len(strs)%2 != 0 {
+		err = errSttb
+	}
+	return saves, err
+}
@@ -0,0 +1,71 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"fmt"
+	"io"
+)
+
+// readTables reads the parts of the table stream, other than the field data, that the report covers: the SttbfAssoc, the mail merge settings, the protection settings, the save history, the bookmarks, the comments, the revision marks and the languages.
+// Parts that can't be read are noted in the warnings. pieces and counts (see loadPieces and ccps) can be nil, in which case the comments' text is left out.
+func (d *Report) readTables(table, doc io.ReaderAt, tableSize, docSize int64, fcLcb []byte, pieces []piece, counts []uint32) {
+	word6 := isWord6(d.NFib)
+	if b, err := readTableData(table, tableSize, fcLcb, 32); err != nil {
+		d.Warnings = append(d.Warnings, fmt.Sprintf("the associated files and authors (SttbfAssoc) can't be read: %v", err))
+	} else if b != nil {
+		var codePage uint16
+		if word6 {
+			codePage = CodePage(d.Languages.Install)
+		}
+		if d.Associations, err = readAssociations(b, codePage); err != nil {
+			d.Warnings = append(d.Warnings, fmt.Sprintf("the associated files and authors (SttbfAssoc) can't all be read: %v", err))
+		}
+	}
+	if b, err := readTableData(table, tableSize, fcLcb, 31); err == nil && len(b) > 0 { // the Dop (which readRevisions reads too, and warns about)
+		d.MailMerge.MainDocument = b[0]&0x04 != 0 // fPMHMainDoc: the document is a mail merge main document
+		d.Protection = readProtection(b, d.Flags)
+	} else {
+		d.Protection = readProtection(nil, d.Flags)
+	}
+	if word6 { // Word 6.0 and Word 95 don't keep a save history, and their bookmark and comment tables and formatting differ
+		return
+	}
+	if b, err := readTableData(table, tableSize, fcLcb, 71); err != nil {
+		d.Warnings = append(d.Warnings, fmt.Sprintf("the save history (SttbSavedBy) can't be read: %v", err))
+	} else if b != nil {
+		if d.SavedBy, err = readSavedBy(b); err != nil {
+			d.Warnings = append(d.Warnings, fmt.Sprintf("the save history (SttbSavedBy) can't all be read: %v", err))
+		}
+	}
+	if b, err := readTableData(table, tableSize, fcLcb, 127); err != nil {
+		d.Warnings = append(d.Warnings, fmt.Sprintf("the mail merge data source settings (ODSO) can't be read: %v", err))
+	} else if b != nil {
+		d.MailMerge.Connection, d.MailMerge.Query = readODSO(b)
+	}
+	var err error
+	if d.Bookmarks, err = readBookmarks(table, tableSize, fcLcb, counts); err != nil {
+		d.Warnings = append(d.Warnings, fmt.Sprintf("the bookmarks can't be read: %v", err))
+	}
+	if d.Comments, err = readComments(table, tableSize, fcLcb, doc, pieces, counts); err != nil {
+		d.Warnings = append(d.Warnings, fmt.Sprintf("the comments can't all be read: %v", err))
+	}
+	if d.Revisions, err = readRevisions(table, tableSize, fcLcb, doc, docSize, pieces); err != nil {
+		d.Warnings = append(d.Warnings, fmt.Sprintf("the revision marks can't all be read: %v", err))
+	}
+	if err = d.Languages.readLanguages(table, tableSize, fcLcb, doc, docSize, pieces); err != nil {
+		d.Warnings = append(d.Warnings, fmt.Sprintf("the languages of the text can't all be read: %v", err))
+	}
+}
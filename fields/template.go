@@ -0,0 +1,225 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Template is what a template (a document with fDot set) carries for the documents based on it, besides its own text:
+// its AutoText entries, which are kept in a glossary document of their own, and its command customisations.
+// Whether it has macros is Report.Macros.
+type Template struct {
+	AutoText       []AutoText // in the order of their names in the SttbfGlsy
+	Customizations Customizations
+}
+
+// AutoText is an AutoText entry of a template: its name, and the fields in its text, in document order (with CPs counted from the start of the glossary document's text)
+type AutoText struct {
+	Name   string
+	Fields []Field
+}
+
+// Customizations are the command customisations of a template or document (its Tcg, located by pair 24 of the FibRgFcLcb, fcCmds/lcbCmds)
+type Customizations struct {
+	Size           uint32   // the size of the Tcg in bytes, or 0 if there isn't one
+	KeyAssignments int      // the shortcut keys assigned to commands, macros, styles etc. (the PlfKme)
+	MacroCommands  int      // the menu and toolbar commands that run macros (the PlfMcd)
+	MacroNames     []string // the names of the macros those commands run (the MacroNames)
+	Toolbars       bool     // the toolbars or menus are customised (there is a CTBWRAPPER)
+}
+
+const (
+	fcCmds      = 24 // the pairs of the FibRgFcLcb that locate the Tcg,
+	fcSttbfGlsy = 9  // the names of the AutoText entries
+	fcPlcfGlsy  = 10 // and the CPs of their starts
+	fcPlcfFld   = 16 // and the field data of the main document (which, in a glossary document, holds the AutoText)
+)
+
+var errTcg = errors.New("malformed command customisations (Tcg)")
+
+// readTemplate reads the command customisations of a Word 97 or later template and, if pnNext in its FibBase locates one, the AutoText entries of its glossary document.
+// The glossary document is in the same WordDocument stream, at pnNext × 512, with a FIB of its own whose fc/lcb pairs are in the same table stream.
+// Parts that can't be read are noted in the warnings.
+func (d *Report) readTemplate(doc io.ReaderAt, docSize int64, table io.ReaderAt, tableSize int64, fib, fcLcb []byte) {
+	d.Template = &Template{}
+	if b, err := readTableData(table, tableSize, fcLcb, fcCmds); err != nil {
+		d.Warnings = append(d.Warnings, fmt.Sprintf("the command customisations (Tcg) can't be read: %v", err))
+	} else if b != nil {
+		if d.Template.Customizations, err = readCustomizations(b); err != nil {
+			d.Warnings = append(d.Warnings, fmt.Sprintf("the command customisations (Tcg) can't all be read: %v", err))
+		}
+	}
+	pnNext := int64(binary.LittleEndian.Uint16(fib[8:10]))
+	if pnNext == 0 { // no AutoText
+		return
+	}
+	var err error
+	if d.Template.AutoText, err = readGlossary(doc, docSize, table, tableSize, pnNext*512); err != nil {
+		d.Warnings = append(d.Warnings, fmt.Sprintf("the AutoText entries can't all be read: %v", err))
+	}
+}
+
+// readGlossary reads the AutoText entries of the glossary document whose FIB is at off in the WordDocument stream: their names from its SttbfGlsy,
+// and the fields in each from its field data, split between the entries by the CPs of their starts in its PlcfGlsy
+func readGlossary(doc io.ReaderAt, docSize int64, table io.ReaderAt, tableSize int64, off int64) ([]AutoText, error) {
+	if off >= docSize {
+		return nil, fmt.Errorf("the glossary document's FIB (at offset %d) is beyond the end of the WordDocument stream (%d bytes)", off, docSize)
+	}
+	fib, fcLcb, err := readFIB(io.NewSectionReader(doc, off, docSize-off))
+	if err != nil {
+		return nil, fmt.Errorf("the glossary document's FIB (at offset %d) can't be read: %w", off, err)
+	}
+	if nFib := binary.LittleEndian.Uint16(fib[2:4]); isWord6(nFib) || !decodeFlags(fib).Glsy {
+		return nil, fmt.Errorf("the FIB at offset %d isn't a glossary document's", off)
+	}
+	b, err := readTableData(table, tableSize, fcLcb, fcSttbfGlsy)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	names, _, err := readSttb(b, 0)
+	entries := make([]AutoText, len(names))
+	for i, n := range names {
+		entries[i].Name = n
+	}
+	if err != nil {
+		return entries, err
+	}
+	plc, err := readTableData(table, tableSize, fcLcb, fcPlcfGlsy)
+	if err != nil {
+		return entries, fmt.Errorf("the starts of the entries (PlcfGlsy) can't be read: %w", err)
+	}
+	b, err = readTableData(table, tableSize, fcLcb, fcPlcfFld)
+	if err != nil {
+		return entries, fmt.Errorf("the field data can't be read: %w", err)
+	}
+	if len(b) < 4 || (len(b)-4)%6 != 0 {
+		return entries, nil // no fields, or field data that isn't a PlcFld (see ParseWithOptions)
+	}
+	fields, _, _ := processField(b, uint32(len(b)))
+	if pieces, err := loadPieces(table, tableSize, fib, fcLcb); err == nil {
+		readInstructions(doc, pieces, 0, fields, false) // the fields are still reported, by type, if their instructions can't be read
+	}
+	for _, f := range fields {
+		for i := range entries {
+			if (i+1)*4+4 > len(plc) {
+				break
+			}
+			start, end := binary.LittleEndian.Uint32(plc[i*4:]), binary.LittleEndian.Uint32(plc[(i+1)*4:])
+			if f.CP >= start && f.CP < end {
+				entries[i].Fields = append(entries[i].Fields, f)
+				break
+			}
+		}
+	}
+	return entries, nil
+}
+
+// readCustomizations reads a Tcg: nTcgVer (0xFF), then a series of parts, each starting with its id, until the 0x40 terminator.
+// Only the counts of the key assignments and macro commands and the names of the macros are kept. The toolbars (a CTBWRAPPER) aren't read,
+// so parts after them are left out, as are those after a part with an id that isn't known.
+func readCustomizations(b []byte) (Customizations, error) {
+	c := Customizations{Size: uint32(len(b))}
+	if len(b) < 2 || b[0] != 0xFF {
+		return c, errTcg
+	}
+	// plf returns the count (iMac) of a Plf of size-byte items at i, and the offset that follows it
+	plf := func(i, size int) (int, int, error) {
+		if i+4 > len(b) {
+			return 0, 0, errTcg
+		}
+		n := int(int32(binary.LittleEndian.Uint32(b[i:])))
+		if n < 0 || n > (len(b)-i-4)/size {
+			return 0, 0, errTcg
+		}
+		return n, i + 4 + n*size, nil
+	}
+	var err error
+	for i := 1; i < len(b); {
+		id := b[i]
+		i++
+		switch id {
+		case 0x40: // the end
+			return c, nil
+		case 0x01: // PlfMcd: the Mcds (24 bytes each)
+			c.MacroCommands, i, err = plf(i, 24)
+		case 0x02: // PlfAcd: the commands added to menus and toolbars (4 bytes each)
+			_, i, err = plf(i, 4)
+		case 0x03: // PlfKme: the key assignments (14 bytes each)
+			c.KeyAssignments, i, err = plf(i, 14)
+		case 0x04: // PlfKme of the key assignments that have been removed
+			_, i, err = plf(i, 14)
+		case 0x10: // TcgSttbf: an extended STTB, with 2 bytes of extra data for each string
+			i, err = skipSttb(b, i)
+		case 0x11: // MacroNames: the macros, each with its index and name
+			c.MacroNames, i, err = readMacroNames(b, i)
+		case 0x12: // CTBWRAPPER: the toolbars
+			c.Toolbars = true
+			return c, nil
+		default:
+			return c, nil
+		}
+		if err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// skipSttb returns the offset that follows the extended STTB (fExtend, cData and cbExtra, then each string with its 16-bit length and extra data) at i
+func skipSttb(b []byte, i int) (int, error) {
+	if i+6 > len(b) || binary.LittleEndian.Uint16(b[i:]) != 0xFFFF {
+		return 0, errTcg
+	}
+	cData, cbExtra := int(binary.LittleEndian.Uint16(b[i+2:])), int(binary.LittleEndian.Uint16(b[i+4:]))
+	i += 6
+	for j := 0; j < cData; j++ {
+		if i+2 > len(b) {
+			return 0, errTcg
+		}
+		i += 2 + int(binary.LittleEndian.Uint16(b[i:]))*2 + cbExtra
+		if i > len(b) {
+			return 0, errTcg
+		}
+	}
+	return i, nil
+}
+
+// readMacroNames reads the MacroNames at i: the 16-bit count of names, then each name's 16-bit index (ibst) and the name as an Xstz
+// (its 16-bit length, the UTF-16 characters, and a 16-bit terminating zero). It returns the names and the offset that follows.
+func readMacroNames(b []byte, i int) ([]string, int, error) {
+	if i+2 > len(b) {
+		return nil, 0, errTcg
+	}
+	n := int(binary.LittleEndian.Uint16(b[i:]))
+	i += 2
+	names := []string{}
+	for j := 0; j < n; j++ {
+		if i+4 > len(b) {
+			return names, 0, errTcg
+		}
+		l := int(binary.LittleEndian.Uint16(b[i+2:]))
+		i += 4
+		if i+l*2+2 > len(b) {
+			return names, 0, errTcg
+		}
+		names = append(names, utf16String(b[i:i+l*2]))
+		i += l*2 + 2
+	}
+	return names, i, nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// ErrTextFormat is returned by Text for documents that aren't .docs
+var ErrTextFormat error = errors.New("text can only be extracted from .doc files")
+
+// the parts of the document's text, in the order they are stored (see ccps)
+var textParts = []string{"Document body", "Footnotes", "Headers/footers", "Macros", "Comments", "Endnotes", "Textboxes", "Header/footer textboxes"}
+
+// Text writes the plain text of a .doc to w: the main document, then (after a blank line and a line naming the part) each of the other parts of the text that isn't empty.
+// Fields are shown as Word shows them, by their results; their instructions are left out. Paragraph marks, line and page breaks and the ends of table cells become newlines,
+// and other control characters (e.g. the marks for footnotes, comments and pictures) are dropped.
+// The text is found through the piece table, which maps character positions (CPs) to the file positions (FCs) of runs of text that are either 8-bit (Windows-1252) or UTF-16.
+func Text(ra io.ReaderAt, w io.Writer) error {
+	td, err := openText(ra)
+	if err != nil {
+		return err
+	}
+	wordDoc, pieces, counts := td.wordDoc, td.pieces, td.counts
+	bw := bufio.NewWriter(w)
+	var cp uint32
+	for i, n := range counts {
+		if n > 0 && i > 0 {
+			fmt.Fprintf(bw, "\n\n%s:\n", textParts[i])
+		}
+		if err := writeText(bw, wordDoc, pieces, cp, cp+n); err != nil {
+			bw.Flush()
+			return wrapError(err)
+		}
+		cp += n
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// textDoc is a .doc opened for reading its text, by Text and Slack
+type textDoc struct {
+	wordDoc, table *mscfb.File // the table stream is the WordDocument stream for Word 6.0 and Word 95 documents
+	fib, fcLcb     []byte
+	pieces         []piece
+	counts         []uint32 // the lengths of the parts of the text (see ccps)
+}
+
+// openText opens a .doc and reads its piece table, returning an error (wrapped, as Text returns it) for documents whose text can't be read
+func openText(ra io.ReaderAt) (*textDoc, error) {
+	switch sniff(ra) {
+	case FormatOOXML, FormatRTF:
+		return nil, wrapError(ErrTextFormat)
+	case "":
+		return nil, wrapError(ErrNotWord)
+	}
+	doc, err := mscfb.New(ra)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	td := &textDoc{wordDoc: findEntry(doc, "WordDocument")}
+	if td.wordDoc == nil {
+		return nil, wrapError(ErrNoWordDocument)
+	}
+	if td.fib, td.fcLcb, err = readFIB(td.wordDoc); err != nil {
+		return nil, wrapError(err)
+	}
+	flags := decodeFlags(td.fib)
+	if flags.Encrypted {
+		return nil, wrapError(ErrEncrypted)
+	}
+	td.table = td.wordDoc
+	if !isWord6(binary.LittleEndian.Uint16(td.fib[2:4])) {
+		name := "0Table"
+		if flags.WhichTblStm {
+			name = "1Table"
+		}
+		if td.table = findEntry(doc, name); td.table == nil {
+			return nil, wrapError(ErrTable)
+		}
+	}
+	if td.pieces, err = loadPieces(td.table, td.table.Size, td.fib, td.fcLcb); err != nil {
+		return nil, wrapError(err)
+	}
+	if td.counts = ccps(td.fib); td.counts == nil {
+		return nil, wrapError(errors.New("the FIB doesn't have the lengths of the parts of the document"))
+	}
+	return td, nil
+}
+
+// writeText writes the text between two CPs (start inclusive, end exclusive), as Text describes
+func writeText(w *bufio.Writer, doc io.ReaderAt, pieces []piece, start, end uint32) error {
+	var fields []bool // for each field the text is in, innermost last, whether the text is in its instructions
+	var last rune     // the last character written, so that a part doesn't end with a trailing newline
+	var pending int   // newlines held back until there is more text
+	emit := func(r rune) {
+		for _, f := range fields {
+			if f {
+				return
+			}
+		}
+		switch r {
+		case 0x0D, 0x0B, 0x0C, 0x07: // paragraph mark, line break, page or section break, end of a table cell or row
+			pending++
+			return
+		case 0x1E: // non-breaking hyphen
+			r = '-'
+		case 0xA0:
+			r = ' '
+		}
+		if r < 0x20 && r != '\t' {
+			return
+		}
+		for ; pending > 0; pending-- {
+			if last != 0 {
+				w.WriteByte('\n')
+			}
+		}
+		w.WriteRune(r)
+		last = r
+	}
+	const chunk = 1 << 15
+	bp := getBuf(chunk)
+	defer putBuf(bp)
+	buf := *bp
+	for _, p := range pieces {
+		if p.cpEnd <= start || p.cpStart >= end {
+			continue
+		}
+		from, to := start, end
+		if from < p.cpStart {
+			from = p.cpStart
+		}
+		if to > p.cpEnd {
+			to = p.cpEnd
+		}
+		size := uint32(2)
+		if p.compressed {
+			size = 1
+		}
+		for from < to {
+			n := to - from
+			if n > chunk/size {
+				n = chunk / size
+			}
+			b := buf[:n*size]
+			if m, err := doc.ReadAt(b, int64(p.fc)+int64(from-p.cpStart)*int64(size)); m < len(b) {
+				if err == nil || err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+			var runes []rune
+			if p.compressed {
+				runes = []rune(decodeString(b, p.codePage))
+			} else {
+				u := make([]uint16, len(b)/2)
+				for i := range u {
+					u[i] = binary.LittleEndian.Uint16(b[i*2:])
+				}
+				runes = utf16.Decode(u)
+			}
+			for _, r := range runes {
+				switch r {
+				case 0x13:
+					fields = append(fields, true)
+				case 0x14:
+					if len(fields) > 0 {
+						fields[len(fields)-1] = false
+					}
+				case 0x15:
+					if len(fields) > 0 {
+						fields = fields[:len(fields)-1]
+					}
+				default:
+					emit(r)
+				}
+			}
+			from += n
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	errTextboxes   = errors.New("malformed textbox tables")
+	errNoTextboxes = errors.New("the document has no table of textboxes")
+)
+
+// the textbox regions, with the fc/lcb pairs of the tables that place their text: the PlcftxbxTxt (or PlcfHdrtxbxTxt), which has a CP for the start of each textbox's
+// story (its text, which can flow through a chain of linked textboxes) followed by an FTXBXS (22 bytes) for each; the PlcfTxbxBkd (or PlcfTxbxHdrBkd), which breaks the
+// text at each textbox of a chain, with a BKD (6 bytes) for each part giving the index of its story (itxbxs); and the PlcSpaMom (or PlcSpaHdr), which has the CP of the
+// anchor of each shape in the body (or header/footer) followed by an FSPA (26 bytes) starting with the shape's identifier (spid), which the FTXBXS's lid matches
+var textboxTables = []struct {
+	name, key     string
+	txt, bkd, spa int
+}{
+	{"Textbox", "textbox", 56, 75, 40},
+	{"Header/footer textbox", "headertextbox", 58, 76, 41},
+}
+
+// textboxStories are the tables of a textbox region that place its fields: the CP of the start of each story and the lid of its first textbox,
+// the parts of the stories (from the PlcfTxbxBkd, if it could be read) and the CPs of the shapes' anchors by their spid
+type textboxStories struct {
+	starts, lids, breaks []uint32
+	parts                []int
+	anchors              map[uint32]uint32
+}
+
+// readTextboxStories reads the textbox tables at the given pairs of the FibRgFcLcb
+func readTextboxStories(table io.ReaderAt, tableSize int64, fcLcb []byte, txtIndex, bkdIndex, spaIndex int) (*textboxStories, error) {
+	txt, err := readTableData(table, tableSize, fcLcb, txtIndex)
+	if err != nil {
+		return nil, err
+	}
+	if txt == nil {
+		return nil, errNoTextboxes
+	}
+	n := (len(txt) - 4) / 26
+	if n < 1 {
+		return nil, errTextboxes
+	}
+	s := &textboxStories{starts: plcCPs(txt, n), lids: make([]uint32, n), anchors: make(map[uint32]uint32)}
+	for i := range s.lids {
+		s.lids[i] = binary.LittleEndian.Uint32(txt[(n+1)*4+i*22+14:]) // after cTxbx/iNextReuse, cReusable, fReusable and 4 reserved bytes
+	}
+	if bkd, err := readTableData(table, tableSize, fcLcb, bkdIndex); err == nil && len(bkd) >= 14 { // it can be left out of documents without linked textboxes
+		m := (len(bkd) - 4) / 10
+		s.breaks, s.parts = plcCPs(bkd, m), make([]int, m)
+		for i := range s.parts {
+			s.parts[i] = int(int16(binary.LittleEndian.Uint16(bkd[(m+1)*4+i*6:])))
+		}
+	}
+	spa, err := readTableData(table, tableSize, fcLcb, spaIndex)
+	if err != nil {
+		return nil, err
+	}
+	if len(spa) >= 30 {
+		m := (len(spa) - 4) / 30
+		for i := 0; i < m; i++ {
+			s.anchors[binary.LittleEndian.Uint32(spa[(m+1)*4+i*26:])] = binary.LittleEndian.Uint32(spa[i*4:])
+		}
+	}
+	return s, nil
+}
+
+// place sets the Story of each field, and its Anchor if the textbox's shape can be found. It returns the number of fields whose anchor wasn't found.
+func (s *textboxStories) place(fields []Field) int {
+	var missing int
+	for i := range fields {
+		n := -1
+		if p := plcIndex(s.breaks, fields[i].CP); p >= 0 && s.parts[p] >= 0 && s.parts[p] < len(s.lids) {
+			n = s.parts[p]
+		} else {
+			n = plcIndex(s.starts, fields[i].CP)
+		}
+		if n < 0 {
+			missing++
+			continue
+		}
+		fields[i].Story = n + 1
+		if a, ok := s.anchors[s.lids[n]]; ok {
+			fields[i].Anchor, fields[i].Anchored = a, true
+		} else {
+			missing++
+		}
+	}
+	return missing
+}
+
+// plcCPs returns the n+1 CPs at the start of a PLC with n data elements
+func plcCPs(b []byte, n int) []uint32 {
+	cps := make([]uint32, n+1)
+	for i := range cps {
+		cps[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	return cps
+}
+
+// plcIndex returns the index of the data element of a PLC whose range of CPs includes cp, or -1 if none does
+func plcIndex(cps []uint32, cp uint32) int {
+	for i := 0; i+1 < len(cps); i++ {
+		if cp >= cps[i] && cp < cps[i+1] {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,70 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fields
+
+import (
+	"context"
+	"io"
+)
+
+// A Walker has the callbacks that Walk makes as it reads a document. Either can be nil.
+type Walker struct {
+	// Region is called for each region that has field data, with all its fields (in Occurrences), once the region has been read
+	Region func(r Region) error
+	// Field is called for each field, in document order within its region, with the key of the region (body, header, footnote etc.); it follows the region's call to Region
+	Field func(region string, f Field) error
+}
+
+// Walk parses a document like ParseWithOptions, but hands the fields of each region to w as soon as that region has been read, rather than keeping them:
+// the Report it returns has the document's properties, flags, structure, warnings etc. and the names of the fields in each region, but no Occurrences,
+// so the parts of the report that are worked out from them (Hyperlinks, FormFields, MailMerge's fields and the pictures' INCLUDEPICTURE fields) are empty too.
+// A .doc's regions are read one at a time, so only one region's fields are held in memory at once; an OOXML package or RTF document is read whole first.
+// If a callback returns an error, Walk stops and returns it, with a nil report.
+func Walk(ctx context.Context, ra io.ReaderAt, opts Options, w Walker) (*Report, error) {
+	opts.walker = &w
+	res, err := ParseWithOptions(ctx, ra, opts)
+	if res == nil || res.Format == FormatDOC {
+		return res, err
+	}
+	for i, names := range res.regionFields() { // the markup formats are parsed whole, so walk the regions now
+		if *names == nil {
+			continue
+		}
+		if err := res.walkRegion(i, &w); err != nil {
+			return nil, err
+		}
+	}
+	return res, err
+}
+
+// walkRegion hands the fields of the region at index i of fieldRegions to a Walker, then drops them from the report
+func (d *Report) walkRegion(i int, w *Walker) error {
+	key := fieldRegions[i].key
+	r := Region{fieldRegions[i].name, key, *d.regionFields()[i], d.Occurrences[key]}
+	if w.Region != nil {
+		if err := w.Region(r); err != nil {
+			return err
+		}
+	}
+	if w.Field != nil {
+		for _, f := range r.Occurrences {
+			if err := w.Field(key, f); err != nil {
+				return err
+			}
+		}
+	}
+	delete(d.Occurrences, key)
+	return nil
+}
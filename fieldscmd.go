@@ -0,0 +1,63 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// jsonFieldType describes a field type from the registry (see fields.FieldTypes), in `fields list -json` and in the types member of -json output
+type jsonFieldType struct {
+	Flt     int    `json:"flt"`
+	Name    string `json:"name,omitempty"` // only in `fields list`: in -json output, types is keyed by name
+	Keyword string `json:"keyword,omitempty"`
+	Spec    string `json:"spec"`
+	Class   string `json:"class"` // static, volatile, external or interactive
+}
+
+func newJSONFieldType(t fields.FieldType) jsonFieldType {
+	return jsonFieldType{int(t.Code), t.Name, t.Keyword, t.Spec, t.Class.String()}
+}
+
+// fieldsListCommand runs `doctool fields list`, which lists the field types doctool knows: their codes (flt), names, keywords, behaviour classes and where they are specified.
+// With -json, each type is written as a JSON object on its own line.
+func fieldsListCommand() error {
+	if *jsonOut {
+		enc := json.NewEncoder(out)
+		for _, t := range fields.FieldTypes() {
+			if err := enc.Encode(newJSONFieldType(t)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	writeFieldTypes(out)
+	return nil
+}
+
+// writeFieldTypes writes the registry of field types as a table
+func writeFieldTypes(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Code\tName\tKeyword\tClass\tSpecified in")
+	for _, t := range fields.FieldTypes() {
+		fmt.Fprintf(tw, "0x%02X\t%s\t%s\t%s\t%s\n", t.Code, t.Name, t.Keyword, t.Class, t.Spec)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,59 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"math"
+)
+
+// fixity is the size and checksum of a file, so that doctool's reports can be joined against fixity manifests (file names alone aren't stable identifiers)
+type fixity struct {
+	Size     int64
+	Checksum string // in hex, with the -hash algorithm
+}
+
+// hashes are the checksum algorithms that -hash can choose, by name
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// wantFixity reports whether the output has a place for each file's size and checksum: the structured outputs and -template, but not the text report
+func wantFixity() bool {
+	return *jsonOut || *xmlOut || *csvOut || *sqliteOut != "" || tmpl != nil
+}
+
+// checksum finds the size and checksum of an input, reading it from start to end while it is open for parsing, or returns nil if -hash is none
+func checksum(ra io.ReaderAt) (*fixity, error) {
+	newHash := hashes[*hashAlg]
+	if newHash == nil {
+		return nil, nil
+	}
+	h := newHash()
+	n, err := io.Copy(h, io.NewSectionReader(ra, 0, math.MaxInt64))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &fixity{n, hex.EncodeToString(h.Sum(nil))}, nil
+}
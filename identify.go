@@ -0,0 +1,82 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// wordPUIDs are the PRONOM formats that doctool reads; with -sf, files identified as anything else are skipped
+var wordPUIDs = map[string]bool{
+	"fmt/39":   true, // Microsoft Word Document 6.0/95
+	"fmt/40":   true, // Microsoft Word Document 97-2003
+	"fmt/609":  true, // Microsoft Word (Generic) 6.0-2003
+	"fmt/754":  true, // Microsoft Word Document (Password Protected) 97-2003
+	"x-fmt/45": true, // Microsoft Word Document Template 97-2003
+	"fmt/412":  true, // Microsoft Word for Windows 2007 onwards
+	"fmt/523":  true, // Microsoft Word Macro-Enabled Document 2007 onwards
+	"fmt/597":  true, // Microsoft Word Template 2007 onwards
+	"fmt/599":  true, // Microsoft Word Macro-Enabled Template 2007 onwards
+	"fmt/494":  true, // Microsoft Office Encrypted Document (an encrypted .docx may be identified as this)
+	"fmt/45":   true, // Rich Text Format 1.0-1.4
+	"fmt/50":   true, // Rich Text Format 1.5-1.6
+	"fmt/52":   true, // Rich Text Format 1.7
+	"fmt/53":   true, // Rich Text Format 1.8
+	"fmt/355":  true, // Rich Text Format 1.9
+}
+
+// sf identifies the format of each input when -sf gives a siegfried signature file
+var sf *siegfried.Siegfried
+
+// unsupportedError is returned for a file that siegfried identifies as a format doctool doesn't read, e.g. a spreadsheet
+type unsupportedError string
+
+func (e unsupportedError) Error() string { return "unsupported format: " + string(e) }
+
+// Is makes an unsupportedError count as fields.ErrNotWord, so the file's status is notword
+func (e unsupportedError) Is(target error) bool { return target == fields.ErrNotWord }
+
+// identify checks the PRONOM identification of an input, returning an unsupportedError if it isn't a word doc.
+// A file siegfried can't identify is passed on to be parsed, as a damaged document may not match any signature: doctool's own check of its signature still rejects it if it isn't one.
+func identify(name string, ra io.ReaderAt) error {
+	if name == "-" {
+		name = ""
+	}
+	ids, err := sf.Identify(io.NewSectionReader(ra, 0, inputSize(ra)), name, "")
+	if err != nil {
+		return wrapError(err)
+	}
+	if len(ids) == 0 || !ids[0].Known() || wordPUIDs[ids[0].String()] {
+		return nil
+	}
+	return unsupportedError(ids[0].String())
+}
+
+// inputSize is the size of an input opened by openInput
+func inputSize(ra io.ReaderAt) int64 {
+	switch r := ra.(type) {
+	case interface{ Size() int64 }: // a bytes.Reader
+		return r.Size()
+	case *os.File:
+		if info, err := r.Stat(); err == nil {
+			return info.Size()
+		}
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// stdinMemory is how much of a document read from stdin is kept in memory; a bigger one is spooled to a temporary file instead
+const stdinMemory = 64 << 20
+
+// the document read from stdin, which is read once (the first time "-" is opened) and shared by every use of "-"
+var (
+	stdinOnce sync.Once
+	stdinDoc  io.ReaderAt
+	stdinErr  error
+	stdinTemp string // the temporary file stdin was spooled to, if any, removed by removeTemp
+)
+
+// readStdin buffers stdin so that it can be read at random (os.Stdin is an io.ReaderAt, but ReadAt fails on a pipe).
+// Up to stdinMemory bytes are read into memory; if there is more, what has been read and the rest of stdin are copied to a temporary file.
+func readStdin() (io.ReaderAt, error) {
+	stdinOnce.Do(func() {
+		buf, err := io.ReadAll(io.LimitReader(os.Stdin, stdinMemory+1))
+		if err != nil {
+			stdinErr = wrapError(err)
+			return
+		}
+		if len(buf) <= stdinMemory {
+			stdinDoc = bytes.NewReader(buf)
+			return
+		}
+		f, err := os.CreateTemp("", "doctool-stdin-*")
+		if err != nil {
+			stdinErr = wrapError(err)
+			return
+		}
+		defer func() {
+			if stdinDoc == nil { // the copy failed, so the file isn't needed: left open, it would also outlive removeTemp
+				f.Close()
+				os.Remove(f.Name())
+			}
+		}()
+		if _, err = io.Copy(f, io.MultiReader(bytes.NewReader(buf), os.Stdin)); err != nil {
+			stdinErr = wrapError(err)
+			return
+		}
+		stdinTemp, stdinDoc = f.Name(), f
+	})
+	return stdinDoc, stdinErr
+}
+
+// removeTemp removes the temporary file stdin was spooled to, if there is one
+func removeTemp() {
+	if stdinTemp == "" {
+		return
+	}
+	if f, ok := stdinDoc.(*os.File); ok {
+		f.Close()
+	}
+	os.Remove(stdinTemp)
+}
+
+// openInput opens a named file, or the document read from stdin if the name is "-", for random access.
+// The returned function closes the file (it does nothing for stdin, which is shared).
+func openInput(in string) (io.ReaderAt, func(), error) {
+	if in == "-" {
+		ra, err := readStdin()
+		return ra, func() {}, err
+	}
+	file, err := os.Open(in)
+	if err != nil {
+		return nil, nil, wrapError(err)
+	}
+	return file, func() { file.Close() }, nil
+}
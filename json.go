@@ -0,0 +1,581 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// jsonResult is the object written for each file in -json mode. Fields is keyed by region (body, header, footnote etc.) and has every region, with an empty list for regions without fields.
+type jsonResult struct {
+	File         string                   `json:"file"`
+	Size         int64                    `json:"size,omitempty"`     // the file's size in bytes, and
+	Checksum     map[string]string        `json:"checksum,omitempty"` // its checksum in hex, keyed by algorithm (see -hash)
+	Format       string                   `json:"format,omitempty"`   // doc, ooxml or rtf
+	Table        string                   `json:"table,omitempty"`
+	Encryption   string                   `json:"encryption,omitempty"`   // the method, if the document is encrypted
+	Version      string                   `json:"version,omitempty"`      // with -word-version
+	Template     *bool                    `json:"template,omitempty"`     // with -word-version
+	Unreferenced string                   `json:"unreferenced,omitempty"` // the other table stream, if the document has both
+	Macros       bool                     `json:"macros"`
+	Sizes        map[string]uint32        `json:"sizes,omitempty"`
+	TotalSize    uint64                   `json:"totalsize"`
+	Fields       map[string][]string      `json:"fields,omitempty"`
+	Types        map[string]jsonFieldType `json:"types,omitempty"`        // the registry entry of each type of field found (see `doctool fields list`), by name
+	Positions    map[string][]uint32      `json:"positions,omitempty"`    // with -positions: the starting CP of each field, in the same order as Fields
+	Ends         map[string][]uint32      `json:"ends,omitempty"`         // with -positions: the CP of each field's end character (0 if it has none)
+	Stories      map[string][]int         `json:"stories,omitempty"`      // with -positions, for the textbox, footnote and endnote regions of a .doc: the textbox or note each field is in (0 if it can't be told)
+	Anchors      map[string][]*uint32     `json:"anchors,omitempty"`      // with -positions, for the same regions: the CP of the anchor of each field's textbox, or its note's reference mark (null if it can't be found)
+	Instructions map[string][]string      `json:"instructions,omitempty"` // with -instructions: the instruction text of each field, in the same order as Fields
+	Results      map[string][]string      `json:"results,omitempty"`      // with -results: the result text of each field, in the same order as Fields
+	Targets      map[string][]string      `json:"targets,omitempty"`      // with -external: the path or URL each field refers to (empty if it can't be found)
+	Classes      map[string][]string      `json:"classes,omitempty"`      // with -volatile: static, volatile, external or interactive for each field, in the same order as Fields
+	Structure    map[string]jsonStructure `json:"structure,omitempty"`
+	Properties   *jsonProperties          `json:"properties,omitempty"`   // with -meta
+	Custom       map[string]string        `json:"custom,omitempty"`       // with -meta: the custom properties, by name
+	Associations *jsonAssociations        `json:"associations,omitempty"` // with -assoc
+	SavedBy      []jsonSavedBy            `json:"savedby,omitempty"`      // with -savedby
+	Bookmarks    []jsonBookmark           `json:"bookmarks,omitempty"`    // with -bookmarks
+	CrossRefs    []jsonCrossRef           `json:"crossrefs,omitempty"`    // with -crossrefs
+	Comments     []jsonComment            `json:"comments,omitempty"`     // with -comments
+	Revisions    *jsonRevisions           `json:"revisions,omitempty"`    // with -revisions
+	FastSave     *jsonFastSave            `json:"fastsave,omitempty"`     // with -fastsave, for a .doc
+	Languages    *jsonLanguages           `json:"languages,omitempty"`    // with -lang, for a .doc
+	Objects      []jsonObject             `json:"objects,omitempty"`      // with -objects
+	Pictures     []jsonPicture            `json:"pictures,omitempty"`     // with -pictures, for a .doc
+	Protection   *jsonProtection          `json:"protection,omitempty"`   // with -protection, for a .doc
+	Controls     *jsonControls            `json:"controls,omitempty"`     // with -controls, for a .doc
+	Layout       []jsonLayout             `json:"layout,omitempty"`       // with -layoutrisk
+	Dot          *jsonTemplate            `json:"dot,omitempty"`          // with -dot, for a template from Word 97 on
+	MailMerge    *jsonMailMerge           `json:"mailmerge,omitempty"`    // with -mailmerge
+	Hyperlinks   []jsonHyperlink          `json:"hyperlinks,omitempty"`   // with -hyperlinks
+	Findings     map[string][]jsonFinding `json:"findings,omitempty"`     // with -plugin: the findings of each analyzer, by name
+	Warnings     []string                 `json:"warnings,omitempty"`
+	Triage       *jsonTriage              `json:"triage,omitempty"`       // with -triage
+	Policy       *jsonPolicy              `json:"policy,omitempty"`       // with -policy
+	Preservation *jsonPreservation        `json:"preservation,omitempty"` // with -volatile
+	Status       string                   `json:"status"`                 // ok, nofields, encrypted, notword, timeout or error
+	Error        string                   `json:"error,omitempty"`
+}
+
+type jsonTriage struct {
+	Risk       string   `json:"risk"` // none, medium or high
+	Indicators []string `json:"indicators"`
+}
+
+type jsonPolicy struct {
+	Verdict string           `json:"verdict"` // pass, warn or fail
+	Rules   []jsonPolicyRule `json:"rules"`   // the rules the file matched
+}
+
+type jsonPolicyRule struct {
+	Name    string `json:"name"`
+	Verdict string `json:"verdict"`
+}
+
+type jsonPreservation struct {
+	Score       int `json:"score"`
+	Static      int `json:"static"`
+	Volatile    int `json:"volatile"`
+	External    int `json:"external"`
+	Interactive int `json:"interactive"`
+}
+
+type jsonProperties struct {
+	Title       string `json:"title,omitempty"`
+	Subject     string `json:"subject,omitempty"`
+	Author      string `json:"author,omitempty"`
+	LastSavedBy string `json:"lastsavedby,omitempty"`
+	Created     string `json:"created,omitempty"`
+	Modified    string `json:"modified,omitempty"`
+	Application string `json:"application,omitempty"`
+	Template    string `json:"template,omitempty"`
+	Company     string `json:"company,omitempty"`
+}
+
+type jsonAssociations struct {
+	Template       string `json:"template,omitempty"`
+	DataSource     string `json:"datasource,omitempty"`
+	HeaderDocument string `json:"headerdoc,omitempty"`
+	Author         string `json:"author,omitempty"`
+	LastSavedBy    string `json:"lastsavedby,omitempty"`
+}
+
+type jsonSavedBy struct {
+	Author string `json:"author"`
+	Path   string `json:"path"`
+}
+
+type jsonBookmark struct {
+	Name   string `json:"name"`
+	Region string `json:"region,omitempty"`
+	Start  uint32 `json:"start"`
+	End    uint32 `json:"end"`
+}
+
+type jsonCrossRef struct {
+	Region   string        `json:"region"`
+	CP       uint32        `json:"cp"`
+	Type     string        `json:"type"`
+	Bookmark string        `json:"bookmark"`
+	Found    *bool         `json:"found"`            // null if the bookmarks weren't read, so the target wasn't checked
+	Target   *jsonBookmark `json:"target,omitempty"` // if found
+}
+
+type jsonComment struct {
+	Author   string `json:"author"`
+	Initials string `json:"initials"`
+	CP       uint32 `json:"cp"` // of the comment's reference mark in the body
+	Text     string `json:"text"`
+}
+
+type jsonMailMerge struct {
+	MainDocument   bool     `json:"maindocument"`
+	Fields         []string `json:"fields"`
+	DataSource     string   `json:"datasource"`
+	HeaderDocument string   `json:"headerdocument"`
+	Connection     string   `json:"connection"`
+	Query          string   `json:"query"`
+	Databases      []string `json:"databases"`
+}
+
+type jsonFinding struct {
+	Message string  `json:"message"`
+	Region  string  `json:"region,omitempty"`
+	CP      *uint32 `json:"cp,omitempty"` // if the finding is in a region
+}
+
+type jsonHyperlink struct {
+	Region        string `json:"region"`
+	CP            uint32 `json:"cp"`
+	Target        string `json:"target"`
+	Location      string `json:"location,omitempty"`
+	Hlink         string `json:"hlink,omitempty"` // from the stored hyperlink data
+	HlinkLocation string `json:"hlinklocation,omitempty"`
+}
+
+type jsonObject struct {
+	Name        string `json:"name"`
+	CLSID       string `json:"clsid"`
+	Type        string `json:"type"`
+	ProgID      string `json:"progid"`
+	File        string `json:"file,omitempty"`        // the name of the packaged file
+	Control     bool   `json:"control,omitempty"`     // the object is an ActiveX control,
+	ControlName string `json:"controlname,omitempty"` // with this name
+}
+
+type jsonLayout struct {
+	Region      string     `json:"region"`
+	CP          uint32     `json:"cp"`
+	Type        string     `json:"type"`
+	Instruction string     `json:"instruction"`
+	Char        *uint32    `json:"char,omitempty"` // for SYMBOL (null if it can't be read), with the font, size and encoding
+	Font        string     `json:"font,omitempty"`
+	Size        string     `json:"size,omitempty"`
+	Encoding    string     `json:"encoding,omitempty"`
+	Moves       []jsonMove `json:"moves,omitempty"`    // for ADVANCE
+	Switches    []string   `json:"switches,omitempty"` // for EQ
+}
+
+type jsonMove struct {
+	Switch string `json:"switch"`
+	Points string `json:"points"`
+}
+
+type jsonControls struct {
+	Count  int      `json:"count"`
+	CLSIDs []string `json:"clsids"` // of each control, in the same order as Names
+	Names  []string `json:"names"`
+}
+
+type jsonProtection struct {
+	Forms               bool            `json:"forms"`
+	Comments            bool            `json:"comments"`
+	Revisions           bool            `json:"revisions"`
+	ReadOnlyRecommended bool            `json:"readonly"`
+	WriteReservation    bool            `json:"writereservation"`
+	FormFields          []jsonFormField `json:"formfields,omitempty"` // when protected for forms
+}
+
+type jsonTemplate struct {
+	AutoText       []jsonAutoText `json:"autotext"`
+	Macros         bool           `json:"macros"`
+	KeyAssignments int            `json:"keys"`
+	MacroCommands  int            `json:"macrocommands"`
+	MacroNames     []string       `json:"macronames,omitempty"`
+	Toolbars       bool           `json:"toolbars"`
+}
+
+type jsonAutoText struct {
+	Name         string   `json:"name"`
+	Fields       []string `json:"fields"`
+	Instructions []string `json:"instructions"`
+}
+
+type jsonFormField struct {
+	Region     string   `json:"region"`
+	CP         uint32   `json:"cp"`
+	Type       string   `json:"type"`
+	Name       string   `json:"name"`
+	Default    string   `json:"default"`
+	Entries    []string `json:"entries,omitempty"`
+	EntryMacro string   `json:"entrymacro,omitempty"`
+	ExitMacro  string   `json:"exitmacro,omitempty"`
+}
+
+type jsonPicture struct {
+	Region  string  `json:"region"`
+	CP      uint32  `json:"cp"`
+	Offset  uint32  `json:"offset"`           // of the PICF in the Data stream
+	Format  string  `json:"format,omitempty"` // empty if no image is stored
+	Size    uint32  `json:"size"`
+	Link    string  `json:"link,omitempty"`
+	Field   *uint32 `json:"field,omitempty"` // the CP of the INCLUDEPICTURE field whose result it is
+	Damaged bool    `json:"damaged,omitempty"`
+}
+
+type jsonFastSave struct {
+	FastSaved  bool `json:"fastsaved"`  // fComplex
+	QuickSaves int  `json:"quicksaves"` // cQuickSaves: 15 if it isn't recorded
+	Pieces     int  `json:"pieces"`     // 0 if the piece table can't be read
+}
+
+type jsonLanguages struct {
+	Primary jsonLanguage   `json:"primary"`           // the language most of the text is in (see fields.Languages.Primary)
+	Text    []jsonLanguage `json:"text"`              // with the number of characters in each
+	Default *jsonLanguage  `json:"default,omitempty"` // the Normal style's
+	Install jsonLanguage   `json:"install"`
+	FarEast *jsonLanguage  `json:"fareast,omitempty"`
+}
+
+type jsonLanguage struct {
+	Name  string `json:"name"`          // e.g. English (United Kingdom) (0x0809)
+	Tag   string `json:"tag,omitempty"` // e.g. en-GB
+	Chars int    `json:"chars,omitempty"`
+}
+
+func newJSONLanguage(lid uint16) *jsonLanguage {
+	return &jsonLanguage{Name: fields.LanguageName(lid), Tag: fields.LanguageTag(lid)}
+}
+
+type jsonRevisions struct {
+	Tracking   bool     `json:"tracking"`
+	Insertions int      `json:"insertions"`
+	Deletions  int      `json:"deletions"`
+	Authors    []string `json:"authors"`
+}
+
+type jsonStructure struct {
+	Begins          int `json:"begins"`
+	Separators      int `json:"separators"`
+	Ends            int `json:"ends"`
+	MaxDepth        int `json:"maxdepth"`
+	Unclosed        int `json:"unclosed"`
+	StrayEnds       int `json:"strayends"`
+	StraySeparators int `json:"strayseparators"`
+	Invalid         int `json:"invalid,omitempty"`
+	Unordered       int `json:"unordered,omitempty"`
+}
+
+func writeJSON(w io.Writer, name string, fix *fixity, res *fields.Report, err error) {
+	jr := jsonResult{File: header(name), Status: fileStatus(err)}
+	if fix != nil {
+		jr.Size, jr.Checksum = fix.Size, map[string]string{*hashAlg: fix.Checksum}
+	}
+	if err != nil {
+		jr.Error = err.Error()
+	}
+	if *triageMode {
+		risk, inds := triage(res, err)
+		jr.Triage = &jsonTriage{riskNames[risk], []string{}}
+		for _, ind := range inds {
+			jr.Triage.Indicators = append(jr.Triage.Indicators, ind.desc)
+		}
+	}
+	if filePolicy != nil {
+		verdict, matched := filePolicy.evaluate(res, err)
+		jr.Policy = &jsonPolicy{verdictNames[verdict], []jsonPolicyRule{}}
+		for _, m := range matched {
+			jr.Policy.Rules = append(jr.Policy.Rules, jsonPolicyRule{m.name, verdictNames[m.verdict]})
+		}
+	}
+	if res != nil {
+		jr.Format = res.Format
+		jr.Encryption = res.Encryption
+		if *wordVersion && res.Format == fields.FormatDOC {
+			jr.Version, jr.Template = res.WordVersion(), &res.Flags.Dot
+		}
+		if *meta {
+			props := jsonProperties(res.Properties)
+			jr.Properties = &props
+			for _, p := range res.CustomProperties {
+				if jr.Custom == nil {
+					jr.Custom = make(map[string]string)
+				}
+				jr.Custom[p.Name] = p.Value
+			}
+		}
+		if *assoc {
+			assocs := jsonAssociations(res.Associations)
+			jr.Associations = &assocs
+		}
+		if *bookmarks {
+			for _, b := range res.Bookmarks {
+				jr.Bookmarks = append(jr.Bookmarks, jsonBookmark(b))
+			}
+		}
+		if *crossRefs {
+			refs, resolved := res.CrossReferences()
+			for _, x := range refs {
+				jx := jsonCrossRef{Region: x.Region, CP: x.CP, Type: x.Type, Bookmark: x.Bookmark}
+				if resolved {
+					found := x.Target != nil
+					jx.Found = &found
+				}
+				if x.Target != nil {
+					b := jsonBookmark(*x.Target)
+					jx.Target = &b
+				}
+				jr.CrossRefs = append(jr.CrossRefs, jx)
+			}
+		}
+		if *comments {
+			for _, c := range res.Comments {
+				jr.Comments = append(jr.Comments, jsonComment(c))
+			}
+		}
+		if *mailMerge {
+			mm := jsonMailMerge(res.MailMerge)
+			if mm.Fields == nil {
+				mm.Fields = []string{}
+			}
+			if mm.Databases == nil {
+				mm.Databases = []string{}
+			}
+			jr.MailMerge = &mm
+		}
+		if *hyperlinks {
+			for _, h := range res.Hyperlinks {
+				jr.Hyperlinks = append(jr.Hyperlinks, jsonHyperlink(h))
+			}
+		}
+		for name, findings := range res.Findings { // the analyzers are run whenever any are registered
+			if jr.Findings == nil {
+				jr.Findings = make(map[string][]jsonFinding)
+			}
+			jf := []jsonFinding{}
+			for _, f := range findings {
+				j := jsonFinding{Message: f.Message, Region: f.Region}
+				if f.Region != "" {
+					cp := f.CP
+					j.CP = &cp
+				}
+				jf = append(jf, j)
+			}
+			jr.Findings[name] = jf
+		}
+		if *objects {
+			for _, o := range res.Objects {
+				jr.Objects = append(jr.Objects, jsonObject(o))
+			}
+		}
+		if *protection && res.Format == fields.FormatDOC && res.Encryption == "" {
+			p := res.Protection
+			jr.Protection = &jsonProtection{p.Forms, p.Comments, p.Revisions, p.ReadOnlyRecommended, p.WriteReservation, nil}
+			if p.Forms {
+				for _, ff := range res.FormFields {
+					jr.Protection.FormFields = append(jr.Protection.FormFields, jsonFormField(ff))
+				}
+			}
+		}
+		if *layoutRisk {
+			for _, r := range res.Regions() {
+				for _, f := range r.Occurrences {
+					l, ok := f.Layout()
+					if !ok {
+						continue
+					}
+					jl := jsonLayout{Region: r.Key, CP: f.CP, Type: f.Name, Instruction: f.Instruction, Switches: l.Switches}
+					if f.Name == "symbol" {
+						jl.Font, jl.Size, jl.Encoding = l.Font, l.Size, l.Encoding
+						if l.Char != 0 {
+							jl.Char = &l.Char
+						}
+					}
+					for _, m := range l.Moves {
+						jl.Moves = append(jl.Moves, jsonMove(m))
+					}
+					jr.Layout = append(jr.Layout, jl)
+				}
+			}
+		}
+		if *controlsFlag && res.Format == fields.FormatDOC && res.Encryption == "" {
+			jr.Controls = &jsonControls{CLSIDs: []string{}, Names: []string{}}
+			for _, c := range res.Controls() {
+				jr.Controls.Count++
+				jr.Controls.CLSIDs, jr.Controls.Names = append(jr.Controls.CLSIDs, c.CLSID), append(jr.Controls.Names, c.ControlName)
+			}
+		}
+		if *dot && res.Template != nil {
+			c := res.Template.Customizations
+			jr.Dot = &jsonTemplate{[]jsonAutoText{}, res.Macros, c.KeyAssignments, c.MacroCommands, c.MacroNames, c.Toolbars}
+			for _, a := range res.Template.AutoText {
+				ja := jsonAutoText{a.Name, []string{}, []string{}}
+				for _, f := range a.Fields {
+					ja.Fields, ja.Instructions = append(ja.Fields, f.Name), append(ja.Instructions, f.Instruction)
+				}
+				jr.Dot.AutoText = append(jr.Dot.AutoText, ja)
+			}
+		}
+		if *pictures && res.Pictures != nil {
+			for _, p := range res.Pictures {
+				jp := jsonPicture{p.Region, p.CP, p.Offset, p.Format, p.Size, p.Link, nil, p.Damaged}
+				if p.Included {
+					cp := p.Field
+					jp.Field = &cp
+				}
+				jr.Pictures = append(jr.Pictures, jp)
+			}
+		}
+		if *fastSave && res.Format == fields.FormatDOC && res.Encryption == "" {
+			jr.FastSave = &jsonFastSave{res.Flags.Complex, res.Flags.QuickSaves, res.Pieces}
+		}
+		if *lang && res.Format == fields.FormatDOC {
+			l := res.Languages
+			jr.Languages = &jsonLanguages{Primary: *newJSONLanguage(l.Primary()), Text: []jsonLanguage{}, Install: *newJSONLanguage(l.Install)}
+			for _, lc := range l.Text {
+				jl := newJSONLanguage(lc.LID)
+				jl.Chars = lc.Chars
+				jr.Languages.Text = append(jr.Languages.Text, *jl)
+			}
+			if l.Default != 0 {
+				jr.Languages.Default = newJSONLanguage(l.Default)
+			}
+			if l.FarEast != 0 {
+				jr.Languages.FarEast = newJSONLanguage(l.FarEast)
+			}
+		}
+		if *revisions {
+			jr.Revisions = &jsonRevisions{res.Revisions.Tracking, res.Revisions.Insertions, res.Revisions.Deletions, []string{}}
+			jr.Revisions.Authors = append(jr.Revisions.Authors, res.Revisions.Authors...)
+		}
+		if *savedBy {
+			for _, sb := range res.SavedBy {
+				jr.SavedBy = append(jr.SavedBy, jsonSavedBy(sb))
+			}
+		}
+		jr.Table = res.Table
+		jr.Unreferenced = res.Unreferenced
+		for k, st := range res.Structure {
+			if jr.Structure == nil {
+				jr.Structure = make(map[string]jsonStructure)
+			}
+			jr.Structure[k] = jsonStructure(st)
+		}
+		jr.Macros = res.Macros
+		jr.Sizes, jr.TotalSize = res.Sizes, res.TotalSize
+		jr.Warnings = res.Warnings
+		if *volatile && (err == nil || err == fields.ErrNoFields) {
+			p := classify(res)
+			jr.Preservation = &jsonPreservation{p.score(), p.static, p.volatile, p.external, p.interactive}
+		}
+		jr.Fields = make(map[string][]string)
+		for _, r := range res.AllRegions() { // every region is included, so empty regions show up as empty lists
+			occs := r.Occurrences
+			if !*raw { // sort by name, unless -raw asks for document order
+				occs = sortFields(occs)
+			}
+			names, cps, ends, instrs, results := []string{}, []uint32{}, []uint32{}, []string{}, []string{}
+			for _, fld := range occs {
+				names, cps, ends, instrs, results = append(names, fld.Name), append(cps, fld.CP), append(ends, fld.End), append(instrs, fld.Instruction), append(results, fld.Result)
+				if t, ok := fld.Type(); ok {
+					if jr.Types == nil {
+						jr.Types = make(map[string]jsonFieldType)
+					}
+					jt := newJSONFieldType(t)
+					jt.Name = ""
+					jr.Types[t.Name] = jt
+				}
+			}
+			jr.Fields[r.Key] = names
+			if *positions {
+				if jr.Positions == nil {
+					jr.Positions = make(map[string][]uint32)
+				}
+				jr.Positions[r.Key] = cps
+				if jr.Ends == nil {
+					jr.Ends = make(map[string][]uint32)
+				}
+				jr.Ends[r.Key] = ends
+				if r.Key != "body" && r.Key != "header" && r.Key != "comment" && res.Format == fields.FormatDOC { // only a .doc's textboxes and notes are told apart
+					if jr.Stories == nil {
+						jr.Stories, jr.Anchors = make(map[string][]int), make(map[string][]*uint32)
+					}
+					stories, anchors := []int{}, []*uint32{}
+					for _, fld := range occs {
+						var anchor *uint32
+						if fld.Anchored {
+							a := fld.Anchor // a copy, as fld is reused by the loop
+							anchor = &a
+						}
+						stories, anchors = append(stories, fld.Story), append(anchors, anchor)
+					}
+					jr.Stories[r.Key], jr.Anchors[r.Key] = stories, anchors
+				}
+			}
+			if *external {
+				if jr.Targets == nil {
+					jr.Targets = make(map[string][]string)
+				}
+				targets := []string{}
+				for _, fld := range occs {
+					target, _ := fld.External()
+					targets = append(targets, target)
+				}
+				jr.Targets[r.Key] = targets
+			}
+			if *volatile {
+				if jr.Classes == nil {
+					jr.Classes = make(map[string][]string)
+				}
+				classes := []string{}
+				for _, fld := range occs {
+					classes = append(classes, fld.Class().String())
+				}
+				jr.Classes[r.Key] = classes
+			}
+			if *instructions {
+				if jr.Instructions == nil {
+					jr.Instructions = make(map[string][]string)
+				}
+				jr.Instructions[r.Key] = instrs
+			}
+			if *fieldResults {
+				if jr.Results == nil {
+					jr.Results = make(map[string][]string)
+				}
+				jr.Results[r.Key] = results
+			}
+		}
+	}
+	if err := json.NewEncoder(w).Encode(jr); err != nil { // Encode adds the newline
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
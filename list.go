@@ -0,0 +1,99 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// listEntries prints the path and size of every storage and stream in a compound file, without trying to parse it as a word doc.
+// Useful for working out why a file gives fields.ErrTable or fields.ErrNoWordDocument.
+func listEntries(w io.Writer, in string) error {
+	file, closeInput, err := openInput(in)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+	doc, err := mscfb.New(file)
+	if err != nil {
+		return wrapError(err)
+	}
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		path := strings.Join(append(entry.Path, entry.Name), "/")
+		if entry.FileInfo().IsDir() {
+			fmt.Fprintf(w, "%s/ (storage)\n", path)
+			continue
+		}
+		fmt.Fprintf(w, "%s (%d bytes)\n", path, entry.Size)
+	}
+	return nil
+}
+
+// writeDocText prints the plain text of a .doc (see fields.Text)
+func writeDocText(w io.Writer, in string) error {
+	file, closeInput, err := openInput(in)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+	return fields.Text(file, w)
+}
+
+// the most characters of a run of slack text shown by writeSlack, unless the runs are printed in full
+const slackPreview = 60
+
+// writeSlack reports the runs of text in a .doc that aren't part of the document (see fields.Slack): where each is in the WordDocument stream, its size
+// and encoding, and the start of its text on one line or, if full is set, all of its text.
+func writeSlack(w io.Writer, in string, full bool) error {
+	file, closeInput, err := openInput(in)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+	runs, err := fields.Slack(file)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "Slack text: none")
+		return nil
+	}
+	var total int64
+	for _, r := range runs {
+		total += r.Length
+	}
+	fmt.Fprintf(w, "Slack text: %d runs, %d bytes\n", len(runs), total)
+	for _, r := range runs {
+		enc := "8-bit"
+		if r.Unicode {
+			enc = "UTF-16"
+		}
+		if full {
+			fmt.Fprintf(w, "--- 0x%X (%d bytes, %s)\n%s\n", r.Offset, r.Length, enc, r.Text)
+			continue
+		}
+		preview := []rune(strings.Join(strings.Fields(r.Text), " "))
+		if len(preview) > slackPreview {
+			preview = append(preview[:slackPreview], '…')
+		}
+		fmt.Fprintf(w, "  0x%X (%d bytes, %s): %s\n", r.Offset, r.Length, enc, string(preview))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// a row of the matrix report: the count of each field type found in a file, across all its regions
+type matrixRow struct {
+	name   string
+	counts map[string]int
+}
+
+// the matrix can't be written until every file has been processed, as the columns are all the field types seen during the run
+var (
+	matrixRows []matrixRow
+	matrixCols = make(map[string]bool)
+)
+
+func addRow(name string, res *fields.Report, err error) {
+	if err != nil && err != fields.ErrNoFields {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err) // keep failed files out of the table, but not silently
+		return
+	}
+	row := matrixRow{name, make(map[string]int)}
+	if res != nil {
+		row.counts = res.Counts()
+		for f := range row.counts {
+			matrixCols[f] = true
+		}
+	}
+	matrixRows = append(matrixRows, row)
+}
+
+// writeMatrix writes the matrix report as CSV: a header row of "file" and then the field types (sorted), followed by a row of counts for each file
+func writeMatrix(w io.Writer) error {
+	cols := make([]string, 0, len(matrixCols))
+	for c := range matrixCols {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"file"}, cols...))
+	for _, row := range matrixRows {
+		rec := make([]string, len(cols)+1)
+		rec[0] = header(row.name)
+		for i, c := range cols {
+			rec[i+1] = strconv.Itoa(row.counts[c])
+		}
+		cw.Write(rec)
+	}
+	cw.Flush()
+	return cw.Error()
+}
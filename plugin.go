@@ -0,0 +1,42 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"plugin"
+	"strings"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// loadPlugins opens the Go plugins given with -plugin, a comma-separated list of paths. Each plugin registers its analyzers with fields.RegisterAnalyzer
+// from an init function, which runs when it is opened, so nothing needs to be looked up in it; a plugin that doesn't register any is an error, as it was probably meant to.
+// Go plugins are only supported on Linux, macOS and FreeBSD, and must be built with the same version of Go and of doctool's packages as doctool itself.
+func loadPlugins(paths string) error {
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		before := len(fields.Analyzers())
+		if _, err := plugin.Open(path); err != nil {
+			return err // which names the file
+		}
+		if len(fields.Analyzers()) == before {
+			return fmt.Errorf("%s: the plugin doesn't register any analyzers (with fields.RegisterAnalyzer)", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/ross-spencer/doctool/fields"
+	"gopkg.in/yaml.v3"
+)
+
+// verdicts for -policy, in increasing order of severity
+const (
+	verdictPass = iota
+	verdictWarn
+	verdictFail
+)
+
+var verdictNames = []string{"pass", "warn", "fail"}
+
+// policy is an archive's acceptance criteria, loaded from the YAML file given with -policy, e.g.:
+//
+//	default: pass
+//	rules:
+//	  - name: DDE fields
+//	    fields: [DDE, DDEAUTO]
+//	    verdict: fail
+//	  - name: external content
+//	    classes: [external]
+//	    verdict: warn
+//	  - name: macros
+//	    macros: true
+//	    verdict: fail
+//	  - name: not from ACME
+//	    property: company
+//	    matches: "^ACME"
+//	    negate: true
+//	    verdict: warn
+//
+// A file's verdict is the most severe of the verdicts of the rules it matches, or the default if it matches none.
+type policy struct {
+	Default string       `yaml:"default"` // pass if not given
+	Rules   []policyRule `yaml:"rules"`
+	def     int
+}
+
+// policyRule gives a verdict for the files that match all of its conditions (at least one of which must be given).
+// Field types are matched by name or keyword, ignoring case and spaces, as for -fields.
+type policyRule struct {
+	Name      string   `yaml:"name"`
+	Verdict   string   `yaml:"verdict"`   // pass, warn or fail
+	Fields    []string `yaml:"fields"`    // the file has fields of any of these types
+	Classes   []string `yaml:"classes"`   // the file has fields whose types are in any of these classes (static, volatile, external or interactive; see `doctool fields list`)
+	Min       int      `yaml:"min"`       // with fields or classes, the fewest such fields the file must have (1 if not given)
+	Macros    *bool    `yaml:"macros"`    // the file has (true) or hasn't (false) a VBA project
+	Encrypted *bool    `yaml:"encrypted"` // the document is (true) or isn't (false) encrypted
+	Triage    string   `yaml:"triage"`    // the -triage risk is at least this (medium or high)
+	Status    []string `yaml:"status"`    // the file's status is one of these (ok, nofields, encrypted, notword or error, as in -json)
+	Property  string   `yaml:"property"`  // a document property (title, subject, author, lastsavedby, created, modified, application, template or company) or custom property, by name
+	Matches   string   `yaml:"matches"`   // with property, a regular expression the property's value must match; without it, the property must be set
+	Negate    bool     `yaml:"negate"`    // with property, match the files whose property doesn't match instead
+	verdict   int
+	fields    map[string]bool
+	risk      int
+	re        *regexp.Regexp
+}
+
+// ruleResult is a rule that a file matched
+type ruleResult struct {
+	name    string
+	verdict int
+}
+
+// filePolicy is the rule file given with -policy
+var filePolicy *policy
+
+// policyFailed is set if a file fails the policy, so that doctool exits with exitPolicy
+var policyFailed bool
+
+// verdictIndex returns the verdict with the given name (pass, warn or fail)
+func verdictIndex(name string) (int, error) {
+	for i, v := range verdictNames {
+		if strings.EqualFold(name, v) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown verdict %q: expecting pass, warn or fail", name)
+}
+
+// loadPolicy reads and checks a policy file, reporting errors by the number of the rule (from 1)
+func loadPolicy(path string) (*policy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	p := &policy{}
+	dec := yaml.NewDecoder(file)
+	dec.KnownFields(true) // so that a misspelt condition isn't silently ignored
+	if err := dec.Decode(p); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if p.Default != "" {
+		if p.def, err = verdictIndex(p.Default); err != nil {
+			return nil, fmt.Errorf("%s: default: %v", path, err)
+		}
+	}
+	for i := range p.Rules {
+		if err := p.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %v", path, i+1, err)
+		}
+	}
+	return p, nil
+}
+
+// compile checks a rule and prepares it for matching
+func (r *policyRule) compile() error {
+	var err error
+	if r.Verdict == "" {
+		return errors.New("no verdict")
+	}
+	if r.verdict, err = verdictIndex(r.Verdict); err != nil {
+		return err
+	}
+	if len(r.Fields) == 0 && len(r.Classes) == 0 && r.Macros == nil && r.Encrypted == nil && r.Triage == "" && len(r.Status) == 0 && r.Property == "" {
+		return errors.New("no conditions")
+	}
+	if r.Name == "" {
+		r.Name = r.Verdict
+	}
+	if (r.Matches != "" || r.Negate) && r.Property == "" {
+		return errors.New("matches and negate need a property")
+	}
+	if r.Min > 0 && len(r.Fields) == 0 && len(r.Classes) == 0 {
+		return errors.New("min needs fields or classes")
+	}
+	if r.Min == 0 {
+		r.Min = 1
+	}
+	r.fields = make(map[string]bool)
+	for _, f := range r.Fields {
+		r.fields[normaliseField(f)] = true
+	}
+	for _, c := range r.Classes {
+		switch c {
+		case fields.Static.String(), fields.Volatile.String(), fields.External.String(), fields.Interactive.String():
+		default:
+			return fmt.Errorf("unknown class %q: expecting static, volatile, external or interactive", c)
+		}
+	}
+	if r.Triage != "" {
+		for i := riskMedium; i < len(riskNames); i++ {
+			if r.Triage == riskNames[i] {
+				r.risk = i
+			}
+		}
+		if r.risk == riskNone {
+			return fmt.Errorf("unknown triage risk %q: expecting medium or high", r.Triage)
+		}
+	}
+	for _, st := range r.Status {
+		switch st {
+		case statusOK, statusNoFields, statusEncrypted, statusNotWord, statusError, statusTimeout:
+		default:
+			return fmt.Errorf("unknown status %q", st)
+		}
+	}
+	if r.Matches != "" {
+		if r.re, err = regexp.Compile(r.Matches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluate returns the verdict of the policy for a file, along with the rules it matched
+func (p *policy) evaluate(res *fields.Report, err error) (int, []ruleResult) {
+	var matched []ruleResult
+	verdict := -1
+	for _, r := range p.Rules {
+		if r.match(res, err) {
+			matched = append(matched, ruleResult{r.Name, r.verdict})
+			if r.verdict > verdict {
+				verdict = r.verdict
+			}
+		}
+	}
+	if verdict < 0 {
+		verdict = p.def
+	}
+	return verdict, matched
+}
+
+// match reports whether a file meets all of the rule's conditions
+func (r policyRule) match(res *fields.Report, err error) bool {
+	if len(r.Status) > 0 && !contains(r.Status, fileStatus(err)) {
+		return false
+	}
+	if r.risk > riskNone {
+		if risk, _ := triage(res, err); risk < r.risk {
+			return false
+		}
+	}
+	if r.Encrypted != nil && *r.Encrypted != (errors.Is(err, fields.ErrEncrypted) || res != nil && res.Encryption != "") {
+		return false
+	}
+	if res == nil { // nothing else can be told about a file that couldn't be read
+		return r.Macros == nil && len(r.fields) == 0 && len(r.Classes) == 0 && r.Property == ""
+	}
+	if r.Macros != nil && *r.Macros != res.Macros {
+		return false
+	}
+	if len(r.fields) > 0 || len(r.Classes) > 0 {
+		var n int
+		for _, reg := range res.Regions() {
+			for _, f := range reg.Occurrences {
+				if r.matchField(f) {
+					n++
+				}
+			}
+		}
+		if n < r.Min {
+			return false
+		}
+	}
+	if r.Property != "" {
+		value, ok := propertyValue(res, r.Property)
+		matches := ok && value != ""
+		if r.re != nil {
+			matches = ok && r.re.MatchString(value)
+		}
+		if matches == r.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// matchField reports whether a field is of one of the rule's types, or in one of its classes
+func (r policyRule) matchField(f fields.Field) bool {
+	if r.fields[normaliseField(f.Name)] {
+		return true
+	}
+	t, ok := f.Type()
+	if !ok {
+		return false
+	}
+	return r.fields[normaliseField(t.Keyword)] || contains(r.Classes, t.Class.String())
+}
+
+// propertyValue returns the value of a document property or, failing that, a custom property, by name (ignoring case), and whether there is one
+func propertyValue(res *fields.Report, name string) (string, bool) {
+	p := res.Properties
+	switch strings.ToLower(name) {
+	case "title":
+		return p.Title, true
+	case "subject":
+		return p.Subject, true
+	case "author":
+		return p.Author, true
+	case "lastsavedby":
+		return p.LastSavedBy, true
+	case "created":
+		return p.Created, true
+	case "modified":
+		return p.Modified, true
+	case "application":
+		return p.Application, true
+	case "template":
+		return p.Template, true
+	case "company":
+		return p.Company, true
+	}
+	for _, cp := range res.CustomProperties {
+		if strings.EqualFold(cp.Name, name) {
+			return cp.Value, true
+		}
+	}
+	return "", false
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// policySummary describes a file's verdict for the text report, e.g. "FAIL (DDE fields: fail, external content: warn)"
+func policySummary(verdict int, matched []ruleResult) string {
+	s := strings.ToUpper(verdictNames[verdict])
+	if len(matched) == 0 {
+		return s + " (no rules matched)"
+	}
+	descs := make([]string, len(matched))
+	for i, m := range matched {
+		descs[i] = m.name + ": " + verdictNames[m.verdict]
+	}
+	return s + " (" + strings.Join(descs, ", ") + ")"
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// profileSets are named sets of field types. Use one with -profile-set to report only those fields.
+// Names are the field names from fieldnames.go, but they are matched ignoring case and spaces, so the keyword (e.g. INCLUDETEXT) works too.
+var profileSets = map[string][]string{
+	"security": {"dde", "dde auto", "include text", "include picture", "import", "link", "embed", "macro button", "control", "html control", "add in"},
+	"links":    {"hyperlink", "ref", "ref - no keyword", "pageref", "note ref", "ftnref", "goto button", "include text", "include picture", "import", "link"},
+	"forms":    {"form text", "form checkbox", "form dropdown", "fill in", "ask", "macro button", "goto button", "control", "html control"},
+	"merge":    {"merge field", "merge rec", "merge seq", "next", "next if", "skip if", "data", "address block", "greeting line", "fill in", "ask", "set", "if", "database"},
+}
+
+func normaliseField(f string) string {
+	return strings.ToLower(strings.Replace(f, " ", "", -1))
+}
+
+// loadProfiles adds the profile sets defined in a file to the built-in ones (replacing any with the same name).
+// Each line of the file is a name, an equals sign, and a comma separated list of fields, e.g.:
+//
+//	link-audit = HYPERLINK, REF, INCLUDETEXT
+//
+// Blank lines and lines starting with # are ignored.
+func loadProfiles(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		idx := strings.Index(line, "=")
+		if idx < 1 {
+			return fmt.Errorf("%s line %d: expecting name = field, field, ...", path, n)
+		}
+		var fields []string
+		for _, f := range strings.Split(line[idx+1:], ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, f)
+			}
+		}
+		profileSets[strings.TrimSpace(line[:idx])] = fields
+	}
+	return scanner.Err()
+}
+
+// profile is the set of (normalised) field names selected with -profile-set, -type and -fields
+var profile map[string]bool
+
+// listFlag is a flag that can be given more than once, or with a comma separated list, e.g. -type INCLUDETEXT -type DDEAUTO or -fields INCLUDETEXT,DDEAUTO
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// addTypes adds the field types given with -type and -fields to the profile
+func addTypes(types []string) {
+	if profile == nil {
+		profile = make(map[string]bool)
+	}
+	for _, t := range types {
+		profile[normaliseField(t)] = true
+	}
+}
+
+func setProfile(name string) error {
+	fields, ok := profileSets[name]
+	if !ok {
+		names := make([]string, 0, len(profileSets))
+		for k := range profileSets {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown profile set %q; available sets are: %s", name, strings.Join(names, ", "))
+	}
+	profile = make(map[string]bool)
+	for _, f := range fields {
+		profile[normaliseField(f)] = true
+	}
+	return nil
+}
+
+// applyProfile drops the fields that aren't in the selected profile set, along with any regions left empty
+func applyProfile(res *fields.Report) {
+	res.Filter(func(f fields.Field) bool { return profile[normaliseField(f.Name)] })
+}
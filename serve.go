@@ -0,0 +1,167 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// the flags that only serve has
+var (
+	serveAddr    *string
+	serveMaxSize *int64
+)
+
+const (
+	serveReadTimeout  = 2 * time.Minute  // the longest a client may take to send a request
+	serveParseTimeout = 10 * time.Minute // the longest the response may take, after the request, without -timeout
+)
+
+// serveFlags defines the flags that only serve has
+func serveFlags(fs *flag.FlagSet) {
+	serveAddr = fs.String("addr", ":8080", "the address to listen on")
+	serveMaxSize = fs.Int64("max-size", 100, "the largest document accepted, in MiB")
+}
+
+// serveCommand runs `doctool serve [-addr :8080] [-max-size 100]`, an HTTP service that reports the fields of each document POSTed to it.
+// The report is the same as a line of -json output, and the other flags apply as they do to files, e.g. doctool serve -triage -external.
+func serveCommand() error {
+	n := *workers
+	if n < 1 {
+		n = 1
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", &server{maxSize: *serveMaxSize << 20, sem: make(chan struct{}, n)})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, "ok") })
+	// a slow client can't hold a connection open indefinitely: it has serveReadTimeout to send the document,
+	// and the response must be written within the parse's -timeout (or serveParseTimeout, without one) after that
+	write := serveReadTimeout + serveParseTimeout
+	if *timeout > 0 {
+		write = serveReadTimeout + *timeout + 10*time.Second
+	}
+	srv := &http.Server{Addr: *serveAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second, ReadTimeout: serveReadTimeout, WriteTimeout: write}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+		shut, cancel := context.WithTimeout(context.Background(), 30*time.Second) // let the reports in progress finish
+		defer cancel()
+		srv.Shutdown(shut)
+	}()
+	log.Printf("doctool serving on %s", *serveAddr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// server handles the POSTs to doctool serve. The document is either the whole body of the request,
+// or the first file in a multipart/form-data upload (e.g. curl -F file=@letter.doc).
+// Its name in the report is the name of the uploaded file, or the name query parameter, e.g. curl --data-binary @letter.doc localhost:8080/?name=letter.doc
+type server struct {
+	maxSize int64
+	sem     chan struct{} // limits the documents parsed at once to -j
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST a document to get its field report", http.StatusMethodNotAllowed)
+		return
+	}
+	name, buf, err := s.readUpload(w, r)
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			http.Error(w, fmt.Sprintf("the document is bigger than the limit of %d bytes", s.maxSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	select {
+	case s.sem <- struct{}{}:
+	case <-r.Context().Done():
+		return
+	}
+	fix, _ := checksum(bytes.NewReader(buf)) // reading from memory can't fail
+	res, _, err := withTimeout(r.Context(), func(ctx context.Context) (*fields.Report, *fixity, error) {
+		defer func() { <-s.sem }() // released when the parse ends, not when a -timeout abandons it, so -j still bounds the parses running
+		res, err := parse(ctx, name, bytes.NewReader(buf))
+		return res, nil, err
+	})
+	if err != nil && err == r.Context().Err() { // the client has gone
+		return
+	}
+	if res != nil {
+		filter(res)
+	}
+	var report bytes.Buffer
+	writeJSON(&report, name, fix, res, err)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(report.Bytes())
+}
+
+// readUpload returns the name and content of the document in a request
+func (s *server) readUpload(w http.ResponseWriter, r *http.Request) (string, []byte, error) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "-"
+	}
+	body := http.MaxBytesReader(w, r.Body, s.maxSize)
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "multipart/form-data" {
+		buf, err := io.ReadAll(body)
+		if err == nil && len(buf) == 0 {
+			err = errors.New("the request has no document")
+		}
+		return name, buf, err
+	}
+	r.Body = body
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return "", nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return "", nil, errors.New("the form has no file")
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		if r.URL.Query().Get("name") == "" {
+			name = part.FileName()
+		}
+		buf, err := io.ReadAll(part)
+		return name, buf, err
+	}
+}
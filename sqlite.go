@@ -0,0 +1,215 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ross-spencer/doctool/fields"
+	_ "modernc.org/sqlite" // a pure Go driver, so doctool still builds without cgo
+)
+
+// dbSchema creates the -sqlite tables, if the database doesn't have them already (so a database can collect several runs).
+// Field names are as in the other output, e.g. "dde auto", and times as in the -meta output, e.g. 2015-01-27 01:04:00 +0000 UTC, so that they sort.
+var dbSchema = []string{
+	`CREATE TABLE IF NOT EXISTS files (
+	id INTEGER PRIMARY KEY,
+	path TEXT NOT NULL,
+	size INTEGER,
+	checksum TEXT,
+	checksum_algorithm TEXT,
+	format TEXT,
+	status TEXT NOT NULL,
+	table_stream TEXT,
+	version TEXT,
+	macros INTEGER,
+	encryption TEXT,
+	title TEXT,
+	subject TEXT,
+	author TEXT,
+	last_saved_by TEXT,
+	created TEXT,
+	modified TEXT,
+	application TEXT,
+	template TEXT,
+	company TEXT
+)`,
+	`CREATE TABLE IF NOT EXISTS field_occurrences (
+	file_id INTEGER NOT NULL REFERENCES files(id),
+	region TEXT NOT NULL,
+	field TEXT NOT NULL,
+	code INTEGER,
+	cp INTEGER,
+	end_cp INTEGER,
+	depth INTEGER,
+	instruction TEXT,
+	result TEXT
+)`,
+	`CREATE TABLE IF NOT EXISTS errors (
+	file_id INTEGER NOT NULL REFERENCES files(id),
+	kind TEXT NOT NULL,
+	message TEXT NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS field_occurrences_file ON field_occurrences(file_id)`,
+	`CREATE INDEX IF NOT EXISTS field_occurrences_field ON field_occurrences(field)`,
+}
+
+// dbColumns are the columns added to the tables since they were first created, which are added to the tables of a database made by an earlier version of doctool
+var dbColumns = []string{
+	`ALTER TABLE files ADD COLUMN size INTEGER`,
+	`ALTER TABLE files ADD COLUMN checksum TEXT`,
+	`ALTER TABLE files ADD COLUMN checksum_algorithm TEXT`,
+	`ALTER TABLE field_occurrences ADD COLUMN result TEXT`,
+}
+
+// dbBatch is the number of files written in each transaction: one per file is slow, and one for the whole run would lose everything if doctool were killed
+const dbBatch = 1000
+
+// the -sqlite database, and the transaction the current batch of files is written in
+var (
+	db     *sql.DB
+	dbTx   *sql.Tx
+	dbRows int
+	dbErr  error // the first error writing to the database, after which nothing more is written
+)
+
+// openDB opens (creating if need be) the -sqlite database and its tables
+func openDB(path string) error {
+	var err error
+	if db, err = sql.Open("sqlite", path); err != nil {
+		return err
+	}
+	for _, stmt := range dbSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return fmt.Errorf("creating the tables in %s: %w", path, err)
+		}
+	}
+	for _, stmt := range dbColumns {
+		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column name") { // the table has it already
+			db.Close()
+			return fmt.Errorf("adding columns to the tables in %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// addDBRow writes a file to the -sqlite database: a row in files, a row in field_occurrences for each of its fields, and a row in errors for its error and each warning
+func addDBRow(name string, fix *fixity, res *fields.Report, err error) {
+	if dbErr != nil {
+		return
+	}
+	if dbErr = writeDBRow(name, fix, res, err); dbErr != nil {
+		fmt.Fprintf(os.Stderr, "%s: writing to the -sqlite database: %v\n", name, dbErr)
+		failed = true
+	}
+}
+
+func writeDBRow(name string, fix *fixity, res *fields.Report, err error) error {
+	if dbTx == nil {
+		var e error
+		if dbTx, e = db.Begin(); e != nil {
+			return e
+		}
+	}
+	var r fields.Report
+	if res != nil {
+		r = *res
+	}
+	var version interface{}
+	if r.Format == fields.FormatDOC {
+		version = r.WordVersion()
+	}
+	var size, sum, algorithm interface{}
+	if fix != nil {
+		size, sum, algorithm = fix.Size, fix.Checksum, *hashAlg
+	}
+	p := r.Properties
+	row, e := dbTx.Exec(`INSERT INTO files (path, size, checksum, checksum_algorithm, format, status, table_stream, version, macros, encryption,
+	title, subject, author, last_saved_by, created, modified, application, template, company)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		header(name), size, sum, algorithm, dbText(r.Format), fileStatus(err), dbText(r.Table), version, r.Macros, dbText(r.Encryption),
+		dbText(p.Title), dbText(p.Subject), dbText(p.Author), dbText(p.LastSavedBy), dbText(p.Created), dbText(p.Modified), dbText(p.Application), dbText(p.Template), dbText(p.Company))
+	if e != nil {
+		return e
+	}
+	id, e := row.LastInsertId()
+	if e != nil {
+		return e
+	}
+	if res != nil {
+		for _, reg := range res.AllRegions() {
+			for _, f := range reg.Occurrences {
+				if _, e := dbTx.Exec(`INSERT INTO field_occurrences (file_id, region, field, code, cp, end_cp, depth, instruction, result) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+					id, reg.Key, f.Name, f.Code, f.CP, f.End, f.Depth, dbText(f.Instruction), dbText(f.Result)); e != nil {
+					return e
+				}
+			}
+		}
+	}
+	msgs := r.Warnings
+	if err != nil {
+		msgs = append([]string{err.Error()}, msgs...)
+	}
+	for i, m := range msgs {
+		kind := "warning"
+		if i == 0 && err != nil {
+			kind = "error"
+		}
+		if _, e := dbTx.Exec(`INSERT INTO errors (file_id, kind, message) VALUES (?, ?, ?)`, id, kind, m); e != nil {
+			return e
+		}
+	}
+	if dbRows++; dbRows%dbBatch == 0 {
+		e = dbTx.Commit()
+		dbTx = nil
+	}
+	return e
+}
+
+// dbText gives NULL, rather than an empty string, for a value a document doesn't have
+func dbText(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// commitDB commits the batch of files written so far, so that other readers of the database can see them (doctool watch does this after each file)
+func commitDB() {
+	if dbTx == nil || dbErr != nil {
+		return
+	}
+	if dbErr = dbTx.Commit(); dbErr != nil {
+		fmt.Fprintf(os.Stderr, "writing to the -sqlite database: %v\n", dbErr)
+		failed = true
+	}
+	dbTx = nil
+}
+
+// closeDB commits the last batch of files and closes the -sqlite database
+func closeDB() {
+	if dbTx != nil && dbErr != nil {
+		dbTx.Rollback() // the batch the error happened in
+	}
+	commitDB()
+	if err := db.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "closing the -sqlite database: %v\n", err)
+		failed = true
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// jsonStreams is the object written for each file by `doctool -json streams`
+type jsonStreams struct {
+	File    string       `json:"file"`
+	Entries []jsonStream `json:"entries"`
+	Error   string       `json:"error,omitempty"`
+}
+
+type jsonStream struct {
+	Path     string `json:"path"`
+	Storage  bool   `json:"storage"`
+	Size     int64  `json:"size"`
+	CLSID    string `json:"clsid,omitempty"`
+	Class    string `json:"class,omitempty"` // a description of the CLSID, if doctool knows it
+	Created  string `json:"created,omitempty"`
+	Modified string `json:"modified,omitempty"`
+}
+
+// nullCLSID is the CLSID of a storage or stream that doesn't record one
+const nullCLSID = "00000000-0000-0000-0000-000000000000"
+
+// streamsCommand runs `doctool streams file ...`, which lists every storage and stream of each compound file with its size, CLSID and times,
+// without trying to parse it as a word doc. It's the first thing to look at when a document can't be parsed.
+func streamsCommand(ins []string) {
+	for _, in := range ins {
+		entries, err := readStreams(in)
+		if *jsonOut {
+			js := jsonStreams{File: header(in), Entries: entries}
+			if js.Entries == nil {
+				js.Entries = []jsonStream{}
+			}
+			if err != nil {
+				js.Error = err.Error()
+			}
+			if err := json.NewEncoder(out).Encode(js); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		} else {
+			fmt.Fprintln(out, header(in))
+			if err == nil {
+				writeStreams(out, entries)
+			} else {
+				fmt.Fprintln(out, err)
+			}
+		}
+		if err != nil {
+			failed = true
+		}
+	}
+}
+
+// readStreams reads the directory of a compound file. Names that start with a control character, such as \x05SummaryInformation, are given with it escaped.
+func readStreams(in string) ([]jsonStream, error) {
+	file, closeInput, err := openInput(in)
+	if err != nil {
+		return nil, err
+	}
+	defer closeInput()
+	doc, err := mscfb.New(file)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	var entries []jsonStream
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		name := entry.Name
+		if entry.Initial != 0 {
+			name = fmt.Sprintf("\\x%02X%s", entry.Initial, name)
+		}
+		e := jsonStream{Path: strings.Join(append(entry.Path, name), "/"), Storage: entry.FileInfo().IsDir(), Size: entry.Size,
+			Created: streamTime(entry.Created()), Modified: streamTime(entry.Modified())}
+		if e.Storage {
+			e.Size = 0
+		}
+		if id := entry.ID(); id != nullCLSID {
+			e.CLSID, e.Class = id, fields.ClassName(id)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// streamTime formats the creation or modification time of a storage, or returns an empty string if it isn't recorded (streams never have them)
+func streamTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// writeStreams writes the entries of a compound file as a table
+func writeStreams(w io.Writer, entries []jsonStream) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Path\tType\tSize\tCLSID\tCreated\tModified")
+	for _, e := range entries {
+		kind, size := "stream", fmt.Sprint(e.Size)
+		if e.Storage {
+			kind, size = "storage", ""
+		}
+		clsid := e.CLSID
+		if e.Class != "" {
+			clsid += " (" + e.Class + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Path, kind, size, clsid, e.Created, e.Modified)
+	}
+	tw.Flush()
+}
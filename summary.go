@@ -0,0 +1,181 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// fieldTotal is the number of documents containing a field type, and the number of times it occurs across all of them (in all, and in each region)
+type fieldTotal struct {
+	docs, occurrences int
+	regions           map[string]int
+}
+
+// the running totals for the -summary report
+var (
+	totals                         = make(map[string]*fieldTotal)
+	totalFiles, noFields, errFiles int
+	regionsSeen                    = make(map[string]bool) // the regions with any fields, which get a column in the report
+	failures                       = make(map[string]int)  // the number of files that couldn't be processed, by the kind of error (see errorKind)
+)
+
+// errorKinds are the errors that files are counted by in the summary; a file with any other error is counted by its message
+var errorKinds = []error{fields.ErrEncrypted, fields.ErrNotWord, fields.ErrOOXML, fields.ErrNoWordDocument, fields.ErrTable, fields.ErrFibShort,
+	fields.ErrUnsupportedVersion, fields.ErrTooBig, fs.ErrNotExist, fs.ErrPermission}
+
+// errorKind gives the kind of error a file couldn't be processed with, leaving out the details of the particular file (e.g. the method of encryption)
+func errorKind(err error) string {
+	if u := (unsupportedError("")); errors.As(err, &u) { // checked first, as an unsupportedError is also fields.ErrNotWord
+		return "unsupported format"
+	}
+	if me := (mscfb.Error{}); errors.As(err, &me) && me.Typ() == mscfb.ErrFormat {
+		return "not a compound file"
+	}
+	for _, e := range errorKinds {
+		if errors.Is(err, e) {
+			return e.Error()
+		}
+	}
+	return strings.TrimPrefix(err.Error(), "Error processing file: ")
+}
+
+func addSummary(res *fields.Report, err error) {
+	totalFiles++
+	if err != nil && err != fields.ErrNoFields {
+		errFiles++
+		failures[errorKind(err)]++
+		return
+	}
+	counts := res.Counts()
+	if len(counts) == 0 {
+		noFields++
+		return
+	}
+	for f, n := range counts {
+		t, ok := totals[f]
+		if !ok {
+			t = &fieldTotal{regions: make(map[string]int)}
+			totals[f] = t
+		}
+		t.docs++
+		t.occurrences += n
+	}
+	for _, r := range res.Regions() {
+		for _, f := range r.Fields {
+			totals[f].regions[r.Key]++
+			regionsSeen[r.Key] = true
+		}
+	}
+}
+
+// writeSummary prints the number of files processed, then a table of field types, most widespread first, with their occurrences in each region that has any fields
+func writeSummary(w io.Writer) error {
+	fmt.Fprintf(w, "Files: %d (%d with fields, %d without fields, %d could not be processed)\n", totalFiles, totalFiles-noFields-errFiles, noFields, errFiles)
+	names := make([]string, 0, len(totals))
+	for f := range totals {
+		names = append(names, f)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := totals[names[i]], totals[names[j]]
+		if a.docs != b.docs {
+			return a.docs > b.docs
+		}
+		return names[i] < names[j]
+	})
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	var keys []string
+	for _, k := range fields.RegionKeys() {
+		if regionsSeen[k] {
+			keys = append(keys, k)
+		}
+	}
+	fmt.Fprint(tw, "Field\tDocuments\tOccurrences")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "\t%s", k)
+	}
+	fmt.Fprintln(tw)
+	for _, f := range names {
+		fmt.Fprintf(tw, "%s\t%d\t%d", f, totals[f].docs, totals[f].occurrences)
+		for _, k := range keys {
+			fmt.Fprintf(tw, "\t%d", totals[f].regions[k])
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "\nCould not be processed:")
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	kinds := make([]string, 0, len(failures))
+	for k := range failures {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if failures[kinds[i]] != failures[kinds[j]] {
+			return failures[kinds[i]] > failures[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+	for _, k := range kinds {
+		fmt.Fprintf(tw, "%s\t%d\n", k, failures[k])
+	}
+	return tw.Flush()
+}
+
+type jsonSummary struct {
+	Files      int                         `json:"files"`
+	WithFields int                         `json:"withfields"`
+	NoFields   int                         `json:"nofields"`
+	Failed     int                         `json:"failed"`
+	Failures   map[string]int              `json:"failures"` // by kind of error
+	Fields     map[string]jsonFieldSummary `json:"fields"`
+}
+
+type jsonFieldSummary struct {
+	Documents   int            `json:"documents"`
+	Occurrences int            `json:"occurrences"`
+	Regions     map[string]int `json:"regions"` // the occurrences in each region
+}
+
+// writeSummaryFile writes the -summary totals as JSON to -summary-json's file
+func writeSummaryFile(path string) {
+	js := jsonSummary{totalFiles, totalFiles - noFields - errFiles, noFields, errFiles, failures, make(map[string]jsonFieldSummary)}
+	for f, t := range totals {
+		js.Fields[f] = jsonFieldSummary{t.docs, t.occurrences, t.regions}
+	}
+	b, err := json.MarshalIndent(js, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, append(b, '\n'), 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		failed = true
+	}
+}
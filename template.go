@@ -0,0 +1,42 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+var tmpl *template.Template
+
+// parseTemplate sets up the -template output. The flag value is read as a file if there is one at that path, otherwise it is the template itself.
+// A newline is added after each file's output unless the template already ends with one.
+func parseTemplate(t string) error {
+	if byt, err := os.ReadFile(t); err == nil {
+		t = string(byt)
+	}
+	if !strings.HasSuffix(t, "\n") {
+		t += "\n"
+	}
+	var err error
+	tmpl, err = template.New("doctool").Funcs(template.FuncMap{"join": strings.Join}).Parse(t)
+	return err
+}
+
+func executeTemplate(w io.Writer, res *Result) error {
+	return tmpl.Execute(w, res)
+}
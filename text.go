@@ -0,0 +1,843 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// writeText writes the default, human readable, report for a file
+func writeText(w io.Writer, name string, res *fields.Report, err error) {
+	fmt.Fprintln(w, header(name)) // print the file name
+	// with -verbose, a document without fields has each region listed as "none" instead
+	if err != nil && !((*verbose || !fieldReport) && err == fields.ErrNoFields) {
+		fmt.Fprintln(w, err)
+	}
+	if *triageMode {
+		fmt.Fprintf(w, "Triage: %s\n", triageSummary(triage(res, err)))
+	}
+	if filePolicy != nil {
+		fmt.Fprintf(w, "Policy: %s\n", policySummary(filePolicy.evaluate(res, err)))
+	}
+	if *volatile && res != nil && (err == nil || err == fields.ErrNoFields) {
+		fmt.Fprintf(w, "Preservation risk: %s\n", classify(res))
+	}
+	if res == nil {
+		return
+	}
+	if *verbose && res.Format == fields.FormatDOC {
+		if res.Unreferenced != "" {
+			fmt.Fprintf(w, "Table stream: %s (the document also has a %s stream, but it isn't referenced)\n", res.Table, res.Unreferenced)
+		} else {
+			fmt.Fprintf(w, "Table stream: %s\n", res.Table)
+		}
+	}
+	if *flags {
+		fmt.Fprintf(w, "FIB flags: %s\n", res.Flags)
+	}
+	if *fastSave && res.Format == fields.FormatDOC && res.Encryption == "" { // the flags of an encrypted .doc don't say
+		fmt.Fprintf(w, "Fast saved: %s\n", describeFastSave(res))
+	}
+	if *wordVersion && res.Format == fields.FormatDOC {
+		if res.Flags.Dot {
+			fmt.Fprintf(w, "Word version: %s (template)\n", res.WordVersion())
+		} else {
+			fmt.Fprintf(w, "Word version: %s\n", res.WordVersion())
+		}
+	}
+	if *sizes && res.Sizes != nil {
+		var strs []string
+		for _, key := range fields.RegionKeys() {
+			strs = append(strs, fmt.Sprintf("%s %d", key, res.Sizes[key]))
+		}
+		fmt.Fprintf(w, "Field data sizes: %s (total %d bytes)\n", strings.Join(strs, ", "), res.TotalSize)
+	}
+	if *macros {
+		if res.Macros {
+			fmt.Fprintln(w, "Macros: contains a VBA project")
+		} else {
+			fmt.Fprintln(w, "Macros: none")
+		}
+	}
+	regions := res.Regions()
+	if *verbose {
+		regions = res.AllRegions() // show that every region was examined, even those without field data
+	}
+	if !fieldReport {
+		regions = nil
+	}
+	for _, r := range regions {
+		if len(r.Fields) == 0 {
+			fmt.Fprintf(w, "%s fields: none\n", r.Name)
+		} else if *external {
+			fmt.Fprintf(w, "%s fields:\n", r.Name)
+			for _, f := range r.Occurrences {
+				target, _ := f.External()
+				if target == "" {
+					target = "(target not found)"
+				}
+				if f.Name == "include picture" && res.Pictures != nil {
+					if p, ok := res.FieldPicture(r.Key, f); ok && p.Stored() {
+						target += fmt.Sprintf(" (and stored in the document: %s, %d bytes)", p.Format, p.Size)
+					} else {
+						target += " (not stored in the document: the picture is only shown while the target can be reached)"
+					}
+				}
+				fmt.Fprintf(w, "  %s: %s\n", f.Name, target)
+			}
+		} else if *volatile {
+			fmt.Fprintf(w, "%s fields:\n", r.Name)
+			for _, f := range r.Occurrences {
+				fmt.Fprintf(w, "  %s: %s\n", f.Name, f.Class())
+			}
+		} else if *instructions || *fieldResults {
+			fmt.Fprintf(w, "%s fields:\n", r.Name)
+			for _, f := range r.Occurrences {
+				fmt.Fprintf(w, "  %s: %s\n", f.Name, withResult(f))
+			}
+		} else if *positions {
+			fmt.Fprintf(w, "%s fields: %s\n", r.Name, withPositions(r.Key, r.Occurrences))
+		} else if *raw {
+			fmt.Fprintf(w, "%s fields: %s\n", r.Name, strings.Join(r.Fields, ", "))
+		} else {
+			fmt.Fprintf(w, "%s fields: %s\n", r.Name, summarise(r.Fields))
+		}
+	}
+	if *verbose && fieldReport {
+		for _, r := range res.AllRegions() {
+			if st, ok := res.Structure[r.Key]; ok {
+				fmt.Fprintf(w, "%s structure: %d begin, %d separator, %d end; maximum nesting depth %d", r.Name, st.Begins, st.Separators, st.Ends, st.MaxDepth)
+				if st.Malformed() {
+					fmt.Fprintf(w, "; malformed: %d unclosed, %d stray end, %d stray separator, %d invalid, %d out of order", st.Unclosed, st.StrayEnds, st.StraySeparators, st.Invalid, st.Unordered)
+				}
+				fmt.Fprintln(w)
+			}
+		}
+	}
+	if (profile != nil || *external) && fieldReport && err == nil && len(res.Regions()) == 0 {
+		fmt.Fprintln(w, "No matching fields")
+	}
+	if *metadata {
+		for _, p := range res.Metadata {
+			fmt.Fprintf(w, "%s: %s\n", p.Name, p.Value)
+		}
+	}
+	if *meta {
+		writeProperties(w, res.Properties)
+		if len(res.CustomProperties) > 0 {
+			fmt.Fprintln(w, "Custom properties:")
+			for _, p := range res.CustomProperties {
+				fmt.Fprintf(w, "  %s: %s\n", p.Name, p.Value)
+			}
+		}
+	}
+	if *assoc {
+		writeAssociations(w, res.Associations)
+	}
+	if *bookmarks {
+		if len(res.Bookmarks) == 0 {
+			fmt.Fprintln(w, "Bookmarks: none")
+		} else {
+			fmt.Fprintln(w, "Bookmarks:")
+			for _, b := range res.Bookmarks {
+				fmt.Fprintf(w, "  %s: %s\n", b.Name, strings.TrimSpace(fmt.Sprintf("%s CP %d-%d", b.Region, b.Start, b.End)))
+			}
+		}
+	}
+	if *crossRefs {
+		writeCrossRefs(w, res)
+	}
+	if *comments {
+		if len(res.Comments) == 0 {
+			fmt.Fprintln(w, "Comments: none")
+		} else {
+			fmt.Fprintln(w, "Comments:")
+			for _, c := range res.Comments {
+				author := c.Author
+				if author == "" {
+					author = "(unknown author)"
+				}
+				if c.Initials != "" {
+					author += " (" + c.Initials + ")"
+				}
+				fmt.Fprintf(w, "  %s: %s\n", author, strings.Replace(c.Text, "\n", "\n    ", -1)) // indent the comment's later paragraphs
+			}
+		}
+	}
+	if *mailMerge {
+		writeMailMerge(w, res.MailMerge)
+	}
+	if *hyperlinks {
+		if len(res.Hyperlinks) == 0 {
+			fmt.Fprintln(w, "Hyperlinks: none")
+		} else {
+			fmt.Fprintln(w, "Hyperlinks:")
+			for _, h := range res.Hyperlinks {
+				link := linkString(h.Target, h.Location)
+				if stored := linkString(h.Hlink, h.HlinkLocation); stored != "" && stored != link {
+					link += " (stored hyperlink data: " + stored + ")"
+				}
+				fmt.Fprintf(w, "  %s CP %d: %s\n", h.Region, h.CP, link)
+			}
+		}
+	}
+	if *objects {
+		if len(res.Objects) == 0 {
+			fmt.Fprintln(w, "Embedded objects: none")
+		} else {
+			fmt.Fprintln(w, "Embedded objects:")
+			for _, o := range res.Objects {
+				fmt.Fprintf(w, "  %s: %s\n", o.Name, describeObject(o))
+			}
+		}
+	}
+	if *pictures {
+		writePictures(w, res)
+	}
+	if *protection {
+		writeProtection(w, res)
+	}
+	if *layoutRisk {
+		writeLayout(w, res)
+	}
+	if *controlsFlag {
+		writeControls(w, res)
+	}
+	if *dot {
+		writeTemplate(w, res)
+	}
+	if *lang {
+		writeLanguages(w, res)
+	}
+	if *revisions {
+		fmt.Fprintf(w, "Tracked changes: %s\n", describeRevisions(res.Revisions))
+	}
+	if *savedBy {
+		if len(res.SavedBy) == 0 {
+			fmt.Fprintln(w, "Saved by: none")
+		} else {
+			fmt.Fprintln(w, "Saved by:")
+			for _, sb := range res.SavedBy {
+				fmt.Fprintf(w, "  %s: %s\n", sb.Author, sb.Path)
+			}
+		}
+	}
+	writeFindings(w, res)
+}
+
+// writeFindings writes the findings of each analyzer loaded with -plugin, in the order of their names, each under a line with its name and the number found,
+// e.g. `classification findings: 1` then `  header CP 0: marked OFFICIAL, but the property says SECRET`
+func writeFindings(w io.Writer, res *fields.Report) {
+	for _, name := range fields.Analyzers() {
+		findings, ok := res.Findings[name]
+		if !ok { // the analyzer failed, which is a warning
+			continue
+		}
+		if len(findings) == 0 {
+			fmt.Fprintf(w, "%s findings: none\n", name)
+			continue
+		}
+		fmt.Fprintf(w, "%s findings: %d\n", name, len(findings))
+		for _, f := range findings {
+			if f.Region != "" {
+				fmt.Fprintf(w, "  %s CP %d: %s\n", f.Region, f.CP, f.Message)
+			} else {
+				fmt.Fprintf(w, "  %s\n", f.Message)
+			}
+		}
+	}
+}
+
+// writeMailMerge writes the mail merge details that were found, after a line saying whether the document is a main document
+func writeMailMerge(w io.Writer, m fields.MailMerge) {
+	switch {
+	case m.MainDocument:
+		fmt.Fprintln(w, "Mail merge: main document")
+	case m.Active():
+		fmt.Fprintln(w, "Mail merge: not a main document, but has mail merge data")
+	default:
+		fmt.Fprintln(w, "Mail merge: none")
+		return
+	}
+	if len(m.Fields) > 0 {
+		fmt.Fprintf(w, "  Merge fields: %s\n", strings.Join(m.Fields, ", "))
+	}
+	for _, p := range []fields.Property{{Name: "Data source", Value: m.DataSource}, {Name: "Header document", Value: m.HeaderDocument}, {Name: "Connection", Value: m.Connection}, {Name: "Query", Value: m.Query}} {
+		if p.Value != "" {
+			fmt.Fprintf(w, "  %s: %s\n", p.Name, p.Value)
+		}
+	}
+	for _, db := range m.Databases {
+		fmt.Fprintf(w, "  DATABASE field: %s\n", db)
+	}
+}
+
+// linkString joins a hyperlink's target and location as a URL would, e.g. http://example.com/page#section, or #name for a link to a bookmark
+func linkString(target, location string) string {
+	if location == "" {
+		return target
+	}
+	return target + "#" + location
+}
+
+// describeObject describes an embedded object, e.g. "Packager (Package) containing invoice.pdf.exe {0003000C-0000-0000-C000-000000000046}"
+func describeObject(o fields.Object) string {
+	desc := o.Type
+	if desc == "" {
+		desc = "unknown type"
+	}
+	if o.ProgID != "" {
+		desc += " (" + o.ProgID + ")"
+	}
+	if o.File != "" {
+		desc += " containing " + o.File
+	}
+	if o.CLSID != "" {
+		desc += " {" + o.CLSID + "}"
+	}
+	return desc
+}
+
+// labelled is a value with the label it is printed with
+type labelled struct{ label, value string }
+
+// propertyList gives the document properties, labelled, in the order they are printed
+func propertyList(dp fields.DocProperties) []labelled {
+	return []labelled{
+		{"Title", dp.Title},
+		{"Subject", dp.Subject},
+		{"Author", dp.Author},
+		{"Last saved by", dp.LastSavedBy},
+		{"Created", dp.Created},
+		{"Last saved", dp.Modified},
+		{"Application", dp.Application},
+		{"Template", dp.Template},
+		{"Company", dp.Company},
+	}
+}
+
+// writeProperties writes the document properties that were recorded, one per line
+func writeProperties(w io.Writer, dp fields.DocProperties) {
+	var found bool
+	for _, p := range propertyList(dp) {
+		if p.value != "" {
+			fmt.Fprintf(w, "%s: %s\n", p.label, p.value)
+			found = true
+		}
+	}
+	if !found {
+		fmt.Fprintln(w, "Document properties: none")
+	}
+}
+
+// describeFastSave says whether a .doc was fast saved, e.g. "yes (2 consecutive fast saves), 14 pieces of text"
+func describeFastSave(res *fields.Report) string {
+	saved := "no"
+	if res.Flags.Complex && res.Flags.QuickSaves > 0 && res.Flags.QuickSaves < 15 { // 15 (0xF) means the count isn't recorded, which is what later versions of Word always write
+		saved = fmt.Sprintf("yes (%d consecutive fast saves)", res.Flags.QuickSaves)
+	} else if res.Flags.Complex {
+		saved = "yes"
+	}
+	switch res.Pieces {
+	case 0:
+		return saved + ", piece table unreadable"
+	case 1:
+		return saved + ", 1 piece of text"
+	}
+	return fmt.Sprintf("%s, %d pieces of text", saved, res.Pieces)
+}
+
+// writeLanguages writes the languages of a .doc, one kind per line
+func writeLanguages(w io.Writer, res *fields.Report) {
+	if res.Format != fields.FormatDOC {
+		fmt.Fprintln(w, "Languages: not recorded (only .doc files are read for languages)")
+		return
+	}
+	l := res.Languages
+	if len(l.Text) == 0 {
+		fmt.Fprintln(w, "Languages of the text: unknown")
+	} else {
+		strs := make([]string, len(l.Text))
+		for i, lc := range l.Text {
+			strs[i] = fmt.Sprintf("%s %d characters", fields.LanguageName(lc.LID), lc.Chars)
+		}
+		fmt.Fprintf(w, "Languages of the text: %s\n", strings.Join(strs, ", "))
+	}
+	if l.Default != 0 {
+		fmt.Fprintf(w, "Default language (Normal style): %s\n", fields.LanguageName(l.Default))
+	}
+	fmt.Fprintf(w, "Install language (FIB): %s\n", fields.LanguageName(l.Install))
+	if l.FarEast != 0 {
+		fmt.Fprintf(w, "East Asian install language (FIB): %s\n", fields.LanguageName(l.FarEast))
+	}
+}
+
+// writeAssociations writes the files and people the document is associated with, one per line
+func writeAssociations(w io.Writer, a fields.Associations) {
+	assocs := []struct{ label, value string }{
+		{"Attached template", a.Template},
+		{"Mail merge data source", a.DataSource},
+		{"Mail merge header document", a.HeaderDocument},
+		{"Author (SttbfAssoc)", a.Author},
+		{"Last saved by (SttbfAssoc)", a.LastSavedBy},
+	}
+	var found bool
+	for _, as := range assocs {
+		if as.value != "" {
+			fmt.Fprintf(w, "%s: %s\n", as.label, as.value)
+			found = true
+		}
+	}
+	if !found {
+		fmt.Fprintln(w, "Associations: none")
+	}
+}
+
+// writeProtection writes how the document is protected, e.g. "Protection: forms, read-only recommended", then, for a form, its form fields
+func writeProtection(w io.Writer, res *fields.Report) {
+	if res.Format != fields.FormatDOC || res.Encryption != "" {
+		fmt.Fprintln(w, "Protection: not read (only .doc files that aren't encrypted are read for protection)")
+		return
+	}
+	p := res.Protection
+	var strs []string
+	for _, s := range []struct {
+		set  bool
+		desc string
+	}{
+		{p.Forms, "forms (only form fields can be filled in)"},
+		{p.Comments, "comments (only comments can be added)"},
+		{p.Revisions, "tracked changes (changes are always tracked)"},
+		{p.ReadOnlyRecommended, "read-only recommended"},
+		{p.WriteReservation, "password to modify"},
+	} {
+		if s.set {
+			strs = append(strs, s.desc)
+		}
+	}
+	if len(strs) == 0 {
+		strs = []string{"none"}
+	}
+	fmt.Fprintf(w, "Protection: %s\n", strings.Join(strs, ", "))
+	if !p.Forms {
+		return
+	}
+	if len(res.FormFields) == 0 {
+		fmt.Fprintln(w, "Form fields: none")
+		return
+	}
+	fmt.Fprintln(w, "Form fields:")
+	for _, ff := range res.FormFields {
+		fmt.Fprintf(w, "  %s CP %d: %s\n", ff.Region, ff.CP, describeFormField(ff))
+	}
+}
+
+// writeCrossRefs writes the number of cross-reference fields and of those that are dangling, then lists each with the bookmark it refers to, e.g. `body CP 40: PAGEREF "_Ref123" -> body CP 10-24`
+func writeCrossRefs(w io.Writer, res *fields.Report) {
+	refs, resolved := res.CrossReferences()
+	if len(refs) == 0 {
+		fmt.Fprintln(w, "Cross-references: none")
+		return
+	}
+	if !resolved {
+		fmt.Fprintf(w, "Cross-references: %d (targets not checked: doctool reads the bookmarks of a .doc from Word 97 on only)\n", len(refs))
+	} else {
+		var dangling int
+		for _, x := range refs {
+			if x.Target == nil {
+				dangling++
+			}
+		}
+		fmt.Fprintf(w, "Cross-references: %d, dangling: %d\n", len(refs), dangling)
+	}
+	for _, x := range refs {
+		desc := fmt.Sprintf("%s %q", x.Type, x.Bookmark)
+		switch {
+		case !resolved:
+		case x.Target != nil:
+			desc += " -> " + strings.TrimSpace(fmt.Sprintf("%s CP %d-%d", x.Target.Region, x.Target.Start, x.Target.End))
+		case x.Bookmark == "":
+			desc = x.Type + ": dangling (no bookmark given)"
+		default:
+			desc += ": dangling"
+		}
+		fmt.Fprintf(w, "  %s CP %d: %s\n", x.Region, x.CP, desc)
+	}
+}
+
+// moveNames describes the switches of an ADVANCE field
+var moveNames = map[string]string{"d": "down %s pt", "u": "up %s pt", "l": "left %s pt", "r": "right %s pt", "x": "to %s pt from the left edge of the column", "y": "to %s pt from the top of the line"}
+
+// writeLayout writes the number of fields of each type that draw or move text, then describes each, e.g. `body CP 12: symbol 0xF0E0 (Unicode) in "Wingdings", 12 pt`
+func writeLayout(w io.Writer, res *fields.Report) {
+	counts := make(map[string]int)
+	var names, lines []string
+	for _, r := range res.Regions() {
+		for _, f := range r.Occurrences {
+			l, ok := f.Layout()
+			if !ok {
+				continue
+			}
+			if counts[f.Name] == 0 {
+				names = append(names, f.Name)
+			}
+			counts[f.Name]++
+			lines = append(lines, fmt.Sprintf("  %s CP %d: %s", r.Key, f.CP, describeLayout(f, l)))
+		}
+	}
+	if len(lines) == 0 {
+		fmt.Fprintln(w, "Layout risk: none")
+		return
+	}
+	sort.Slice(names, func(i, j int) bool { return lessName(names[i], names[j]) })
+	strs := make([]string, len(names))
+	for i, n := range names {
+		strs[i] = fmt.Sprintf("%s (%d)", n, counts[n])
+	}
+	fmt.Fprintf(w, "Layout risk: %s\n", strings.Join(strs, ", "))
+	for _, l := range lines {
+		fmt.Fprintln(w, l)
+	}
+}
+
+// describeLayout describes what a layout-affecting field draws or moves, e.g. `advance up 3 pt, right 10 pt` or `eq fraction, radical: EQ \f(1,\r(2))`
+func describeLayout(f fields.Field, l fields.Layout) string {
+	switch f.Name {
+	case "symbol":
+		desc := "symbol"
+		if l.Char != 0 {
+			desc += fmt.Sprintf(" 0x%04X", l.Char)
+		} else {
+			desc += " (character code can't be read)"
+		}
+		desc += " (" + l.Encoding + ")"
+		if l.Font != "" {
+			desc += fmt.Sprintf(" in %q", l.Font)
+		}
+		if l.Size != "" {
+			desc += ", " + l.Size + " pt"
+		}
+		return desc
+	case "advance":
+		if len(l.Moves) == 0 {
+			return "advance (no moves)"
+		}
+		strs := make([]string, len(l.Moves))
+		for i, m := range l.Moves {
+			strs[i] = fmt.Sprintf(moveNames[m.Switch], m.Points)
+		}
+		return "advance " + strings.Join(strs, ", ")
+	}
+	strs := make([]string, len(l.Switches))
+	for i, s := range l.Switches {
+		strs[i] = fields.EQSwitches[s]
+	}
+	if len(strs) == 0 {
+		strs = []string{"no switches"}
+	}
+	return "eq " + strings.Join(strs, ", ") + ": " + f.Instruction
+}
+
+// writeControls writes the number of ActiveX controls in the document, then describes each, e.g. "_1234567890: CommandButton1, Microsoft Forms 2.0 CommandButton (Forms.CommandButton.1) {D7053240-CE69-11CD-A777-00DD01143C57}"
+func writeControls(w io.Writer, res *fields.Report) {
+	if res.Format != fields.FormatDOC || res.Encryption != "" {
+		fmt.Fprintln(w, "ActiveX controls: not read (only .doc files that aren't encrypted are read for controls)")
+		return
+	}
+	cs := res.Controls()
+	if len(cs) == 0 {
+		fmt.Fprintln(w, "ActiveX controls: none")
+		return
+	}
+	fmt.Fprintf(w, "ActiveX controls: %d\n", len(cs))
+	for _, c := range cs {
+		desc := describeObject(c)
+		if c.ControlName != "" {
+			desc = c.ControlName + ", " + desc
+		}
+		fmt.Fprintf(w, "  %s: %s\n", c.Name, desc)
+	}
+}
+
+// writeTemplate writes what a template carries for the documents based on it, e.g. "Template: macros (a VBA project), key assignments: 2",
+// then its AutoText entries, each with the fields in it
+func writeTemplate(w io.Writer, res *fields.Report) {
+	switch {
+	case res.Format != fields.FormatDOC || res.Encryption != "":
+		fmt.Fprintln(w, "Template: not read (only .doc files that aren't encrypted are read as templates)")
+		return
+	case !res.Flags.Dot:
+		fmt.Fprintln(w, "Template: not a template")
+		return
+	case res.Template == nil:
+		fmt.Fprintln(w, "Template: not read (only templates from Word 97 on are read for AutoText and customisations)")
+		return
+	}
+	c := res.Template.Customizations
+	var strs []string
+	if res.Macros {
+		strs = append(strs, "macros (a VBA project)")
+	}
+	if c.KeyAssignments > 0 {
+		strs = append(strs, fmt.Sprintf("key assignments: %d", c.KeyAssignments))
+	}
+	if c.MacroCommands > 0 {
+		strs = append(strs, fmt.Sprintf("menu and toolbar commands that run macros: %d", c.MacroCommands))
+	}
+	if len(c.MacroNames) > 0 {
+		strs = append(strs, "commands for the macros "+strings.Join(c.MacroNames, ", "))
+	}
+	if c.Toolbars {
+		strs = append(strs, "customised toolbars or menus")
+	}
+	if len(strs) == 0 {
+		strs = []string{"no macros or customisations"}
+	}
+	fmt.Fprintf(w, "Template: %s\n", strings.Join(strs, ", "))
+	if len(res.Template.AutoText) == 0 {
+		fmt.Fprintln(w, "AutoText entries: none")
+		return
+	}
+	fmt.Fprintln(w, "AutoText entries:")
+	for _, a := range res.Template.AutoText {
+		names := make([]string, len(a.Fields))
+		for i, f := range a.Fields {
+			names[i] = f.Name
+		}
+		desc := summarise(names)
+		if desc == "" {
+			desc = "no fields"
+		}
+		fmt.Fprintf(w, "  %q: %s\n", a.Name, desc)
+	}
+}
+
+// describeFormField describes a form field's settings, e.g. `form dropdown "Colour", default "Red" (Red, Green, Blue)`
+func describeFormField(ff fields.FormField) string {
+	desc := ff.Type
+	if ff.Name != "" {
+		desc += fmt.Sprintf(" %q", ff.Name)
+	}
+	switch {
+	case ff.Type == "form checkbox" && ff.Default != "":
+		desc += ", default " + ff.Default
+	case ff.Default != "":
+		desc += fmt.Sprintf(", default %q", ff.Default)
+	}
+	if len(ff.Entries) > 0 {
+		desc += " (" + strings.Join(ff.Entries, ", ") + ")"
+	}
+	if ff.EntryMacro != "" {
+		desc += ", runs " + ff.EntryMacro + " on entry"
+	}
+	if ff.ExitMacro != "" {
+		desc += ", runs " + ff.ExitMacro + " on exit"
+	}
+	return desc
+}
+
+// writePictures writes the count of each format of picture stored in the document, then describes each picture, e.g. "body CP 12: PNG, 10240 bytes"
+func writePictures(w io.Writer, res *fields.Report) {
+	if res.Pictures == nil {
+		fmt.Fprintln(w, "Pictures: not read (only .doc files from Word 97 on are read for pictures)")
+		return
+	}
+	if len(res.Pictures) == 0 {
+		fmt.Fprintln(w, "Pictures: none")
+		return
+	}
+	counts := make(map[string]int)
+	var formats []string
+	var total uint64
+	var missing, damaged int
+	for _, p := range res.Pictures {
+		if p.Damaged {
+			damaged++
+			continue
+		}
+		if !p.Stored() {
+			missing++
+			continue
+		}
+		if counts[p.Format] == 0 {
+			formats = append(formats, p.Format)
+		}
+		counts[p.Format]++
+		total += uint64(p.Size)
+	}
+	sort.SliceStable(formats, func(i, j int) bool { return counts[formats[i]] > counts[formats[j]] })
+	strs := make([]string, len(formats))
+	for i, f := range formats {
+		if f == "" {
+			f = "unknown format"
+		}
+		strs[i] = fmt.Sprintf("%d %s", counts[formats[i]], f)
+	}
+	desc := fmt.Sprintf("%d stored", len(res.Pictures)-missing-damaged)
+	if len(strs) > 0 {
+		desc += fmt.Sprintf(" (%s; %d bytes)", strings.Join(strs, ", "), total)
+	}
+	if missing > 0 {
+		desc += fmt.Sprintf(", %d not stored", missing)
+	}
+	if damaged > 0 {
+		desc += fmt.Sprintf(", %d damaged", damaged)
+	}
+	fmt.Fprintf(w, "Pictures: %s\n", desc)
+	for _, p := range res.Pictures {
+		fmt.Fprintf(w, "  %s CP %d: %s\n", p.Region, p.CP, describePicture(p))
+	}
+}
+
+// describePicture describes a picture's stored image and where it comes from, e.g. "PNG, 10240 bytes, linked to C:\logo.png, the result of the INCLUDEPICTURE field at CP 3"
+func describePicture(p fields.Picture) string {
+	var desc string
+	switch {
+	case p.Damaged:
+		desc = "damaged (the picture data runs past the end of the Data stream or is malformed)"
+	case !p.Stored():
+		desc = "not stored in the document"
+	case p.Format == "":
+		desc = fmt.Sprintf("unknown format, %d bytes", p.Size)
+	default:
+		desc = fmt.Sprintf("%s, %d bytes", p.Format, p.Size)
+	}
+	if p.Link != "" {
+		desc += ", linked to " + p.Link
+	}
+	if p.Included {
+		desc += fmt.Sprintf(", the result of the INCLUDEPICTURE field at CP %d", p.Field)
+	}
+	return desc
+}
+
+// describeRevisions summarises the tracked changes, e.g. "4 inserted and 1 deleted runs of text, by Richard, Ross (change tracking is on)"
+func describeRevisions(rev fields.Revisions) string {
+	desc := "none"
+	if rev.Pending() {
+		desc = fmt.Sprintf("%d inserted and %d deleted runs of text", rev.Insertions, rev.Deletions)
+		if len(rev.Authors) > 0 {
+			desc += ", by " + strings.Join(rev.Authors, ", ")
+		}
+	}
+	if rev.Tracking {
+		desc += " (change tracking is on)"
+	}
+	return desc
+}
+
+// writeFIBDetails writes the values read from the FIB, for debugging documents that give surprising results
+func writeFIBDetails(w io.Writer, name string, res *fields.Report) {
+	which := 0
+	if res.Flags.WhichTblStm {
+		which = 1
+	}
+	var fcLcb string
+	if res.CbRgFcLcb > 0 { // Word 6.0 and Word 95 FIBs don't have one
+		fcLcb = fmt.Sprintf(", cbRgFcLcb 0x%04X (%s)", res.CbRgFcLcb, fields.FcLcbVersion(res.CbRgFcLcb))
+	}
+	if res.NFibNew != 0 {
+		fcLcb += fmt.Sprintf(", nFibNew 0x%04X (%s)", res.NFibNew, fields.Version(res.NFibNew))
+	}
+	fmt.Fprintf(w, "%s: nFib 0x%04X (%s), nFibBack 0x%04X%s, fWhichTblStm %d, table stream %s (%d bytes)\n", name, res.NFib, fields.Version(res.NFib), res.NFibBack, fcLcb, which, res.Table, res.TableSize)
+	for _, key := range fields.RegionKeys() {
+		if off, ok := res.Offsets[key]; ok {
+			fmt.Fprintf(w, "%s: %s field data at offset %d, length %d\n", name, key, off, res.Sizes[key])
+		}
+	}
+}
+
+// withResult gives a field's instruction (with -instructions) and its result text (with -results), e.g. `DATE \@ "d MMMM yyyy" = "14 October 2026"`.
+// A field without a separator has no result.
+func withResult(f fields.Field) string {
+	if !*fieldResults {
+		return f.Instruction
+	}
+	result := "(no result)"
+	if f.Separator != 0 {
+		result = fmt.Sprintf("%q", f.Result)
+	}
+	if !*instructions {
+		return result
+	}
+	return f.Instruction + " = " + result
+}
+
+// withPositions lists fields in document order along with the CPs of their begin and end characters, e.g. "date (CP 12-40), page (CP 407-419)".
+// Only the begin is given for a field without an end.
+func withPositions(key string, occs []fields.Field) string {
+	strs := make([]string, len(occs))
+	for i, f := range occs {
+		pos := fmt.Sprintf("CP %d-%d", f.CP, f.End)
+		if f.End == 0 {
+			pos = fmt.Sprintf("CP %d", f.CP)
+		}
+		switch {
+		case f.Story == 0:
+		case key == "footnote" || key == "endnote":
+			pos += fmt.Sprintf(", %s %d referenced at CP %d", key, f.Story, f.Anchor)
+		case f.Anchored:
+			pos += fmt.Sprintf(", textbox %d anchored at CP %d", f.Story, f.Anchor)
+		default:
+			pos += fmt.Sprintf(", textbox %d", f.Story)
+		}
+		strs[i] = fmt.Sprintf("%s (%s)", f.Name, pos)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// lessName orders field names alphabetically, ignoring case, with the exact names as a tie-break so the order is always the same
+func lessName(a, b string) bool {
+	if la, lb := strings.ToLower(a), strings.ToLower(b); la != lb {
+		return la < lb
+	}
+	return a < b
+}
+
+// sortFields returns a copy of the fields in name order (see lessName), with fields of the same type in document order
+func sortFields(occs []fields.Field) []fields.Field {
+	sorted := append([]fields.Field(nil), occs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return lessName(sorted[i].Name, sorted[j].Name)
+		}
+		return sorted[i].CP < sorted[j].CP
+	})
+	return sorted
+}
+
+// summarise lists each field type once, in alphabetical order, with the number of times it occurs, e.g. "hyperlink (50), ref (3), TOC (1)".
+// The order doesn't depend on where fields are in the document, so output can be compared across runs and documents.
+func summarise(fields []string) string {
+	counts := make(map[string]int)
+	var names []string
+	for _, f := range fields {
+		if counts[f] == 0 {
+			names = append(names, f)
+		}
+		counts[f]++
+	}
+	sort.Slice(names, func(i, j int) bool { return lessName(names[i], names[j]) })
+	strs := make([]string, len(names))
+	for i, n := range names {
+		strs[i] = fmt.Sprintf("%s (%d)", n, counts[n])
+	}
+	return strings.Join(strs, ", ")
+}
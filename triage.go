@@ -0,0 +1,96 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// risk levels for -triage, in increasing order
+const (
+	riskNone = iota
+	riskMedium
+	riskHigh
+)
+
+var riskNames = []string{"none", "medium", "high"}
+
+// indicator is something about a document that warrants a closer look before it is opened
+type indicator struct {
+	risk int
+	desc string
+}
+
+// suspicious is set if -triage finds any indicators, so that doctool exits with exitTriage
+var suspicious bool
+
+// triage checks a document for the signs of a malicious document: DDE fields (DDEAUTO runs its command when the document is opened),
+// a VBA project, packaged files (embedded Packager objects), and encryption or obfuscation (which hide the rest of the document from inspection).
+// It returns the highest risk of the indicators found, along with the indicators.
+func triage(res *fields.Report, err error) (int, []indicator) {
+	var inds []indicator
+	if res != nil {
+		for _, r := range res.Regions() {
+			for _, f := range r.Fields {
+				switch f {
+				case "dde auto":
+					inds = append(inds, indicator{riskHigh, "DDEAUTO field in " + r.Name})
+				case "dde":
+					inds = append(inds, indicator{riskMedium, "DDE field in " + r.Name})
+				}
+			}
+		}
+		if res.Macros {
+			inds = append(inds, indicator{riskHigh, "VBA project (macros)"})
+		}
+		for _, o := range res.Objects {
+			if o.Packaged() { // the Packager can wrap an executable or script, which runs when the object is opened
+				desc := "embedded Packager object"
+				if o.File != "" {
+					desc += fmt.Sprintf(" (%q)", o.File)
+				}
+				inds = append(inds, indicator{riskMedium, desc})
+			}
+		}
+		if res.Encryption != "" {
+			inds = append(inds, indicator{riskMedium, "encrypted (" + res.Encryption + ")"})
+		}
+	} else if errors.Is(err, fields.ErrEncrypted) {
+		inds = append(inds, indicator{riskMedium, "encrypted"})
+	}
+	risk := riskNone
+	for _, ind := range inds {
+		if ind.risk > risk {
+			risk = ind.risk
+		}
+	}
+	return risk, inds
+}
+
+// triageSummary describes the result of triage, e.g. "high risk: DDEAUTO field in Document body; VBA project (macros)"
+func triageSummary(risk int, inds []indicator) string {
+	if risk == riskNone {
+		return "no risk indicators"
+	}
+	descs := make([]string, len(inds))
+	for i, ind := range inds {
+		descs[i] = ind.desc
+	}
+	return fmt.Sprintf("%s risk: %s", riskNames[risk], strings.Join(descs, "; "))
+}
@@ -0,0 +1,51 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// unknownCodes records, for each field code doctool has no name for, the files it was found in (used by -fail-on-unknown)
+var unknownCodes = make(map[byte][]string)
+
+func trackUnknown(name string, codes []byte) {
+	seen := make(map[byte]bool)
+	for _, c := range codes {
+		if !seen[c] {
+			unknownCodes[c] = append(unknownCodes[c], name)
+			seen[c] = true
+		}
+	}
+}
+
+// reportUnknown lists the unknown field codes seen during the run, returning false if there were none
+func reportUnknown(w io.Writer) bool {
+	if len(unknownCodes) == 0 {
+		return false
+	}
+	codes := make([]int, 0, len(unknownCodes))
+	for c := range unknownCodes {
+		codes = append(codes, int(c))
+	}
+	sort.Ints(codes)
+	for _, c := range codes {
+		fmt.Fprintf(w, "unknown field code 0x%02X in: %s\n", c, strings.Join(unknownCodes[byte(c)], ", "))
+	}
+	return true
+}
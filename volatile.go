@@ -0,0 +1,64 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// preservation counts a document's fields by class for -volatile
+type preservation struct {
+	static, volatile, external, interactive int
+}
+
+// classify counts the static, volatile, external and interactive fields in every region
+func classify(res *fields.Report) preservation {
+	var p preservation
+	if res == nil {
+		return p
+	}
+	for _, r := range res.Regions() {
+		for _, f := range r.Occurrences {
+			switch f.Class() {
+			case fields.Volatile:
+				p.volatile++
+			case fields.External:
+				p.external++
+			case fields.Interactive:
+				p.interactive++
+			default:
+				p.static++
+			}
+		}
+	}
+	return p
+}
+
+// score is the preservation risk of the document: one point for each volatile field, and two for each external one (whose content may not be there at all when the document is next opened).
+// A document scoring 0 renders the same wherever and whenever it is opened; higher scores are the better candidates for normalising to PDF.
+// Interactive fields (an interactive form) don't count: they render as they were left, but normalising loses what makes them a form, so they are a separate category.
+func (p preservation) score() int {
+	return p.volatile + 2*p.external
+}
+
+func (p preservation) String() string {
+	s := fmt.Sprintf("%d (%d volatile, %d external, %d static)", p.score(), p.volatile, p.external, p.static)
+	if p.interactive > 0 {
+		s += fmt.Sprintf("; interactive form fields, buttons and controls: %d", p.interactive)
+	}
+	return s
+}
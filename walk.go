@@ -0,0 +1,236 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// skip reports whether a file found while walking a directory is obviously not worth opening:
+// hidden files (e.g. .DS_Store), Word's ~$ lock files, and files without one of the -ext suffixes, or, if -ext isn't given, without a Word extension (see isDoc).
+// With -ext '*', every other file is opened.
+func skip(name string) bool {
+	base := filepath.Base(name)
+	if strings.HasPrefix(base, ".") || strings.HasPrefix(base, "~$") {
+		return true
+	}
+	switch *ext {
+	case "":
+		return !isDoc(base)
+	case "*":
+		return false
+	}
+	for _, e := range strings.Split(*ext, ",") {
+		if strings.EqualFold(filepath.Ext(base), strings.TrimSpace(e)) {
+			return false
+		}
+	}
+	return true
+}
+
+// glob expands any arguments that contain glob patterns (for shells, like cmd.exe, that pass *.doc through unexpanded).
+// An argument that names an existing file is left alone even if it contains metacharacters.
+// A ** path element matches any number of directories (see globStar), so **/*.doc finds .doc files at any depth.
+// Patterns that match nothing are reported and dropped.
+func glob(ins []string) []string {
+	var out []string
+	for _, in := range ins {
+		if !strings.ContainsAny(in, "*?[") {
+			out = append(out, in)
+			continue
+		}
+		if _, err := os.Stat(in); err == nil {
+			out = append(out, in)
+			continue
+		}
+		var matches []string
+		var err error
+		if strings.Contains(in, "**") {
+			matches, err = globStar(in)
+		} else {
+			matches, err = filepath.Glob(in)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bad pattern %s: %v\n", in, err)
+			failed = true
+			continue
+		}
+		if len(matches) == 0 {
+			fmt.Fprintf(os.Stderr, "no files match %s\n", in)
+			failed = true
+			continue
+		}
+		out = append(out, matches...)
+	}
+	return out
+}
+
+// globStar expands a pattern with ** path elements by walking the directory that its elements before the first metacharacter name
+// (or the current directory), matching the path of each file beneath it element by element. Hidden directories aren't walked into, as with -r.
+func globStar(pattern string) ([]string, error) {
+	base := ""
+	if i := strings.LastIndexAny(pattern[:strings.IndexAny(pattern, "*?[")], `/`+string(filepath.Separator)); i >= 0 {
+		base = pattern[:i+1]
+	}
+	elems := splitPath(pattern[len(base):])
+	for _, e := range elems {
+		if _, err := filepath.Match(e, ""); err != nil {
+			return nil, err
+		}
+	}
+	root := base
+	if root == "" {
+		root = "."
+	}
+	var matches []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // unreadable parts of the tree just don't match
+		}
+		if path == root {
+			return nil
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err == nil && matchElems(elems, splitPath(rel)) {
+			matches = append(matches, filepath.Join(base, rel))
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// splitPath splits a path into its elements, at either kind of separator on Windows
+func splitPath(p string) []string {
+	return strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == filepath.Separator })
+}
+
+// matchElems reports whether the elements of a path match those of a pattern, where a ** element matches any number of path elements (including none)
+func matchElems(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchElems(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(pattern[0], path[0])
+	return ok && matchElems(pattern[1:], path[1:])
+}
+
+// inputs returns the files named by the arguments, with their glob patterns expanded (see glob), followed by those listed in the -from-file list, which are taken as they are
+func inputs(args []string) []string {
+	ins := glob(args)
+	if *fromFile == "" {
+		return ins
+	}
+	listed, err := readList(*fromFile)
+	if err != nil {
+		fatal(err)
+	}
+	return append(ins, listed...)
+}
+
+// readList reads the paths in a -from-file list (or stdin, if the name is "-"): one per line, or separated by NULs (as written by find -print0) if there are any.
+// Empty lines, and the carriage returns of Windows line ends, are dropped.
+func readList(name string) ([]string, error) {
+	var b []byte
+	var err error
+	if name == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	sep := "\n"
+	if bytes.IndexByte(b, 0) >= 0 {
+		sep = "\x00"
+	}
+	var paths []string
+	for _, p := range strings.Split(string(b), sep) {
+		if sep == "\n" {
+			p = strings.TrimSuffix(p, "\r")
+		}
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+// expand replaces any directories in the inputs with the files beneath them (when -r is set).
+// Symlinks to files are processed but symlinked directories aren't followed, so the walk can't loop.
+// Errors reading part of a tree are reported and the walk carries on.
+func expand(ins []string) []string {
+	if !*recursive {
+		return ins
+	}
+	var files []string
+	for _, in := range ins {
+		info, err := os.Stat(in)
+		if err != nil || !info.IsDir() {
+			files = append(files, in) // let process report any error
+			continue
+		}
+		filepath.WalkDir(in, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, wrapError(err))
+				return nil
+			}
+			if d.IsDir() {
+				if path != in && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if skip(path) {
+				if logger != nil {
+					logger.Debug("skipped", "file", path)
+				}
+				return nil
+			}
+			if d.Type()&fs.ModeSymlink != 0 {
+				if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+					return nil
+				}
+			} else if !d.Type().IsRegular() {
+				return nil
+			}
+			files = append(files, path)
+			return nil
+		})
+	}
+	return files
+}
@@ -0,0 +1,151 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// the flags that only watch has
+var (
+	watchSettle   *time.Duration
+	watchExisting *bool
+)
+
+// watchFlags defines the flags that only watch has
+func watchFlags(fs *flag.FlagSet) {
+	watchSettle = fs.Duration("settle", 2*time.Second, "how long a file must go unchanged before it is processed, so that it isn't read while it is still being copied in")
+	watchExisting = fs.Bool("existing", false, "process the documents already in the folders first")
+}
+
+// watchCommand runs `doctool watch [-settle 2s] [-existing] folder ...`, which processes each word doc that is added to (or changed in) the folders, until interrupted.
+// The results go to the output chosen by the other flags, e.g. -json, -csv or -sqlite, which is flushed after each file.
+func watchCommand(args []string) error {
+	if len(args) < 1 {
+		return errors.New("Missing required argument: the folder to watch")
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	pending := make(map[string]time.Time) // the files that have changed, and when they last changed
+	for _, dir := range args {
+		if err := addWatch(w, dir, pending, *watchExisting); err != nil {
+			return err
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	interval := *watchSettle / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	fmt.Fprintf(os.Stderr, "Watching %s (Ctrl-C to stop)\n", strings.Join(args, ", "))
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, wrapError(err))
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+				if ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 { // gone, so don't report it; a change of permissions alone leaves it pending
+					delete(pending, ev.Name)
+				}
+				continue
+			}
+			if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+				if *recursive && ev.Op&fsnotify.Create != 0 && !strings.HasPrefix(filepath.Base(ev.Name), ".") {
+					if err := addWatch(w, ev.Name, pending, true); err != nil { // a folder moved in may already have documents in it
+						fmt.Fprintln(os.Stderr, wrapError(err))
+					}
+				}
+				continue
+			}
+			if watched(ev.Name) {
+				pending[ev.Name] = time.Now()
+			}
+		case now := <-tick.C:
+			var ready []string
+			for name, t := range pending {
+				if now.Sub(t) >= *watchSettle {
+					ready = append(ready, name)
+				}
+			}
+			sort.Strings(ready)
+			for _, name := range ready {
+				delete(pending, name)
+				res, fix, err := process(ctx, name)
+				if err != nil && err == ctx.Err() {
+					return nil
+				}
+				output(name, fix, res, err)
+				flushCSV()
+				if *sqliteOut != "" {
+					commitDB()
+				}
+				if stopped {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// addWatch watches a folder (and, with -r, the folders beneath it), adding the documents already in them to pending if existing is set
+func addWatch(w *fsnotify.Watcher, dir string, pending map[string]time.Time, existing bool) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			if existing && d.Type().IsRegular() && watched(path) {
+				pending[path] = time.Time{}
+			}
+			return nil
+		}
+		if path != dir && (!*recursive || strings.HasPrefix(d.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		return w.Add(path)
+	})
+}
+
+// watched reports whether a file in a watched folder should be processed: one with an -ext suffix if that is given, or else a word doc (see isDoc),
+// but not a hidden file or a lock file (see skip)
+func watched(name string) bool {
+	return !skip(name)
+}
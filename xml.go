@@ -0,0 +1,220 @@
+// Copyright 2015 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/ross-spencer/doctool/fields"
+)
+
+// xmlNamespace is the namespace of the -xml output, which is described by doctool.xsd
+const xmlNamespace = "https://github.com/ross-spencer/doctool/schema/1"
+
+type xmlFile struct {
+	XMLName        xml.Name           `xml:"https://github.com/ross-spencer/doctool/schema/1 file"` // each file element declares the namespace, so that it can be copied into a METS or PREMIS document on its own
+	Path           string             `xml:"path,attr"`
+	Status         string             `xml:"status,attr"`
+	Identification *xmlIdentification `xml:"identification,omitempty"`
+	Fixity         *xmlFixity         `xml:"fixity,omitempty"`
+	Properties     *xmlProperties     `xml:"properties,omitempty"`
+	Custom         *xmlCustom         `xml:"customProperties,omitempty"`
+	Policy         *xmlPolicy         `xml:"policy,omitempty"`
+	Sections       *xmlSections       `xml:"sections,omitempty"`
+	Errors         *xmlErrors         `xml:"errors,omitempty"`
+	Warnings       *xmlWarnings       `xml:"warnings,omitempty"`
+}
+
+// the wrapper elements are pointers so that they are left out, rather than written empty, when there is nothing in them
+type xmlSections struct {
+	Section []xmlSection `xml:"section"`
+}
+
+type xmlErrors struct {
+	Error []string `xml:"error"`
+}
+
+type xmlWarnings struct {
+	Warning []string `xml:"warning"`
+}
+
+type xmlIdentification struct {
+	Format     string `xml:"format,attr"`
+	Version    string `xml:"version,attr,omitempty"`
+	NFib       string `xml:"nfib,attr,omitempty"`
+	Table      string `xml:"table,attr,omitempty"`
+	Encryption string `xml:"encryption,attr,omitempty"`
+	Macros     bool   `xml:"macros,attr"`
+	Template   *bool  `xml:"template,attr,omitempty"` // .doc only: whether the document is a template (fDot)
+}
+
+// xmlFixity is the file's checksum in hex, with its algorithm and the file's size, as for a PREMIS fixity
+type xmlFixity struct {
+	Algorithm string `xml:"algorithm,attr"`
+	Size      int64  `xml:"size,attr"`
+	Digest    string `xml:",chardata"`
+}
+
+type xmlProperties struct {
+	Title       string `xml:"title,omitempty"`
+	Subject     string `xml:"subject,omitempty"`
+	Author      string `xml:"author,omitempty"`
+	LastSavedBy string `xml:"lastSavedBy,omitempty"`
+	Created     string `xml:"created,omitempty"`
+	Modified    string `xml:"modified,omitempty"`
+	Application string `xml:"application,omitempty"`
+	Template    string `xml:"template,omitempty"`
+	Company     string `xml:"company,omitempty"`
+}
+
+type xmlCustom struct {
+	Property []xmlCustomProperty `xml:"property"`
+}
+
+type xmlCustomProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlPolicy struct {
+	Verdict string          `xml:"verdict,attr"`
+	Rules   []xmlPolicyRule `xml:"rule"` // the rules the file matched
+}
+
+type xmlPolicyRule struct {
+	Name    string `xml:"name,attr"`
+	Verdict string `xml:"verdict,attr"`
+}
+
+type xmlSection struct {
+	Name   string     `xml:"name,attr"`  // as in the -json keys, e.g. body
+	Label  string     `xml:"label,attr"` // e.g. Document body
+	Offset *uint32    `xml:"offset,attr,omitempty"`
+	Size   *uint32    `xml:"size,attr,omitempty"`
+	Fields []xmlField `xml:"field"`
+}
+
+type xmlField struct {
+	Type        string  `xml:"type,attr"`
+	Code        string  `xml:"code,attr,omitempty"`
+	Keyword     string  `xml:"keyword,attr,omitempty"` // from the registry of field types, for a type doctool knows
+	Class       string  `xml:"class,attr,omitempty"`
+	CP          uint32  `xml:"cp,attr"`
+	End         uint32  `xml:"end,attr"`
+	Depth       int     `xml:"depth,attr"`
+	Textbox     int     `xml:"textbox,attr,omitempty"` // for a field in a textbox, which one
+	Note        int     `xml:"note,attr,omitempty"`    // for a field in a footnote or endnote, which one
+	Anchor      *uint32 `xml:"anchor,attr,omitempty"`  // the CP of the textbox's anchor, or the note's reference mark
+	Instruction string  `xml:"instruction,omitempty"`
+	Result      string  `xml:"result,omitempty"` // with -results
+}
+
+// xmlStarted is set once the root element has been written, by the first call to writeXML
+var xmlStarted bool
+
+// writeXML writes a file element for a file, starting the document (the XML declaration and a doctool root element) the first time
+func writeXML(w io.Writer, name string, fix *fixity, res *fields.Report, err error) {
+	if !xmlStarted {
+		fmt.Fprintf(w, "%s<doctool xmlns=\"%s\">\n", xml.Header, xmlNamespace)
+		xmlStarted = true
+	}
+	xf := xmlFile{Path: header(name), Status: fileStatus(err)}
+	if fix != nil {
+		xf.Fixity = &xmlFixity{*hashAlg, fix.Size, fix.Checksum}
+	}
+	if err != nil {
+		xf.Errors = &xmlErrors{[]string{err.Error()}}
+	}
+	if filePolicy != nil {
+		verdict, matched := filePolicy.evaluate(res, err)
+		xf.Policy = &xmlPolicy{Verdict: verdictNames[verdict]}
+		for _, m := range matched {
+			xf.Policy.Rules = append(xf.Policy.Rules, xmlPolicyRule{m.name, verdictNames[m.verdict]})
+		}
+	}
+	if res != nil {
+		id := &xmlIdentification{Format: res.Format, Table: res.Table, Encryption: res.Encryption, Macros: res.Macros}
+		if res.Format == fields.FormatDOC {
+			id.Version, id.NFib, id.Template = res.WordVersion(), fmt.Sprintf("0x%04X", res.NFib), &res.Flags.Dot
+		}
+		if res.Format != "" {
+			xf.Identification = id
+		}
+		if *meta {
+			props := xmlProperties(res.Properties)
+			xf.Properties = &props
+			if len(res.CustomProperties) > 0 {
+				xf.Custom = &xmlCustom{}
+				for _, p := range res.CustomProperties {
+					xf.Custom.Property = append(xf.Custom.Property, xmlCustomProperty(p))
+				}
+			}
+		}
+		regions := res.AllRegions()
+		if len(regions) > 0 {
+			xf.Sections = &xmlSections{}
+		}
+		for _, r := range regions {
+			sec := xmlSection{Name: r.Key, Label: r.Name}
+			if o, ok := res.Offsets[r.Key]; ok {
+				sz := res.Sizes[r.Key]
+				sec.Offset, sec.Size = &o, &sz
+			}
+			occs := r.Occurrences
+			if !*raw {
+				occs = sortFields(occs)
+			}
+			for _, f := range occs {
+				xfld := xmlField{Type: f.Name, CP: f.CP, End: f.End, Depth: f.Depth, Instruction: f.Instruction, Result: f.Result}
+				if f.Story > 0 && (r.Key == "footnote" || r.Key == "endnote") {
+					xfld.Note = f.Story
+				} else if f.Story > 0 {
+					xfld.Textbox = f.Story
+				}
+				if f.Anchored {
+					anchor := f.Anchor
+					xfld.Anchor = &anchor
+				}
+				if t, ok := f.Type(); ok {
+					xfld.Keyword, xfld.Class = t.Keyword, t.Class.String()
+				}
+				if res.Format == fields.FormatDOC {
+					xfld.Code = fmt.Sprintf("0x%02X", f.Code)
+				}
+				sec.Fields = append(sec.Fields, xfld)
+			}
+			xf.Sections.Section = append(xf.Sections.Section, sec)
+		}
+		if len(res.Warnings) > 0 {
+			xf.Warnings = &xmlWarnings{res.Warnings}
+		}
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("  ", "  ")
+	if err := enc.Encode(xf); err != nil {
+		fmt.Fprintln(w, err)
+	}
+	fmt.Fprintln(w)
+}
+
+// closeXML ends the XML document, if one was started (with an empty doctool element if there were no files)
+func closeXML(w io.Writer) {
+	if !xmlStarted {
+		fmt.Fprintf(w, "%s<doctool xmlns=\"%s\">\n", xml.Header, xmlNamespace)
+	}
+	fmt.Fprintln(w, "</doctool>")
+}